@@ -74,6 +74,38 @@ func main() {
 		},
 	}))
 
+	if conf.UserDeadLetterTopic != "" && conf.UserDeadLetterTopic != "-" {
+		mustNotFail(reflector.AddChannel(asyncapi.ChannelInfo{
+			Name: conf.UserDeadLetterTopic,
+			BaseChannelItem: &spec.ChannelItem{
+				Description: "user commands, which could not be handled; headers: error, attempts, original-topic, original-partition, original-offset",
+			},
+			Subscribe: &asyncapi.MessageSample{
+				MessageEntity: spec.MessageEntity{
+					Name:  "UserCommandMsg",
+					Title: "UserCommandMsg",
+				},
+				MessageSample: new(ctrl.UserCommandMsg),
+			},
+		}))
+	}
+
+	if conf.UserEventsTopic != "" && conf.UserEventsTopic != "-" {
+		mustNotFail(reflector.AddChannel(asyncapi.ChannelInfo{
+			Name: conf.UserEventsTopic,
+			BaseChannelItem: &spec.ChannelItem{
+				Description: "progress of user deletions; event is one of USER_DELETION_STARTED, USER_SERVICE_CLEANED, USER_DELETED, USER_DELETION_FAILED",
+			},
+			Subscribe: &asyncapi.MessageSample{
+				MessageEntity: spec.MessageEntity{
+					Name:  "UserEventMsg",
+					Title: "UserEventMsg",
+				},
+				MessageSample: new(ctrl.UserEventMsg),
+			},
+		}))
+	}
+
 	buff, err := reflector.Schema.MarshalJSON()
 	mustNotFail(err)
 
@@ -19,270 +19,366 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/info": {
-            "put": {
-                "security": [
-                    {
-                        "Bearer": []
-                    }
-                ],
-                "description": "set user's details, parses jwt for ID",
-                "consumes": [
-                    "application/json"
-                ],
+        "/admin/audit/deletions": {
+            "get": {
+                "description": "lists the recorded delete and transfer requests with requester and per service outcome; requires admin rights",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "deletion",
+                    "audit"
                 ],
-                "summary": "set user info",
+                "summary": "list deletion audit log",
                 "parameters": [
                     {
-                        "description": "user details",
-                        "name": "message",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/api.UserInfoRequest"
-                        }
+                        "type": "string",
+                        "description": "deleted user ID",
+                        "name": "user",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "requesting user ID",
+                        "name": "requester",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "earliest request time (RFC3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "latest request time (RFC3339)",
+                        "name": "to",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/ctrl.AuditEntry"
+                            }
                         }
                     },
                     "400": {
                         "description": "Bad Request"
                     },
+                    "403": {
+                        "description": "Forbidden"
+                    },
                     "500": {
                         "description": "Internal Server Error"
                     }
-                }
-            }
-        },
-        "/password": {
-            "put": {
+                },
                 "security": [
                     {
                         "Bearer": []
                     }
-                ],
-                "description": "set password for user, parses jwt for ID",
-                "consumes": [
+                ]
+            }
+        },
+        "/admin/dead-letters": {
+            "get": {
+                "description": "list the user commands, which could not be handled and were moved to the dead-letter topic; requires admin rights",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "dead-letters"
+                ],
+                "summary": "list dead letters",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/ctrl.DeadLetter"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "404": {
+                        "description": "no dead-letter topic configured"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                },
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ]
+            }
+        },
+        "/admin/dead-letters/replay": {
+            "post": {
+                "description": "publishes dead-lettered user commands to the user topic again and removes them from the dead-letter list; replays all dead letters if no ids are given; requires admin rights",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "dead-letters"
                 ],
-                "summary": "set password for user",
+                "summary": "replay dead letters",
                 "parameters": [
                     {
-                        "description": "user password",
+                        "description": "dead letter ids",
                         "name": "message",
                         "in": "body",
-                        "required": true,
                         "schema": {
-                            "$ref": "#/definitions/api.PasswordRequest"
+                            "$ref": "#/definitions/api.ReplayRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "replayed dead letters",
                         "schema": {
-                            "type": "object"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/ctrl.DeadLetter"
+                            }
                         }
                     },
                     "400": {
                         "description": "Bad Request"
                     },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "404": {
+                        "description": "no dead-letter topic configured"
+                    },
                     "500": {
                         "description": "Internal Server Error"
                     }
-                }
-            }
-        },
-        "/sessions": {
-            "get": {
+                },
                 "security": [
                     {
                         "Bearer": []
                     }
-                ],
-                "description": "get user's sessions by parsing provided jwt token",
+                ]
+            }
+        },
+        "/admin/deletions": {
+            "get": {
+                "description": "list the states of all in-flight and finished user deletions; requires admin rights",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "deletion"
                 ],
-                "summary": "get user's sessions",
+                "summary": "list deletions",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "type": "object"
+                                "$ref": "#/definitions/ctrl.DeletionJob"
                             }
                         }
                     },
                     "400": {
                         "description": "Bad Request"
                     },
+                    "403": {
+                        "description": "Forbidden"
+                    },
                     "500": {
                         "description": "Internal Server Error"
                     }
-                }
-            }
-        },
-        "/user": {
-            "delete": {
+                },
                 "security": [
                     {
                         "Bearer": []
                     }
-                ],
-                "description": "delete user by parsing provided jwt token",
+                ]
+            }
+        },
+        "/admin/deletions/scheduled": {
+            "get": {
+                "description": "list the deletions, which are waiting for the end of their grace period; requires admin rights",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "deletion"
                 ],
-                "summary": "delete user",
+                "summary": "list scheduled deletions",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/ctrl.DeletionJob"
+                            }
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized"
+                    "400": {
+                        "description": "Bad Request"
                     },
-                    "412": {
-                        "description": "Precondition Failed"
+                    "403": {
+                        "description": "Forbidden"
                     },
                     "500": {
                         "description": "Internal Server Error"
                     }
-                }
-            }
-        },
-        "/user-list": {
-            "get": {
+                },
                 "security": [
                     {
                         "Bearer": []
                     }
-                ],
-                "description": "parses provided jwt and lists all users if admin or only lists users from groups the calling user is a member of",
+                ]
+            }
+        },
+        "/admin/inactive-users": {
+            "get": {
+                "description": "lists the users flagged as inactive by the inactive user check, with the time after which their deletion is requested; requires admin rights",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
-                ],
-                "summary": "get users",
-                "parameters": [
-                    {
-                        "type": "boolean",
-                        "description": "if true exclude calling user from result",
-                        "name": "excludeCaller",
-                        "in": "query"
-                    }
+                    "user",
+                    "deletion"
                 ],
+                "summary": "list inactive users",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/ctrl.User"
+                                "$ref": "#/definitions/ctrl.InactiveUser"
                             }
                         }
                     },
                     "400": {
                         "description": "Bad Request"
                     },
+                    "403": {
+                        "description": "Forbidden"
+                    },
                     "500": {
                         "description": "Internal Server Error"
                     }
-                }
-            }
-        },
-        "/user/id/{id}": {
-            "get": {
+                },
                 "security": [
                     {
                         "Bearer": []
                     }
-                ],
-                "description": "get user by providing a user ID",
+                ]
+            }
+        },
+        "/admin/inactive-users/report": {
+            "get": {
+                "description": "get the result of the last inactive user check, including dry runs; requires admin rights",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "user",
+                    "deletion"
                 ],
-                "summary": "get user by ID",
-                "parameters": [
+                "summary": "get inactive user report",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/ctrl.InactiveUserReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "404": {
+                        "description": "no finished check"
+                    }
+                },
+                "security": [
                     {
-                        "type": "string",
-                        "description": "user ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "Bearer": []
                     }
+                ]
+            }
+        },
+        "/admin/orphans": {
+            "get": {
+                "description": "get the result of the last orphan scan, triggered by an admin or scheduled; requires admin rights",
+                "produces": [
+                    "application/json"
                 ],
+                "tags": [
+                    "user",
+                    "deletion"
+                ],
+                "summary": "get orphan scan report",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/ctrl.User"
+                            "$ref": "#/definitions/ctrl.OrphanScanReport"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error"
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "404": {
+                        "description": "no finished scan"
                     }
-                }
-            },
-            "delete": {
+                },
                 "security": [
                     {
                         "Bearer": []
                     }
-                ],
-                "description": "delete user by providing a user ID",
+                ]
+            }
+        },
+        "/admin/orphans/scan": {
+            "post": {
+                "description": "lists per service the owners of resources, which do not exist in keycloak (e.g. users deleted in the keycloak admin console); with enqueue=true a forced deletion is requested for every orphaned owner; requires admin rights",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "user",
+                    "deletion"
                 ],
-                "summary": "delete user by ID",
+                "summary": "scan for orphaned resources",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "user ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "type": "boolean",
+                        "description": "enqueue cleanups of the orphaned owners",
+                        "name": "enqueue",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/ctrl.OrphanScanReport"
                         }
                     },
                     "400": {
@@ -291,72 +387,1597 @@ const docTemplate = `{
                     "403": {
                         "description": "Forbidden"
                     },
-                    "412": {
-                        "description": "Precondition Failed"
-                    },
                     "500": {
                         "description": "Internal Server Error"
                     }
-                }
-            }
-        },
-        "/user/id/{id}/name": {
-            "get": {
+                },
                 "security": [
                     {
                         "Bearer": []
                     }
-                ],
-                "description": "get username by providing a user ID",
+                ]
+            }
+        },
+        "/admin/users/bulk-delete": {
+            "post": {
+                "description": "deletes all users selected by exactly one of a list of ids, a keycloak group id or attributes (all have to match); the requesting user is never included. With preview=true only the selected users are returned. Otherwise one delete command per user is enqueued and the batch is returned; requires admin rights",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "user"
+                    "user",
+                    "deletion"
                 ],
-                "summary": "get username",
+                "summary": "bulk delete users",
                 "parameters": [
                     {
-                        "type": "string",
-                        "description": "user ID",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "user selection",
+                        "name": "message",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/ctrl.BulkDeletionRequest"
+                        }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "OK",
+                        "description": "preview",
                         "schema": {
-                            "type": "string"
+                            "$ref": "#/definitions/ctrl.BulkDeletionPreview"
+                        }
+                    },
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/ctrl.DeletionBatch"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "batch progress resource"
+                            }
                         }
                     },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
                     "500": {
                         "description": "Internal Server Error"
                     }
-                }
-            }
-        }
+                },
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ]
+            }
+        },
+        "/admin/users/bulk-delete/{id}": {
+            "get": {
+                "description": "get the aggregated states of the deletions of a bulk deletion; requires admin rights",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "deletion"
+                ],
+                "summary": "get bulk deletion progress",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "batch ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/ctrl.DeletionBatchProgress"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                },
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ]
+            }
+        },
+        "/sessions": {
+            "get": {
+                "description": "get user's sessions by parsing provided jwt token",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "get user's sessions",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "object"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                },
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ]
+            }
+        },
+        "/user": {
+            "delete": {
+                "description": "delete user by parsing provided jwt token",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "delete user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "overwrites the configured resource policy (delete, keep, anonymize or transfer-to:\u003cuser id\u003e for admins); available for every policy resource type",
+                        "name": "policy.export-databases",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "type": "string"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "deletion status resource"
+                            },
+                            "X-Cancel-Token": {
+                                "type": "string",
+                                "description": "cancels the deletion during the grace period without login (POST /user/id/{id}/deletion/cancel); only set for newly scheduled deletions"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "412": {
+                        "description": "Precondition Failed"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                },
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ]
+            }
+        },
+        "/user-list": {
+            "get": {
+                "description": "parses provided jwt and lists all users if admin or only lists users from groups the calling user is a member of; attributes are filtered by their configured visibility\nthe result is ordered by username and paged by limit and offset; X-Total-Count contains the number of matching users and Link the first, prev, next and last page\nnon-admins in several groups, or with search or attribute filters, are paged in memory: keycloak can neither page the union of groups nor search group members, so every member of the groups is requested",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "get users",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "if true exclude calling user from result",
+                        "name": "excludeCaller",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "max number of users; 0 or missing lists all users",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "number of skipped users",
+                        "name": "offset",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "keycloak search in username, email, first and last name",
+                        "name": "search",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "username (default) or -username",
+                        "name": "sort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "attribute filter, e.g. attr.locale=de; every attribute has to match",
+                        "name": "attr.key",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/ctrl.User"
+                            }
+                        },
+                        "headers": {
+                            "Link": {
+                                "type": "string",
+                                "description": "first, prev, next and last page, if limit is set"
+                            },
+                            "X-Total-Count": {
+                                "type": "integer",
+                                "description": "number of matching users"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                },
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ]
+            }
+        },
+        "/user/export": {
+            "post": {
+                "description": "starts the export of all data of the requesting user as zip archive of json files with a manifest; the archive is generated asynchronously and can be downloaded until it expires",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user",
+                    "export"
+                ],
+                "summary": "export own data",
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/ctrl.DataExport"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "export status resource"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "412": {
+                        "description": "Precondition Failed"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                },
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ]
+            }
+        },
+        "/user/exports/{id}": {
+            "get": {
+                "description": "get the state of a data export; requires admin rights or to be the exported or requesting user",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user",
+                    "export"
+                ],
+                "summary": "get export status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "export ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/ctrl.DataExport"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                },
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ]
+            }
+        },
+        "/user/exports/{id}/download": {
+            "get": {
+                "description": "download the zip archive of a finished data export; requires admin rights or to be the exported or requesting user",
+                "produces": [
+                    "application/zip"
+                ],
+                "tags": [
+                    "user",
+                    "export"
+                ],
+                "summary": "download export",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "export ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "404": {
+                        "description": "unknown, unfinished or expired export"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                },
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ]
+            }
+        },
+        "/user/id/{id}": {
+            "get": {
+                "description": "get user by providing a user ID; attributes are filtered by their configured visibility and the relation between caller and user",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "get user by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "user ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/ctrl.User"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                },
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ]
+            },
+            "delete": {
+                "description": "delete user by providing a user ID; force=true (admin only) publishes a forced deletion, which is executed even for already deleted users to clean up remaining resources",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "delete user by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "user ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "re-run an already completed deletion",
+                        "name": "force",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "overwrites the configured resource policy (delete, keep, anonymize or transfer-to:\u003cuser id\u003e for admins); available for every policy resource type",
+                        "name": "policy.export-databases",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "type": "string"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "deletion status resource"
+                            },
+                            "X-Cancel-Token": {
+                                "type": "string",
+                                "description": "cancels the deletion during the grace period without login (POST /user/id/{id}/deletion/cancel); only set for newly scheduled deletions"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "412": {
+                        "description": "Precondition Failed"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                },
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ]
+            }
+        },
+        "/user/id/{id}/deletion": {
+            "get": {
+                "description": "get the state of the deletion of a user, including the progress of every service cleanup; requires admin rights or a matching user ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user",
+                    "deletion"
+                ],
+                "summary": "get deletion status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "user ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/ctrl.DeletionJob"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                },
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ]
+            },
+            "delete": {
+                "description": "cancels a deletion, which is still in its grace period, and enables the user again; requires admin rights or a matching user ID",
+                "tags": [
+                    "user",
+                    "deletion"
+                ],
+                "summary": "cancel scheduled deletion",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "user ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "404": {
+                        "description": "Not Found"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                },
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ]
+            }
+        },
+        "/user/id/{id}/deletion/cancel": {
+            "post": {
+                "description": "cancels a deletion, which is still in its grace period, and enables the user again; needs no jwt, because the user is disabled during the grace period, but the X-Cancel-Token returned by the deletion request",
+                "tags": [
+                    "user",
+                    "deletion"
+                ],
+                "summary": "cancel scheduled deletion with cancel token",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "user ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "cancel token",
+                        "name": "message",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.CancelDeletionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                }
+            }
+        },
+        "/user/id/{id}/export": {
+            "post": {
+                "description": "starts the export of all data of the user as zip archive of json files with a manifest; the archive is generated asynchronously and can be downloaded until it expires; requires admin rights or a matching user ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user",
+                    "export"
+                ],
+                "summary": "export user data by ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "user ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/ctrl.DataExport"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "export status resource"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "412": {
+                        "description": "Precondition Failed"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                },
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ]
+            }
+        },
+        "/user/id/{id}/name": {
+            "get": {
+                "description": "get username by providing a user ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "get username",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "user ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                },
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ]
+            }
+        },
+        "/user/id/{id}/resources": {
+            "get": {
+                "description": "lists per service which resources a deletion of the user would remove and which would be kept, without deleting anything; requires admin rights or a matching user ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user",
+                    "deletion"
+                ],
+                "summary": "preview user deletion",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "user ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "previews the deletion with an overwritten resource policy; available for every policy resource type",
+                        "name": "policy.export-databases",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/ctrl.UserResources"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                },
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ]
+            }
+        },
+        "/user/id/{id}/transfer": {
+            "post": {
+                "description": "hands the resources of the user to the target user in every service supporting it, reports services unable to transfer in the deletion status and removes the user afterward; requires admin rights",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user",
+                    "deletion"
+                ],
+                "summary": "transfer resources and delete user",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "user ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "target user",
+                        "name": "message",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/api.TransferRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "type": "string"
+                        },
+                        "headers": {
+                            "Location": {
+                                "type": "string",
+                                "description": "deletion status resource"
+                            },
+                            "X-Cancel-Token": {
+                                "type": "string",
+                                "description": "cancels the deletion during the grace period without login (POST /user/id/{id}/deletion/cancel); only set for newly scheduled deletions"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "412": {
+                        "description": "Precondition Failed"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                },
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ]
+            }
+        },
+        "/user/names": {
+            "post": {
+                "description": "get the usernames of a list of user IDs; unknown IDs are mapped to null, IDs whose lookup failed are listed in failed with the error\nthe number of IDs is limited by KeycloakLookupMaxIds; fails only if keycloak is unreachable",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "get usernames",
+                "parameters": [
+                    {
+                        "description": "user IDs",
+                        "name": "ids",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "type": "string"
+                            }
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/ctrl.UsernameLookup"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                },
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ]
+            }
+        },
+        "/user/search": {
+            "get": {
+                "description": "lists users matching all given criteria, ordered by username; non-admins only find members of their groups and attributes are filtered by their configured visibility\nan exact search matching several users is answered with 409",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "user"
+                ],
+                "summary": "search users",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "username",
+                        "name": "username",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "email",
+                        "name": "email",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "attribute filter, e.g. attr.locale=de; every attribute has to match",
+                        "name": "attr.key",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "username and email have to be equal instead of containing the value",
+                        "name": "exact",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/ctrl.User"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "409": {
+                        "description": "ambiguous exact match; contains the matching users",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/ctrl.User"
+                            }
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error"
+                    }
+                },
+                "security": [
+                    {
+                        "Bearer": []
+                    }
+                ]
+            }
+        }
     },
     "definitions": {
-        "api.PasswordRequest": {
+        "api.CancelDeletionRequest": {
+            "type": "object",
+            "properties": {
+                "cancel_token": {
+                    "type": "string"
+                }
+            }
+        },
+        "api.ReplayRequest": {
+            "type": "object",
+            "properties": {
+                "ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "api.TransferRequest": {
+            "type": "object",
+            "properties": {
+                "target_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "ctrl.AuditEntry": {
+            "type": "object",
+            "properties": {
+                "command": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "finished": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "requested": {
+                    "type": "string"
+                },
+                "requester_admin": {
+                    "description": "the requester used admin rights",
+                    "type": "boolean"
+                },
+                "requester_id": {
+                    "description": "sub of the requesting token; empty for kafka commands without requester",
+                    "type": "string"
+                },
+                "services": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/ctrl.AuditService"
+                    }
+                },
+                "source": {
+                    "description": "api or kafka",
+                    "type": "string"
+                },
+                "started": {
+                    "type": "string"
+                },
+                "state": {
+                    "$ref": "#/definitions/ctrl.DeletionState"
+                },
+                "target_user_id": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "ctrl.AuditService": {
+            "type": "object",
+            "properties": {
+                "deleted": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "orphans": {
+                    "description": "resources found by the verification after the cleanup",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "service": {
+                    "type": "string"
+                },
+                "state": {
+                    "$ref": "#/definitions/ctrl.DeletionState"
+                },
+                "transferred": {
+                    "type": "integer"
+                }
+            }
+        },
+        "ctrl.BulkDeletionPreview": {
+            "type": "object",
+            "properties": {
+                "unknown": {
+                    "description": "requested ids without keycloak user",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "users": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/ctrl.User"
+                    }
+                }
+            }
+        },
+        "ctrl.BulkDeletionRequest": {
+            "type": "object",
+            "properties": {
+                "attributes": {
+                    "description": "all attributes have to match",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "group_id": {
+                    "type": "string"
+                },
+                "ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "preview": {
+                    "description": "only resolve the users, without deleting them",
+                    "type": "boolean"
+                }
+            }
+        },
+        "ctrl.DataExport": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "errors": {
+                    "description": "services, which could not be exported",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "expires": {
+                    "description": "the archive is removed after this time",
+                    "type": "string"
+                },
+                "finished": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "requester_id": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "state": {
+                    "$ref": "#/definitions/ctrl.DataExportState"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "ctrl.DataExportState": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "running",
+                "done",
+                "failed"
+            ],
+            "x-enum-varnames": [
+                "DataExportPending",
+                "DataExportRunning",
+                "DataExportDone",
+                "DataExportFailed"
+            ]
+        },
+        "ctrl.DeadLetter": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "created": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "key": {
+                    "type": "string"
+                },
+                "offset": {
+                    "type": "integer"
+                },
+                "partition": {
+                    "type": "integer"
+                },
+                "topic": {
+                    "type": "string"
+                },
+                "value": {
+                    "type": "string"
+                }
+            }
+        },
+        "ctrl.DeletionBatch": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "string"
+                },
+                "errors": {
+                    "description": "users, which could not be enqueued",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "requester_id": {
+                    "type": "string"
+                },
+                "user_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "ctrl.DeletionBatchProgress": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "string"
+                },
+                "errors": {
+                    "description": "users, which could not be enqueued",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "finished": {
+                    "description": "all enqueued deletions are done or failed",
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "requester_id": {
+                    "type": "string"
+                },
+                "states": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                },
+                "user_ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "ctrl.DeletionJob": {
+            "type": "object",
+            "properties": {
+                "audit_id": {
+                    "description": "audit entry of the request",
+                    "type": "string"
+                },
+                "cancel_token_hash": {
+                    "description": "sha256 of the token, which cancels a scheduled job without login",
+                    "type": "string"
+                },
+                "command": {
+                    "type": "string"
+                },
+                "created": {
+                    "type": "string"
+                },
+                "finished": {
+                    "type": "string"
+                },
+                "policies": {
+                    "description": "ResourcePolicies overrides of the request",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "scheduled_for": {
+                    "description": "end of the grace period of a scheduled job",
+                    "type": "string"
+                },
+                "state": {
+                    "$ref": "#/definitions/ctrl.DeletionState"
+                },
+                "steps": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/ctrl.DeletionStep"
+                    }
+                },
+                "target_user_id": {
+                    "description": "receiver of the resources of a TRANSFER",
+                    "type": "string"
+                },
+                "updated": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "ctrl.DeletionState": {
+            "type": "string",
+            "enum": [
+                "pending",
+                "running",
+                "done",
+                "failed",
+                "skipped",
+                "scheduled",
+                "canceled"
+            ],
+            "x-enum-varnames": [
+                "DeletionPending",
+                "DeletionRunning",
+                "DeletionDone",
+                "DeletionFailed",
+                "DeletionSkipped",
+                "DeletionScheduled",
+                "AuditCanceled"
+            ]
+        },
+        "ctrl.DeletionStep": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer"
+                },
+                "copied": {
+                    "description": "ids of resources copied to the transfer target; not copied again by retries",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "decisions": {
+                    "description": "handling of resources with a ResourcePolicy",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/ctrl.PolicyDecision"
+                    }
+                },
+                "deleted": {
+                    "type": "integer"
+                },
+                "finished": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "type": "string"
+                },
+                "note": {
+                    "type": "string"
+                },
+                "orphans": {
+                    "description": "resources still found by the verification after the last attempt",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "service": {
+                    "type": "string"
+                },
+                "state": {
+                    "$ref": "#/definitions/ctrl.DeletionState"
+                },
+                "transferred": {
+                    "type": "integer"
+                }
+            }
+        },
+        "ctrl.InactiveUser": {
+            "type": "object",
+            "properties": {
+                "delete_after": {
+                    "type": "string"
+                },
+                "deletion_requested": {
+                    "type": "boolean"
+                },
+                "flagged": {
+                    "type": "string"
+                },
+                "last_activity": {
+                    "type": "string"
+                },
+                "notified": {
+                    "type": "boolean"
+                },
+                "user_id": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "ctrl.InactiveUserReport": {
+            "type": "object",
+            "properties": {
+                "deleted": {
+                    "description": "users with requested deletion",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/ctrl.InactiveUser"
+                    }
+                },
+                "dry_run": {
+                    "type": "boolean"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "flagged": {
+                    "description": "newly flagged users",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/ctrl.InactiveUser"
+                    }
+                },
+                "time": {
+                    "type": "string"
+                },
+                "unflagged": {
+                    "description": "previously flagged users, which are active or excluded again",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "ctrl.KeptResource": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "ctrl.OrphanScanReport": {
+            "type": "object",
+            "properties": {
+                "enqueue": {
+                    "type": "boolean"
+                },
+                "enqueued": {
+                    "description": "orphaned owners with enqueued cleanup",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "finished": {
+                    "type": "string"
+                },
+                "orphans": {
+                    "description": "orphaned owners of all services",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "services": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/ctrl.OrphanScanService"
+                    }
+                },
+                "started": {
+                    "type": "string"
+                }
+            }
+        },
+        "ctrl.OrphanScanService": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "orphans": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "owners": {
+                    "type": "integer"
+                },
+                "service": {
+                    "type": "string"
+                }
+            }
+        },
+        "ctrl.PolicyDecision": {
             "type": "object",
             "properties": {
-                "password": {
+                "id": {
+                    "type": "string"
+                },
+                "policy": {
+                    "type": "string"
+                },
+                "resource": {
+                    "type": "string"
+                },
+                "target": {
+                    "description": "new owner of transferred or anonymized resources",
                     "type": "string"
                 }
             }
         },
-        "api.UserInfoRequest": {
+        "ctrl.ServiceResources": {
             "type": "object",
             "properties": {
-                "email": {
+                "count": {
+                    "type": "integer"
+                },
+                "error": {
                     "type": "string"
                 },
-                "first_name": {
+                "ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "kept": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/ctrl.KeptResource"
+                    }
+                },
+                "note": {
                     "type": "string"
                 },
-                "last_name": {
+                "resource": {
+                    "type": "string"
+                },
+                "service": {
                     "type": "string"
                 }
             }
@@ -375,6 +1996,39 @@ const docTemplate = `{
                     "type": "string"
                 }
             }
+        },
+        "ctrl.UserResources": {
+            "type": "object",
+            "properties": {
+                "services": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/ctrl.ServiceResources"
+                    }
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "ctrl.UsernameLookup": {
+            "type": "object",
+            "properties": {
+                "failed": {
+                    "description": "errors by id, for ids whose lookup failed",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "names": {
+                    "description": "usernames by id; nil for unknown ids",
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                }
+            }
         }
     },
     "securityDefinitions": {
@@ -54,7 +54,7 @@ func main() {
 	var shutdownTime time.Time
 	go func() {
 		shutdown := make(chan os.Signal, 1)
-		signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM, syscall.SIGKILL)
+		signal.Notify(shutdown, syscall.SIGINT, syscall.SIGTERM)
 		sig := <-shutdown
 		log.Println("received shutdown signal", sig)
 		shutdownTime = time.Now()
@@ -73,6 +73,7 @@ func main() {
 }
 
 func PublishAsyncApiDoc(conf configuration.Config) error {
-	ctx, _ := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 	return client.New(http.DefaultClient, conf.ApiDocsProviderBaseUrl).AsyncapiPutDoc(ctx, "github_com_SENERGY-Platform_user-management", docs.AsyncApiDoc)
 }
@@ -19,6 +19,7 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	_ "github.com/SENERGY-Platform/user-management/docs"
 	"github.com/SENERGY-Platform/user-management/pkg/api/util"
 	"github.com/SENERGY-Platform/user-management/pkg/configuration"
@@ -26,10 +27,16 @@ import (
 	"github.com/julienschmidt/httprouter"
 	"github.com/swaggo/http-swagger"
 	"github.com/swaggo/swag"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 type api struct {
@@ -40,19 +47,49 @@ type api struct {
 func Start(ctx context.Context, conf configuration.Config) (wg *sync.WaitGroup, err error) {
 	wg = &sync.WaitGroup{}
 
-	eventHandler, err := ctrl.InitEventConn(ctx, wg, conf)
+	apiInstance := &api{
+		conf: conf,
+	}
+	err = ctrl.ValidateAttributeVisibility(conf)
 	if err != nil {
 		return
 	}
-	apiInstance := &api{
-		eventHandler: eventHandler,
-		conf:         conf,
+	authorizationHandler, err := NewAuthorizationMiddleware(conf, apiInstance.getRoutes())
+	if err != nil {
+		return
+	}
+	authHandler, err := NewAuthMiddleware(conf, authorizationHandler)
+	if err != nil {
+		return
+	}
+	apiInstance.eventHandler, err = ctrl.InitEventConn(ctx, wg, conf)
+	if err != nil {
+		return
 	}
 	log.Println("start server on port: ", conf.ServerPort)
-	httpHandler := apiInstance.getRoutes()
-	corsHandler := util.NewCors(httpHandler)
+	corsHandler := util.NewCors(authHandler)
 	logg := util.NewLogger(corsHandler)
-	go func() { log.Println(http.ListenAndServe(":"+conf.ServerPort, logg)) }()
+	server := &http.Server{
+		Addr:    ":" + conf.ServerPort,
+		Handler: logg,
+		BaseContext: func(net.Listener) context.Context {
+			return ctx //cancels in-flight requests on shutdown
+		},
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		log.Println("shutdown server", server.Shutdown(shutdownCtx))
+	}()
+	go func() {
+		err := server.ListenAndServe()
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Println(err)
+		}
+	}()
 	return
 }
 
@@ -65,13 +102,41 @@ func Start(ctx context.Context, conf configuration.Config) (wg *sync.WaitGroup,
 // @in header
 // @name Authorization
 // @description Type "Bearer" followed by a space and JWT token.
+// NewRouter returns the routes of the api behind the AuthorizationMiddleware, without AuthMiddleware and cors
+func NewRouter(conf configuration.Config, eventHandler *ctrl.EventHandler) (*AuthorizationMiddleware, error) {
+	apiInstance := &api{conf: conf, eventHandler: eventHandler}
+	return NewAuthorizationMiddleware(conf, apiInstance.getRoutes())
+}
+
 func (api *api) getRoutes() (router *httprouter.Router) {
 	router = httprouter.New()
 	api.getUserByID(router)
 	api.deleteUserByID(router)
 	api.deleteUser(router)
+	api.transferUserByID(router)
 	api.getUsernameByID(router)
+	api.getUsernamesByIDs(router)
+	api.getDeletionByID(router)
+	api.cancelDeletionByID(router)
+	api.cancelDeletionWithToken(router)
+	api.getResourcesByID(router)
+	api.listDeletions(router)
+	api.listScheduledDeletions(router)
+	api.listDeletionAudit(router)
+	api.bulkDeleteUsers(router)
+	api.getDeletionBatch(router)
+	api.listInactiveUsers(router)
+	api.getInactiveUserReport(router)
+	api.scanOrphans(router)
+	api.getOrphanScanReport(router)
+	api.listDeadLetters(router)
+	api.replayDeadLetters(router)
+	api.exportUser(router)
+	api.exportUserByID(router)
+	api.getDataExportByID(router)
+	api.downloadDataExport(router)
 	api.getUsers(router)
+	api.searchUsers(router)
 	api.getSessions(router)
 	if api.conf.EnableSwaggerUi {
 		router.GET("/swagger/:any", func(res http.ResponseWriter, req *http.Request, p httprouter.Params) {
@@ -93,7 +158,7 @@ func (api *api) getRoutes() (router *httprouter.Router) {
 
 // getUserByID godoc
 // @Summary      get user by ID
-// @Description  get user by providing a user ID
+// @Description  get user by providing a user ID; attributes are filtered by their configured visibility and the relation between caller and user
 // @Tags         user
 // @Security Bearer
 // @Param        id path string true "user ID"
@@ -103,25 +168,39 @@ func (api *api) getRoutes() (router *httprouter.Router) {
 // @Router       /user/id/{id} [get]
 func (api *api) getUserByID(router *httprouter.Router) {
 	router.GET("/user/id/:id", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		token, err := GetParsedToken(r)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
 		id := ps.ByName("id")
-		user, err := ctrl.GetUserById(id, api.conf)
+		user, err := ctrl.GetUserById(r.Context(), id, api.conf)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		relation, err := ctrl.GetUserRelation(r.Context(), token.GetUserId(), token.IsAdmin(), user, api.conf)
 		if err != nil {
 			http.Error(res, err.Error(), http.StatusInternalServerError)
 			return
 		}
 		res.Header().Set("Content-Type", "application/json; charset=utf-8")
-		json.NewEncoder(res).Encode(user)
+		json.NewEncoder(res).Encode(ctrl.FilterUserAttributes(user, relation, api.conf))
 	})
 }
 
 // deleteUserByID godoc
 // @Summary      delete user by ID
-// @Description  delete user by providing a user ID
+// @Description  delete user by providing a user ID; force=true (admin only) publishes a forced deletion, which is executed even for already deleted users to clean up remaining resources
 // @Tags         user
 // @Security Bearer
 // @Param        id path string true "user ID"
+// @Param        force query bool false "re-run an already completed deletion"
+// @Param        policy.export-databases query string false "overwrites the configured resource policy (delete, keep, anonymize or transfer-to:<user id> for admins); available for every policy resource type"
 // @Produce      json
-// @Success      200 {object} string
+// @Success      202 {object} string
+// @Header       202 {string} Location "deletion status resource"
+// @Header       202 {string} X-Cancel-Token "cancels the deletion during the grace period without login (POST /user/id/{id}/deletion/cancel); only set for newly scheduled deletions"
 // @Failure      400
 // @Failure      403
 // @Failure      412
@@ -130,21 +209,33 @@ func (api *api) getUserByID(router *httprouter.Router) {
 func (api *api) deleteUserByID(router *httprouter.Router) {
 	router.DELETE("/user/id/:id", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 		id := ps.ByName("id")
-		token, err := GetParsedToken(r)
-		if err != nil {
-			http.Error(res, err.Error(), http.StatusBadRequest)
+		token, ok := requireSelfOrAdmin(res, r, id)
+		if !ok {
 			return
 		}
-		if token.GetUserId() != id && !token.IsAdmin() {
-			http.Error(res, "access denied", http.StatusForbidden)
+		policies, status, err := getPolicyOverrides(r, token)
+		if err != nil {
+			http.Error(res, err.Error(), status)
 			return
 		}
-		err = api.eventHandler.DeleteUser(id)
+		requester := ctrl.Requester{Id: token.GetUserId(), Admin: token.GetUserId() != id}
+		cancelToken := ""
+		if r.URL.Query().Get("force") == "true" {
+			if !token.IsAdmin() {
+				http.Error(res, "access denied", http.StatusForbidden)
+				return
+			}
+			requester.Admin = true
+			err = api.eventHandler.ForceDeleteUser(id, requester, policies)
+		} else {
+			cancelToken, err = api.eventHandler.DeleteUser(r.Context(), id, requester, policies)
+		}
 		if err != nil {
 			http.Error(res, err.Error(), http.StatusPreconditionFailed)
 			return
 		}
-		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		setDeletionHeaders(res, id, cancelToken)
+		res.WriteHeader(http.StatusAccepted)
 		json.NewEncoder(res).Encode("ok")
 	})
 }
@@ -154,9 +245,14 @@ func (api *api) deleteUserByID(router *httprouter.Router) {
 // @Description  delete user by parsing provided jwt token
 // @Tags         user
 // @Security Bearer
+// @Param        policy.export-databases query string false "overwrites the configured resource policy (delete, keep, anonymize or transfer-to:<user id> for admins); available for every policy resource type"
 // @Produce      json
-// @Success      200 {object} string
+// @Success      202 {object} string
+// @Header       202 {string} Location "deletion status resource"
+// @Header       202 {string} X-Cancel-Token "cancels the deletion during the grace period without login (POST /user/id/{id}/deletion/cancel); only set for newly scheduled deletions"
+// @Failure      400
 // @Failure      401
+// @Failure      403
 // @Failure      412
 // @Failure      500
 // @Router       /user [delete]
@@ -167,16 +263,768 @@ func (api *api) deleteUser(router *httprouter.Router) {
 			http.Error(res, err.Error(), http.StatusBadRequest)
 			return
 		}
-		err = api.eventHandler.DeleteUser(token.GetUserId())
+		policies, status, err := getPolicyOverrides(r, token)
+		if err != nil {
+			http.Error(res, err.Error(), status)
+			return
+		}
+		cancelToken, err := api.eventHandler.DeleteUser(r.Context(), token.GetUserId(), ctrl.Requester{Id: token.GetUserId()}, policies)
 		if err != nil {
 			http.Error(res, err.Error(), http.StatusPreconditionFailed)
 			return
 		}
-		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		setDeletionHeaders(res, token.GetUserId(), cancelToken)
+		res.WriteHeader(http.StatusAccepted)
 		json.NewEncoder(res).Encode("ok")
 	})
 }
 
+type TransferRequest struct {
+	TargetId string `json:"target_id"`
+}
+
+// transferUserByID godoc
+// @Summary      transfer resources and delete user
+// @Description  hands the resources of the user to the target user in every service supporting it, reports services unable to transfer in the deletion status and removes the user afterward; requires admin rights
+// @Tags         user, deletion
+// @Security Bearer
+// @Param        id path string true "user ID"
+// @Param        message body TransferRequest true "target user"
+// @Produce      json
+// @Success      202 {object} string
+// @Header       202 {string} Location "deletion status resource"
+// @Header       202 {string} X-Cancel-Token "cancels the deletion during the grace period without login (POST /user/id/{id}/deletion/cancel); only set for newly scheduled deletions"
+// @Failure      400
+// @Failure      403
+// @Failure      412
+// @Failure      500
+// @Router       /user/id/{id}/transfer [post]
+func (api *api) transferUserByID(router *httprouter.Router) {
+	router.POST("/user/id/:id/transfer", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		id := ps.ByName("id")
+		token, ok := requireAdmin(res, r)
+		if !ok {
+			return
+		}
+		transfer := TransferRequest{}
+		err := json.NewDecoder(r.Body).Decode(&transfer)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if transfer.TargetId == "" || transfer.TargetId == id {
+			http.Error(res, "invalid target_id", http.StatusBadRequest)
+			return
+		}
+		cancelToken, err := api.eventHandler.TransferUser(r.Context(), id, transfer.TargetId, ctrl.Requester{Id: token.GetUserId(), Admin: true})
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusPreconditionFailed)
+			return
+		}
+		setDeletionHeaders(res, id, cancelToken)
+		res.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(res).Encode("ok")
+	})
+}
+
+// CancelTokenHeader returns the token of a scheduled deletion, which allows the disabled user to cancel it
+const CancelTokenHeader = "X-Cancel-Token"
+
+func getDeletionLocation(id string) string {
+	return "/user/id/" + url.PathEscape(id) + "/deletion"
+}
+
+// setDeletionHeaders sets the headers of an accepted deletion request; the cancel token is only set for scheduled deletions
+func setDeletionHeaders(res http.ResponseWriter, id string, cancelToken string) {
+	res.Header().Set("Content-Type", "application/json; charset=utf-8")
+	res.Header().Set("Location", getDeletionLocation(id))
+	if cancelToken != "" {
+		res.Header().Set(CancelTokenHeader, cancelToken)
+	}
+}
+
+// getPolicyOverrides reads the resource policies of the query parameters policy.<resource type>=<policy>.
+// Only admins may transfer resources to other users.
+func getPolicyOverrides(r *http.Request, token Token) (policies map[string]string, status int, err error) {
+	for key, values := range r.URL.Query() {
+		resource, found := strings.CutPrefix(key, "policy.")
+		if !found || len(values) == 0 {
+			continue
+		}
+		if policies == nil {
+			policies = map[string]string{}
+		}
+		policies[resource] = values[0]
+	}
+	err = ctrl.ValidateResourcePolicies(policies)
+	if err != nil {
+		return policies, http.StatusBadRequest, err
+	}
+	for _, value := range policies {
+		if strings.HasPrefix(value, ctrl.PolicyTransferTo) && !token.IsAdmin() {
+			return policies, http.StatusForbidden, errors.New("access denied: transfer-to policies require admin rights")
+		}
+	}
+	return policies, http.StatusOK, nil
+}
+
+// getDeletionByID godoc
+// @Summary      get deletion status
+// @Description  get the state of the deletion of a user, including the progress of every service cleanup; requires admin rights or a matching user ID
+// @Tags         user, deletion
+// @Security Bearer
+// @Param        id path string true "user ID"
+// @Produce      json
+// @Success      200 {object} ctrl.DeletionJob
+// @Failure      400
+// @Failure      403
+// @Failure      404
+// @Failure      500
+// @Router       /user/id/{id}/deletion [get]
+func (api *api) getDeletionByID(router *httprouter.Router) {
+	router.GET("/user/id/:id/deletion", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		id := ps.ByName("id")
+		if _, ok := requireSelfOrAdmin(res, r, id); !ok {
+			return
+		}
+		job, exists, err := api.eventHandler.GetDeletionJob(id)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !exists {
+			http.Error(res, "no deletion found", http.StatusNotFound)
+			return
+		}
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(res).Encode(job)
+	})
+}
+
+// cancelDeletionByID godoc
+// @Summary      cancel scheduled deletion
+// @Description  cancels a deletion, which is still in its grace period, and enables the user again; requires admin rights or a matching user ID
+// @Tags         user, deletion
+// @Security Bearer
+// @Param        id path string true "user ID"
+// @Success      204
+// @Failure      400
+// @Failure      403
+// @Failure      404
+// @Failure      500
+// @Router       /user/id/{id}/deletion [delete]
+func (api *api) cancelDeletionByID(router *httprouter.Router) {
+	router.DELETE("/user/id/:id/deletion", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		id := ps.ByName("id")
+		if _, ok := requireSelfOrAdmin(res, r, id); !ok {
+			return
+		}
+		err := api.eventHandler.CancelDeletion(r.Context(), id)
+		if errors.Is(err, ctrl.ErrNotScheduled) {
+			http.Error(res, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.WriteHeader(http.StatusNoContent)
+	})
+}
+
+type CancelDeletionRequest struct {
+	CancelToken string `json:"cancel_token"`
+}
+
+// cancelDeletionWithToken godoc
+// @Summary      cancel scheduled deletion with cancel token
+// @Description  cancels a deletion, which is still in its grace period, and enables the user again; needs no jwt, because the user is disabled during the grace period, but the X-Cancel-Token returned by the deletion request
+// @Tags         user, deletion
+// @Param        id path string true "user ID"
+// @Param        message body CancelDeletionRequest true "cancel token"
+// @Success      204
+// @Failure      400
+// @Failure      403
+// @Failure      500
+// @Router       /user/id/{id}/deletion/cancel [post]
+func (api *api) cancelDeletionWithToken(router *httprouter.Router) {
+	router.POST("/user/id/:id/deletion/cancel", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		id := ps.ByName("id")
+		request := CancelDeletionRequest{}
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = api.eventHandler.CancelDeletionWithToken(r.Context(), id, request.CancelToken)
+		if errors.Is(err, ctrl.ErrInvalidCancelToken) {
+			http.Error(res, err.Error(), http.StatusForbidden)
+			return
+		}
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// getResourcesByID godoc
+// @Summary      preview user deletion
+// @Description  lists per service which resources a deletion of the user would remove and which would be kept, without deleting anything; requires admin rights or a matching user ID
+// @Tags         user, deletion
+// @Security Bearer
+// @Param        id path string true "user ID"
+// @Param        policy.export-databases query string false "previews the deletion with an overwritten resource policy; available for every policy resource type"
+// @Produce      json
+// @Success      200 {object} ctrl.UserResources
+// @Failure      400
+// @Failure      403
+// @Failure      500
+// @Router       /user/id/{id}/resources [get]
+func (api *api) getResourcesByID(router *httprouter.Router) {
+	router.GET("/user/id/:id/resources", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		id := ps.ByName("id")
+		token, ok := requireSelfOrAdmin(res, r, id)
+		if !ok {
+			return
+		}
+		policies, status, err := getPolicyOverrides(r, token)
+		if err != nil {
+			http.Error(res, err.Error(), status)
+			return
+		}
+		resources, err := ctrl.GetUserResources(r.Context(), id, ctrl.MergeResourcePolicies(api.conf, policies))
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(res).Encode(resources)
+	})
+}
+
+// listDeletions godoc
+// @Summary      list deletions
+// @Description  list the states of all in-flight and finished user deletions; requires admin rights
+// @Tags         deletion
+// @Security Bearer
+// @Produce      json
+// @Success      200 {array} ctrl.DeletionJob
+// @Failure      400
+// @Failure      403
+// @Failure      500
+// @Router       /admin/deletions [get]
+func (api *api) listDeletions(router *httprouter.Router) {
+	router.GET("/admin/deletions", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if _, ok := requireAdmin(res, r); !ok {
+			return
+		}
+		jobs, err := api.eventHandler.ListDeletionJobs()
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(res).Encode(jobs)
+	})
+}
+
+// listScheduledDeletions godoc
+// @Summary      list scheduled deletions
+// @Description  list the deletions, which are waiting for the end of their grace period; requires admin rights
+// @Tags         deletion
+// @Security Bearer
+// @Produce      json
+// @Success      200 {array} ctrl.DeletionJob
+// @Failure      400
+// @Failure      403
+// @Failure      500
+// @Router       /admin/deletions/scheduled [get]
+func (api *api) listScheduledDeletions(router *httprouter.Router) {
+	router.GET("/admin/deletions/scheduled", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if _, ok := requireAdmin(res, r); !ok {
+			return
+		}
+		jobs, err := api.eventHandler.ListScheduledDeletions()
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(res).Encode(jobs)
+	})
+}
+
+// bulkDeleteUsers godoc
+// @Summary      bulk delete users
+// @Description  deletes all users selected by exactly one of a list of ids, a keycloak group id or attributes (all have to match); the requesting user is never included. With preview=true only the selected users are returned. Otherwise one delete command per user is enqueued and the batch is returned; requires admin rights
+// @Tags         user, deletion
+// @Security Bearer
+// @Param        message body ctrl.BulkDeletionRequest true "user selection"
+// @Produce      json
+// @Success      200 {object} ctrl.BulkDeletionPreview "preview"
+// @Success      202 {object} ctrl.DeletionBatch
+// @Header       202 {string} Location "batch progress resource"
+// @Failure      400
+// @Failure      403
+// @Failure      500
+// @Router       /admin/users/bulk-delete [post]
+func (api *api) bulkDeleteUsers(router *httprouter.Router) {
+	router.POST("/admin/users/bulk-delete", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		token, ok := requireAdmin(res, r)
+		if !ok {
+			return
+		}
+		request := ctrl.BulkDeletionRequest{}
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if request.Preview {
+			preview, err := api.eventHandler.PreviewBulkDeletion(r.Context(), request, token.GetUserId())
+			if errors.Is(err, ctrl.ErrInvalidBulkDeletion) {
+				http.Error(res, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				http.Error(res, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			res.Header().Set("Content-Type", "application/json; charset=utf-8")
+			json.NewEncoder(res).Encode(preview)
+			return
+		}
+		batch, err := api.eventHandler.BulkDelete(r.Context(), request, ctrl.Requester{Id: token.GetUserId(), Admin: true})
+		if errors.Is(err, ctrl.ErrInvalidBulkDeletion) {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		res.Header().Set("Location", "/admin/users/bulk-delete/"+url.PathEscape(batch.Id))
+		res.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(res).Encode(batch)
+	})
+}
+
+// getDeletionBatch godoc
+// @Summary      get bulk deletion progress
+// @Description  get the aggregated states of the deletions of a bulk deletion; requires admin rights
+// @Tags         deletion
+// @Security Bearer
+// @Param        id path string true "batch ID"
+// @Produce      json
+// @Success      200 {object} ctrl.DeletionBatchProgress
+// @Failure      400
+// @Failure      403
+// @Failure      404
+// @Failure      500
+// @Router       /admin/users/bulk-delete/{id} [get]
+func (api *api) getDeletionBatch(router *httprouter.Router) {
+	router.GET("/admin/users/bulk-delete/:id", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if _, ok := requireAdmin(res, r); !ok {
+			return
+		}
+		progress, err := api.eventHandler.GetDeletionBatchProgress(ps.ByName("id"))
+		if errors.Is(err, ctrl.ErrDeletionBatchNotFound) {
+			http.Error(res, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(res).Encode(progress)
+	})
+}
+
+// listInactiveUsers godoc
+// @Summary      list inactive users
+// @Description  lists the users flagged as inactive by the inactive user check, with the time after which their deletion is requested; requires admin rights
+// @Tags         user, deletion
+// @Security Bearer
+// @Produce      json
+// @Success      200 {array} ctrl.InactiveUser
+// @Failure      400
+// @Failure      403
+// @Failure      500
+// @Router       /admin/inactive-users [get]
+func (api *api) listInactiveUsers(router *httprouter.Router) {
+	router.GET("/admin/inactive-users", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if _, ok := requireAdmin(res, r); !ok {
+			return
+		}
+		users, err := api.eventHandler.ListInactiveUsers()
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(res).Encode(users)
+	})
+}
+
+// getInactiveUserReport godoc
+// @Summary      get inactive user report
+// @Description  get the result of the last inactive user check, including dry runs; requires admin rights
+// @Tags         user, deletion
+// @Security Bearer
+// @Produce      json
+// @Success      200 {object} ctrl.InactiveUserReport
+// @Failure      400
+// @Failure      403
+// @Failure      404 "no finished check"
+// @Router       /admin/inactive-users/report [get]
+func (api *api) getInactiveUserReport(router *httprouter.Router) {
+	router.GET("/admin/inactive-users/report", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if _, ok := requireAdmin(res, r); !ok {
+			return
+		}
+		report := api.eventHandler.GetInactiveUserReport()
+		if report == nil {
+			http.Error(res, "no finished inactive user check", http.StatusNotFound)
+			return
+		}
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(res).Encode(report)
+	})
+}
+
+// scanOrphans godoc
+// @Summary      scan for orphaned resources
+// @Description  lists per service the owners of resources, which do not exist in keycloak (e.g. users deleted in the keycloak admin console); with enqueue=true a forced deletion is requested for every orphaned owner; requires admin rights
+// @Tags         user, deletion
+// @Security Bearer
+// @Param        enqueue query bool false "enqueue cleanups of the orphaned owners"
+// @Produce      json
+// @Success      200 {object} ctrl.OrphanScanReport
+// @Failure      400
+// @Failure      403
+// @Failure      500
+// @Router       /admin/orphans/scan [post]
+func (api *api) scanOrphans(router *httprouter.Router) {
+	router.POST("/admin/orphans/scan", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		token, ok := requireAdmin(res, r)
+		if !ok {
+			return
+		}
+		enqueue := false
+		if value := r.URL.Query().Get("enqueue"); value != "" {
+			var err error
+			enqueue, err = strconv.ParseBool(value)
+			if err != nil {
+				http.Error(res, "invalid enqueue: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		report, err := api.eventHandler.ScanOrphans(r.Context(), enqueue, ctrl.Requester{Id: token.GetUserId(), Admin: true})
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(res).Encode(report)
+	})
+}
+
+// getOrphanScanReport godoc
+// @Summary      get orphan scan report
+// @Description  get the result of the last orphan scan, triggered by an admin or scheduled; requires admin rights
+// @Tags         user, deletion
+// @Security Bearer
+// @Produce      json
+// @Success      200 {object} ctrl.OrphanScanReport
+// @Failure      400
+// @Failure      403
+// @Failure      404 "no finished scan"
+// @Router       /admin/orphans [get]
+func (api *api) getOrphanScanReport(router *httprouter.Router) {
+	router.GET("/admin/orphans", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if _, ok := requireAdmin(res, r); !ok {
+			return
+		}
+		report := api.eventHandler.GetOrphanScanReport()
+		if report == nil {
+			http.Error(res, "no finished orphan scan", http.StatusNotFound)
+			return
+		}
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(res).Encode(report)
+	})
+}
+
+// listDeletionAudit godoc
+// @Summary      list deletion audit log
+// @Description  lists the recorded delete and transfer requests with requester and per service outcome; requires admin rights
+// @Tags         deletion, audit
+// @Security Bearer
+// @Param        user query string false "deleted user ID"
+// @Param        requester query string false "requesting user ID"
+// @Param        from query string false "earliest request time (RFC3339)"
+// @Param        to query string false "latest request time (RFC3339)"
+// @Produce      json
+// @Success      200 {array} ctrl.AuditEntry
+// @Failure      400
+// @Failure      403
+// @Failure      500
+// @Router       /admin/audit/deletions [get]
+func (api *api) listDeletionAudit(router *httprouter.Router) {
+	router.GET("/admin/audit/deletions", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if _, ok := requireAdmin(res, r); !ok {
+			return
+		}
+		query := r.URL.Query()
+		filter := ctrl.AuditFilter{
+			UserId:      query.Get("user"),
+			RequesterId: query.Get("requester"),
+		}
+		var err error
+		for param, value := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+			if query.Get(param) == "" {
+				continue
+			}
+			*value, err = time.Parse(time.RFC3339, query.Get(param))
+			if err != nil {
+				http.Error(res, "invalid "+param+": "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		entries, err := api.eventHandler.ListAuditEntries(filter)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(res).Encode(entries)
+	})
+}
+
+// listDeadLetters godoc
+// @Summary      list dead letters
+// @Description  list the user commands, which could not be handled and were moved to the dead-letter topic; requires admin rights
+// @Tags         dead-letters
+// @Security Bearer
+// @Produce      json
+// @Success      200 {array} ctrl.DeadLetter
+// @Failure      400
+// @Failure      403
+// @Failure      404 "no dead-letter topic configured"
+// @Failure      500
+// @Router       /admin/dead-letters [get]
+func (api *api) listDeadLetters(router *httprouter.Router) {
+	router.GET("/admin/dead-letters", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if _, ok := requireAdmin(res, r); !ok {
+			return
+		}
+		letters, err := api.eventHandler.ListDeadLetters()
+		if errors.Is(err, ctrl.ErrDeadLettersDisabled) {
+			http.Error(res, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(res).Encode(letters)
+	})
+}
+
+type ReplayRequest struct {
+	Ids []string `json:"ids"`
+}
+
+// replayDeadLetters godoc
+// @Summary      replay dead letters
+// @Description  publishes dead-lettered user commands to the user topic again and removes them from the dead-letter list; replays all dead letters if no ids are given; requires admin rights
+// @Tags         dead-letters
+// @Security Bearer
+// @Param        message body ReplayRequest false "dead letter ids"
+// @Produce      json
+// @Success      200 {array} ctrl.DeadLetter "replayed dead letters"
+// @Failure      400
+// @Failure      403
+// @Failure      404 "no dead-letter topic configured"
+// @Failure      500
+// @Router       /admin/dead-letters/replay [post]
+func (api *api) replayDeadLetters(router *httprouter.Router) {
+	router.POST("/admin/dead-letters/replay", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if _, ok := requireAdmin(res, r); !ok {
+			return
+		}
+		replay := ReplayRequest{}
+		err := json.NewDecoder(r.Body).Decode(&replay)
+		if err != nil && !errors.Is(err, io.EOF) {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		letters, err := api.eventHandler.ReplayDeadLetters(replay.Ids)
+		if errors.Is(err, ctrl.ErrDeadLettersDisabled) {
+			http.Error(res, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(res).Encode(letters)
+	})
+}
+
+// exportUser godoc
+// @Summary      export own data
+// @Description  starts the export of all data of the requesting user as zip archive of json files with a manifest; the archive is generated asynchronously and can be downloaded until it expires
+// @Tags         user, export
+// @Security Bearer
+// @Produce      json
+// @Success      202 {object} ctrl.DataExport
+// @Header       202 {string} Location "export status resource"
+// @Failure      400
+// @Failure      412
+// @Failure      500
+// @Router       /user/export [post]
+func (api *api) exportUser(router *httprouter.Router) {
+	router.POST("/user/export", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		token, err := GetParsedToken(r)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		api.startDataExport(r.Context(), res, token.GetUserId(), token.GetUserId())
+	})
+}
+
+// exportUserByID godoc
+// @Summary      export user data by ID
+// @Description  starts the export of all data of the user as zip archive of json files with a manifest; the archive is generated asynchronously and can be downloaded until it expires; requires admin rights or a matching user ID
+// @Tags         user, export
+// @Security Bearer
+// @Param        id path string true "user ID"
+// @Produce      json
+// @Success      202 {object} ctrl.DataExport
+// @Header       202 {string} Location "export status resource"
+// @Failure      400
+// @Failure      403
+// @Failure      412
+// @Failure      500
+// @Router       /user/id/{id}/export [post]
+func (api *api) exportUserByID(router *httprouter.Router) {
+	router.POST("/user/id/:id/export", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		id := ps.ByName("id")
+		token, ok := requireSelfOrAdmin(res, r, id)
+		if !ok {
+			return
+		}
+		api.startDataExport(r.Context(), res, id, token.GetUserId())
+	})
+}
+
+func (api *api) startDataExport(ctx context.Context, res http.ResponseWriter, userId string, requesterId string) {
+	export, err := api.eventHandler.RequestDataExport(ctx, userId, requesterId)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusPreconditionFailed)
+		return
+	}
+	res.Header().Set("Content-Type", "application/json; charset=utf-8")
+	res.Header().Set("Location", "/user/exports/"+url.PathEscape(export.Id))
+	res.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(res).Encode(export)
+}
+
+// getDataExport returns the export, if the token user is the exported user, the requester or an admin
+func (api *api) getDataExport(res http.ResponseWriter, r *http.Request, id string) (export ctrl.DataExport, ok bool) {
+	token, err := GetParsedToken(r)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return export, false
+	}
+	export, exists, err := api.eventHandler.GetDataExport(id)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusInternalServerError)
+		return export, false
+	}
+	if !exists {
+		http.Error(res, ctrl.ErrDataExportNotFound.Error(), http.StatusNotFound)
+		return export, false
+	}
+	if token.GetUserId() != export.UserId && token.GetUserId() != export.RequesterId && !token.IsAdmin() {
+		http.Error(res, "access denied", http.StatusForbidden)
+		return export, false
+	}
+	return export, true
+}
+
+// getDataExportByID godoc
+// @Summary      get export status
+// @Description  get the state of a data export; requires admin rights or to be the exported or requesting user
+// @Tags         user, export
+// @Security Bearer
+// @Param        id path string true "export ID"
+// @Produce      json
+// @Success      200 {object} ctrl.DataExport
+// @Failure      400
+// @Failure      403
+// @Failure      404
+// @Failure      500
+// @Router       /user/exports/{id} [get]
+func (api *api) getDataExportByID(router *httprouter.Router) {
+	router.GET("/user/exports/:id", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		export, ok := api.getDataExport(res, r, ps.ByName("id"))
+		if !ok {
+			return
+		}
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(res).Encode(export)
+	})
+}
+
+// downloadDataExport godoc
+// @Summary      download export
+// @Description  download the zip archive of a finished data export; requires admin rights or to be the exported or requesting user
+// @Tags         user, export
+// @Security Bearer
+// @Param        id path string true "export ID"
+// @Produce      application/zip
+// @Success      200 {file} file
+// @Failure      400
+// @Failure      403
+// @Failure      404 "unknown, unfinished or expired export"
+// @Failure      500
+// @Router       /user/exports/{id}/download [get]
+func (api *api) downloadDataExport(router *httprouter.Router) {
+	router.GET("/user/exports/:id/download", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		export, ok := api.getDataExport(res, r, ps.ByName("id"))
+		if !ok {
+			return
+		}
+		location, err := api.eventHandler.GetDataExportLocation(export)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusNotFound)
+			return
+		}
+		file, err := os.Open(location)
+		if errors.Is(err, os.ErrNotExist) {
+			http.Error(res, ctrl.ErrDataExportNotFound.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer file.Close()
+		res.Header().Set("Content-Type", "application/zip")
+		res.Header().Set("Content-Disposition", `attachment; filename="export-`+export.Id+`.zip"`)
+		http.ServeContent(res, r, "", *export.Finished, file)
+	})
+}
+
 // getUsernameByID godoc
 // @Summary      get username
 // @Description  get username by providing a user ID
@@ -190,7 +1038,7 @@ func (api *api) deleteUser(router *httprouter.Router) {
 func (api *api) getUsernameByID(router *httprouter.Router) {
 	router.GET("/user/id/:id/name", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
 		id := ps.ByName("id")
-		user, err := ctrl.GetUserById(id, api.conf)
+		user, err := ctrl.GetUserById(r.Context(), id, api.conf)
 		if err != nil {
 			http.Error(res, err.Error(), http.StatusInternalServerError)
 			return
@@ -200,15 +1048,60 @@ func (api *api) getUsernameByID(router *httprouter.Router) {
 	})
 }
 
+// getUsernamesByIDs godoc
+// @Summary      get usernames
+// @Description  get the usernames of a list of user IDs; unknown IDs are mapped to null, IDs whose lookup failed are listed in failed with the error
+// @Description  the number of IDs is limited by KeycloakLookupMaxIds; fails only if keycloak is unreachable
+// @Tags         user
+// @Security Bearer
+// @Accept       json
+// @Param        ids body []string true "user IDs"
+// @Produce      json
+// @Success      200 {object} ctrl.UsernameLookup
+// @Failure      400
+// @Failure      500
+// @Router       /user/names [post]
+func (api *api) getUsernamesByIDs(router *httprouter.Router) {
+	router.POST("/user/names", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		ids := []string{}
+		err := json.NewDecoder(r.Body).Decode(&ids)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result, err := ctrl.GetUsernames(r.Context(), ids, api.conf)
+		if errors.Is(err, ctrl.ErrLookupTooLarge) {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(res).Encode(result)
+	})
+}
+
 // getUsers godoc
 // @Summary      get users
-// @Description  parses provided jwt and lists all users if admin or only lists users from groups the calling user is a member of
+// @Description  parses provided jwt and lists all users if admin or only lists users from groups the calling user is a member of; attributes are filtered by their configured visibility
+// @Description  the result is ordered by username and paged by limit and offset; X-Total-Count contains the number of matching users and Link the first, prev, next and last page
+// @Description  non-admins in several groups, or with search or attribute filters, are paged in memory: keycloak can neither page the union of groups nor search group members, so every member of the groups is requested
 // @Tags         user
 // @Security Bearer
 // @Param        excludeCaller query bool false "if true exclude calling user from result"
+// @Param        limit query int false "max number of users; 0 or missing lists all users"
+// @Param        offset query int false "number of skipped users"
+// @Param        search query string false "keycloak search in username, email, first and last name"
+// @Param        sort query string false "username (default) or -username"
+// @Param        attr.key query string false "attribute filter, e.g. attr.locale=de; every attribute has to match"
 // @Produce      json
 // @Success      200 {array} ctrl.User
+// @Header       200 {integer} X-Total-Count "number of matching users"
+// @Header       200 {string} Link "first, prev, next and last page, if limit is set"
 // @Failure      400
+// @Failure      403
 // @Failure      500
 // @Router       /user-list [get]
 func (api *api) getUsers(router *httprouter.Router) {
@@ -218,30 +1111,190 @@ func (api *api) getUsers(router *httprouter.Router) {
 			http.Error(res, err.Error(), http.StatusBadRequest)
 			return
 		}
-		excludeID := ""
+		options, err := parseUserListOptions(r.URL.Query())
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
 		if excludeCaller := r.URL.Query().Get("excludeCaller"); excludeCaller == "true" {
-			excludeID = token.GetUserId()
+			options.ExcludeId = token.GetUserId()
 		}
 		var users []ctrl.User
+		var total int
 		if token.IsAdmin() {
-			users, err = ctrl.GetUsers(excludeID, api.conf)
+			users, total, err = ctrl.ListUsers(r.Context(), options, api.conf)
 			if err != nil {
 				http.Error(res, err.Error(), http.StatusInternalServerError)
 				return
 			}
 		} else {
-			groups, err := ctrl.GetUsersGroups(token.GetUserId(), api.conf)
+			for attribute := range options.Attributes {
+				//filters on hidden attributes would reveal their values
+				if !ctrl.IsAttributeVisible(api.conf, attribute, ctrl.RelationGroupMember) {
+					http.Error(res, "filter on attribute "+attribute+" not permitted", http.StatusForbidden)
+					return
+				}
+			}
+			groups, err := ctrl.GetUsersGroups(r.Context(), token.GetUserId(), api.conf)
 			if err != nil {
 				http.Error(res, err.Error(), http.StatusInternalServerError)
 				return
 			}
-			users, err = ctrl.GetGroupMembersCombined(groups, excludeID, api.conf)
+			users, total, err = ctrl.ListGroupMembers(r.Context(), groups, options, api.conf)
 			if err != nil {
 				http.Error(res, err.Error(), http.StatusInternalServerError)
 				return
 			}
 		}
+		for i, user := range users {
+			users[i] = ctrl.FilterUserAttributes(user, listRelation(token, user), api.conf)
+		}
+		setPaginationHeaders(res, r.URL, options, total)
+		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(res).Encode(users)
+	})
+}
+
+func parseUserListOptions(query url.Values) (options ctrl.UserListOptions, err error) {
+	if limit := query.Get("limit"); limit != "" {
+		options.Limit, err = strconv.Atoi(limit)
+		if err != nil {
+			return options, errors.New("invalid limit: " + err.Error())
+		}
+	}
+	if offset := query.Get("offset"); offset != "" {
+		options.Offset, err = strconv.Atoi(offset)
+		if err != nil {
+			return options, errors.New("invalid offset: " + err.Error())
+		}
+	}
+	options.Search = query.Get("search")
+	options.Sort = query.Get("sort")
+	options.Attributes = parseAttributeFilters(query)
+	return options, ctrl.ValidateUserListOptions(options)
+}
+
+// parseAttributeFilters returns the attr.<name>=<value> query parameters by attribute name
+func parseAttributeFilters(query url.Values) (attributes map[string]string) {
+	for key, values := range query {
+		if attribute, ok := strings.CutPrefix(key, "attr."); ok && attribute != "" && len(values) > 0 {
+			if attributes == nil {
+				attributes = map[string]string{}
+			}
+			attributes[attribute] = values[0]
+		}
+	}
+	return attributes
+}
+
+// setPaginationHeaders sets X-Total-Count and, for paged requests, the Link header with first, prev, next and last page
+func setPaginationHeaders(res http.ResponseWriter, requestUrl *url.URL, options ctrl.UserListOptions, total int) {
+	res.Header().Set("X-Total-Count", strconv.Itoa(total))
+	if options.Limit == 0 {
+		return
+	}
+	pageLink := func(offset int, rel string) string {
+		query := requestUrl.Query()
+		query.Set("limit", strconv.Itoa(options.Limit))
+		query.Set("offset", strconv.Itoa(offset))
+		return "<" + requestUrl.Path + "?" + query.Encode() + ">; rel=\"" + rel + "\""
+	}
+	last := 0
+	if total > 0 {
+		last = (total - 1) / options.Limit * options.Limit
+	}
+	links := []string{pageLink(0, "first")}
+	if options.Offset > 0 {
+		links = append(links, pageLink(max(options.Offset-options.Limit, 0), "prev"))
+	}
+	if options.Offset+options.Limit < total {
+		links = append(links, pageLink(options.Offset+options.Limit, "next"))
+	}
+	links = append(links, pageLink(last, "last"))
+	res.Header().Set("Link", strings.Join(links, ", "))
+}
+
+// listRelation is the relation of the caller to a user of /user-list or /user/search, which only list members of the callers groups to non-admins
+func listRelation(token Token, user ctrl.User) ctrl.UserRelation {
+	switch {
+	case token.IsAdmin():
+		return ctrl.RelationAdmin
+	case token.GetUserId() == user.Id:
+		return ctrl.RelationSelf
+	default:
+		return ctrl.RelationGroupMember
+	}
+}
+
+// searchUsers godoc
+// @Summary      search users
+// @Description  lists users matching all given criteria, ordered by username; non-admins only find members of their groups and attributes are filtered by their configured visibility
+// @Description  an exact search matching several users is answered with 409
+// @Tags         user
+// @Security Bearer
+// @Param        username query string false "username"
+// @Param        email query string false "email"
+// @Param        attr.key query string false "attribute filter, e.g. attr.locale=de; every attribute has to match"
+// @Param        exact query bool false "username and email have to be equal instead of containing the value"
+// @Produce      json
+// @Success      200 {array} ctrl.User
+// @Failure      400
+// @Failure      403
+// @Failure      409 {array} ctrl.User "ambiguous exact match; contains the matching users"
+// @Failure      500
+// @Router       /user/search [get]
+func (api *api) searchUsers(router *httprouter.Router) {
+	router.GET("/user/search", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		token, err := GetParsedToken(r)
+		if err != nil {
+			http.Error(res, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query := r.URL.Query()
+		search := ctrl.UserSearch{
+			Username:   query.Get("username"),
+			Email:      query.Get("email"),
+			Exact:      query.Get("exact") == "true",
+			Attributes: parseAttributeFilters(query),
+		}
+		if search.Username == "" && search.Email == "" && len(search.Attributes) == 0 {
+			http.Error(res, "missing username, email or attribute", http.StatusBadRequest)
+			return
+		}
+		var members map[string]bool //nil for admins, who find every user
+		if !token.IsAdmin() {
+			for attribute := range search.Attributes {
+				//searches by hidden attributes would reveal their values
+				if !ctrl.IsAttributeVisible(api.conf, attribute, ctrl.RelationGroupMember) {
+					http.Error(res, "search by attribute "+attribute+" not permitted", http.StatusForbidden)
+					return
+				}
+			}
+			groups, err := ctrl.GetUsersGroups(r.Context(), token.GetUserId(), api.conf)
+			if err != nil {
+				http.Error(res, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			members, err = ctrl.GetGroupMemberIds(r.Context(), groups, api.conf)
+			if err != nil {
+				http.Error(res, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+		users, err := ctrl.SearchUsers(r.Context(), search, members, api.conf)
+		status := http.StatusOK
+		switch {
+		case errors.Is(err, ctrl.ErrAmbiguousUser):
+			status = http.StatusConflict
+		case err != nil:
+			http.Error(res, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for i, user := range users {
+			users[i] = ctrl.FilterUserAttributes(user, listRelation(token, user), api.conf)
+		}
 		res.Header().Set("Content-Type", "application/json; charset=utf-8")
+		res.WriteHeader(status)
 		json.NewEncoder(res).Encode(users)
 	})
 }
@@ -263,7 +1316,7 @@ func (api *api) getSessions(router *httprouter.Router) {
 			http.Error(res, err.Error(), http.StatusBadRequest)
 			return
 		}
-		token, err := ctrl.EnsureAccess(api.conf)
+		token, err := ctrl.EnsureAccess(r.Context(), api.conf)
 		if err != nil {
 			http.Error(res, err.Error(), http.StatusInternalServerError)
 			return
@@ -17,6 +17,7 @@
 package api
 
 import (
+	"encoding/json"
 	"errors"
 	"github.com/golang-jwt/jwt"
 	"net/http"
@@ -27,7 +28,11 @@ func GetAuthToken(req *http.Request) string {
 	return req.Header.Get("Authorization")
 }
 
+// GetParsedToken returns the claims verified by the AuthMiddleware; without verification (e.g. in gateway mode) the token is only parsed
 func GetParsedToken(req *http.Request) (token Token, err error) {
+	if claims, ok := req.Context().Value(tokenContextKey{}).(Token); ok {
+		return claims, nil
+	}
 	return parse(GetAuthToken(req))
 }
 
@@ -35,6 +40,26 @@ type Token struct {
 	Token       string              `json:"-"`
 	Sub         string              `json:"sub,omitempty"`
 	RealmAccess map[string][]string `json:"realm_access,omitempty"`
+	Exp         int64               `json:"exp,omitempty"`
+	Nbf         int64               `json:"nbf,omitempty"`
+	Iss         string              `json:"iss,omitempty"`
+	Aud         Audience            `json:"aud,omitempty"`
+	Azp         string              `json:"azp,omitempty"`
+}
+
+// Audience is a single string or a list of strings in tokens
+type Audience []string
+
+func (this *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if json.Unmarshal(data, &single) == nil {
+		*this = Audience{single}
+		return nil
+	}
+	var list []string
+	err := json.Unmarshal(data, &list)
+	*this = list
+	return err
 }
 
 func (this *Token) String() string {
@@ -0,0 +1,211 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/ctrl"
+	"github.com/julienschmidt/httprouter"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// AuthErrorResponse is the body of requests denied by the AuthMiddleware or the AuthorizationMiddleware
+type AuthErrorResponse struct {
+	Status int    `json:"status"`
+	Error  string `json:"error"`
+}
+
+func writeAuthError(res http.ResponseWriter, status int, message string) {
+	res.Header().Set("Content-Type", "application/json; charset=utf-8")
+	res.WriteHeader(status)
+	json.NewEncoder(res).Encode(AuthErrorResponse{Status: status, Error: message})
+}
+
+// requireSelfOrAdmin repeats the self-or-admin check of the shipped AuthorizationRules in handlers of routes, which act on a user.
+// Deletions and personal data of other users stay protected, if a rule is misconfigured (e.g. a catch-all rule without roles).
+func requireSelfOrAdmin(res http.ResponseWriter, r *http.Request, id string) (token Token, ok bool) {
+	token, err := GetParsedToken(r)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return token, false
+	}
+	if token.GetUserId() != id && !token.IsAdmin() {
+		writeAuthError(res, http.StatusForbidden, "access denied")
+		return token, false
+	}
+	return token, true
+}
+
+// requireAdmin is the defensive check of admin routes; see requireSelfOrAdmin
+func requireAdmin(res http.ResponseWriter, r *http.Request) (token Token, ok bool) {
+	token, err := GetParsedToken(r)
+	if err != nil {
+		http.Error(res, err.Error(), http.StatusBadRequest)
+		return token, false
+	}
+	if !token.IsAdmin() {
+		writeAuthError(res, http.StatusForbidden, "access denied")
+		return token, false
+	}
+	return token, true
+}
+
+// NewAuthorizationMiddleware enforces conf.AuthorizationRules in front of the router. The first rule matching method and path
+// of a request decides; requests to routes of the router without matching rule are denied.
+func NewAuthorizationMiddleware(conf configuration.Config, router *httprouter.Router) (*AuthorizationMiddleware, error) {
+	for _, rule := range conf.AuthorizationRules {
+		err := validateAuthorizationRule(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid authorization rule %v %v: %w", rule.Method, rule.Route, err)
+		}
+	}
+	return &AuthorizationMiddleware{conf: conf, router: router}, nil
+}
+
+type AuthorizationMiddleware struct {
+	conf   configuration.Config
+	router *httprouter.Router
+}
+
+func (this *AuthorizationMiddleware) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	rule, params, found := this.findRule(req.Method, req.URL.Path)
+	if !found {
+		if handle, _, _ := this.router.Lookup(req.Method, req.URL.Path); handle != nil {
+			writeAuthError(res, http.StatusForbidden, "access denied: no authorization rule")
+			return
+		}
+		this.router.ServeHTTP(res, req) //unknown routes are answered by the router
+		return
+	}
+	if rule.Public {
+		this.router.ServeHTTP(res, req)
+		return
+	}
+	if GetAuthToken(req) == "" {
+		writeAuthError(res, http.StatusUnauthorized, "missing token")
+		return
+	}
+	token, err := GetParsedToken(req)
+	if err != nil {
+		writeAuthError(res, http.StatusUnauthorized, "invalid token: "+err.Error())
+		return
+	}
+	granted, err := this.isGranted(req, rule, params, token)
+	if err != nil {
+		log.Println("ERROR: unable to check authorization", err)
+		writeAuthError(res, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if !granted {
+		writeAuthError(res, http.StatusForbidden, "access denied")
+		return
+	}
+	this.router.ServeHTTP(res, req)
+}
+
+func (this *AuthorizationMiddleware) isGranted(req *http.Request, rule configuration.AuthorizationRule, params map[string]string, token Token) (bool, error) {
+	if len(rule.Roles) == 0 && rule.Self == "" && len(rule.Groups) == 0 {
+		return true, nil
+	}
+	for _, role := range rule.Roles {
+		if contains(token.RealmAccess["roles"], role) {
+			return true, nil
+		}
+	}
+	if rule.Self != "" && params[rule.Self] == token.GetUserId() {
+		return true, nil
+	}
+	if len(rule.Groups) > 0 {
+		groups, err := ctrl.GetUsersGroups(req.Context(), token.GetUserId(), this.conf)
+		if err != nil {
+			return false, err
+		}
+		for _, group := range groups {
+			if slices.Contains(rule.Groups, group.Name) || slices.Contains(rule.Groups, group.Path) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (this *AuthorizationMiddleware) findRule(method string, path string) (rule configuration.AuthorizationRule, params map[string]string, found bool) {
+	for _, rule = range this.conf.AuthorizationRules {
+		if rule.Method != "*" && rule.Method != method {
+			continue
+		}
+		params, found = matchRoute(rule.Route, path)
+		if found {
+			return rule, params, true
+		}
+	}
+	return rule, nil, false
+}
+
+// matchRoute matches the path against a httprouter pattern with :name segments and a trailing *name catch-all
+func matchRoute(route string, path string) (params map[string]string, ok bool) {
+	routeSegments := strings.Split(strings.TrimPrefix(route, "/"), "/")
+	pathSegments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	params = map[string]string{}
+	for i, segment := range routeSegments {
+		if strings.HasPrefix(segment, "*") {
+			params[segment[1:]] = "/" + strings.Join(pathSegments[min(i, len(pathSegments)):], "/")
+			return params, true
+		}
+		if i >= len(pathSegments) {
+			return nil, false
+		}
+		switch {
+		case strings.HasPrefix(segment, ":") && pathSegments[i] != "":
+			params[segment[1:]] = pathSegments[i]
+		case segment != pathSegments[i]:
+			return nil, false
+		}
+	}
+	return params, len(routeSegments) == len(pathSegments)
+}
+
+func validateAuthorizationRule(rule configuration.AuthorizationRule) error {
+	if rule.Method == "" {
+		return fmt.Errorf("missing method")
+	}
+	if rule.Method != "*" && rule.Method != strings.ToUpper(rule.Method) {
+		return fmt.Errorf("method has to be uppercase or *")
+	}
+	if !strings.HasPrefix(rule.Route, "/") {
+		return fmt.Errorf("route has to start with /")
+	}
+	segments := strings.Split(strings.TrimPrefix(rule.Route, "/"), "/")
+	names := []string{}
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, "*") && i != len(segments)-1 {
+			return fmt.Errorf("catch-all has to be the last segment")
+		}
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			names = append(names, segment[1:])
+		}
+	}
+	if rule.Self != "" && !slices.Contains(names, rule.Self) {
+		return fmt.Errorf("unknown self parameter %v", rule.Self)
+	}
+	return nil
+}
@@ -0,0 +1,228 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/golang-jwt/jwt"
+	"log"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AuthModeJwks    = "jwks"    //tokens are verified against the JWKS of the realm
+	AuthModeGateway = "gateway" //tokens are verified by an upstream gateway and only parsed
+)
+
+var ErrUnknownKeyId = errors.New("unknown key id")
+
+// DefaultJwksRefreshInterval is used for an empty or non-positive AuthJwksRefreshInterval, so that tokens with unknown key ids
+// are unable to trigger a JWKS request each
+const DefaultJwksRefreshInterval = 10 * time.Second
+
+type tokenContextKey struct{}
+
+// NewAuthMiddleware verifies the Authorization header of every request according to conf.AuthMode.
+// Requests with invalid tokens are rejected with 401; verified claims are available to handlers by GetParsedToken.
+// Requests without Authorization header are passed on, because public routes (e.g. /doc) need no token.
+func NewAuthMiddleware(conf configuration.Config, handler http.Handler) (*AuthMiddleware, error) {
+	switch conf.AuthMode {
+	case AuthModeGateway:
+		return &AuthMiddleware{handler: handler}, nil
+	case AuthModeJwks:
+		refreshInterval := time.Duration(0)
+		if conf.AuthJwksRefreshInterval != "" {
+			var err error
+			refreshInterval, err = time.ParseDuration(conf.AuthJwksRefreshInterval)
+			if err != nil {
+				return nil, fmt.Errorf("invalid AuthJwksRefreshInterval: %w", err)
+			}
+		}
+		if refreshInterval <= 0 {
+			refreshInterval = DefaultJwksRefreshInterval
+		}
+		leeway := time.Duration(0)
+		if conf.AuthLeeway != "" {
+			var err error
+			leeway, err = time.ParseDuration(conf.AuthLeeway)
+			if err != nil {
+				return nil, fmt.Errorf("invalid AuthLeeway: %w", err)
+			}
+		}
+		jwksUrl := conf.AuthJwksUrl
+		if jwksUrl == "" {
+			jwksUrl = conf.KeycloakUrl + "/auth/realms/" + conf.KeycloakRealm + "/protocol/openid-connect/certs"
+		}
+		issuer := conf.AuthIssuer
+		if issuer == "" {
+			issuer = conf.KeycloakUrl + "/auth/realms/" + conf.KeycloakRealm
+		}
+		return &AuthMiddleware{handler: handler, verifier: &jwksVerifier{
+			url:             jwksUrl,
+			issuer:          issuer,
+			audiences:       conf.AuthAudiences,
+			refreshInterval: refreshInterval,
+			leeway:          leeway,
+			keys:            map[string]*rsa.PublicKey{},
+		}}, nil
+	default:
+		return nil, fmt.Errorf("unknown AuthMode %#v; expected %#v or %#v", conf.AuthMode, AuthModeJwks, AuthModeGateway)
+	}
+}
+
+type AuthMiddleware struct {
+	handler  http.Handler
+	verifier *jwksVerifier //nil in gateway mode
+}
+
+func (this *AuthMiddleware) ServeHTTP(res http.ResponseWriter, req *http.Request) {
+	auth := GetAuthToken(req)
+	if this.verifier == nil || auth == "" {
+		this.handler.ServeHTTP(res, req)
+		return
+	}
+	claims, err := this.verifier.verify(auth)
+	if err != nil {
+		log.Println("WARNING: rejected token", err)
+		writeAuthError(res, http.StatusUnauthorized, "invalid token: "+err.Error())
+		return
+	}
+	this.handler.ServeHTTP(res, req.WithContext(context.WithValue(req.Context(), tokenContextKey{}, claims)))
+}
+
+// jwksVerifier checks signature, validity period, issuer and audience of tokens. Keys are cached by kid;
+// an unknown kid refreshes the keys, at most once per refreshInterval (also if the refresh fails).
+type jwksVerifier struct {
+	url             string
+	issuer          string
+	audiences       []string
+	refreshInterval time.Duration
+	leeway          time.Duration //tolerated clock skew for exp and nbf
+	mux             sync.Mutex
+	keys            map[string]*rsa.PublicKey
+	refreshed       time.Time //last refresh attempt
+}
+
+func (this *jwksVerifier) verify(token string) (claims Token, err error) {
+	orig := token
+	if len(token) > 7 && strings.ToLower(token[:7]) == "bearer " {
+		token = token[7:]
+	}
+	_, err = new(jwt.Parser).ParseWithClaims(token, &claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return this.getKey(kid)
+	})
+	if err != nil {
+		return claims, err
+	}
+	now := time.Now()
+	if claims.Exp == 0 || now.Add(-this.leeway).Unix() > claims.Exp {
+		return claims, errors.New("token is expired")
+	}
+	if claims.Nbf != 0 && now.Add(this.leeway).Unix() < claims.Nbf {
+		return claims, errors.New("token is not valid yet")
+	}
+	if claims.Iss != this.issuer {
+		return claims, fmt.Errorf("unexpected issuer %v", claims.Iss)
+	}
+	if len(this.audiences) > 0 && !slices.ContainsFunc(this.audiences, func(audience string) bool {
+		return claims.Azp == audience || slices.Contains(claims.Aud, audience)
+	}) {
+		return claims, errors.New("unexpected audience")
+	}
+	claims.Token = orig
+	return claims, nil
+}
+
+func (this *jwksVerifier) getKey(kid string) (*rsa.PublicKey, error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if key, ok := this.keys[kid]; ok {
+		return key, nil
+	}
+	if !this.refreshed.IsZero() && time.Since(this.refreshed) < this.refreshInterval {
+		return nil, fmt.Errorf("%w: %v", ErrUnknownKeyId, kid)
+	}
+	this.refreshed = time.Now()
+	keys, err := loadJwks(this.url)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load jwks: %w", err)
+	}
+	this.keys = keys
+	if key, ok := this.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("%w: %v", ErrUnknownKeyId, kid)
+}
+
+type jwks struct {
+	Keys []struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		Use string `json:"use"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+// loadJwks returns the RSA signing keys of the JWKS by kid; other keys (e.g. for encryption) are ignored
+func loadJwks(url string) (keys map[string]*rsa.PublicKey, err error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return keys, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return keys, errors.New(resp.Status)
+	}
+	set := jwks{}
+	err = json.NewDecoder(resp.Body).Decode(&set)
+	if err != nil {
+		return keys, err
+	}
+	keys = map[string]*rsa.PublicKey{}
+	for _, key := range set.Keys {
+		if key.Kty != "RSA" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return keys, fmt.Errorf("invalid modulus of key %v: %w", key.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			return keys, fmt.Errorf("invalid exponent of key %v: %w", key.Kid, err)
+		}
+		keys[key.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys, nil
+}
@@ -35,6 +35,7 @@ func (this *CorsMiddleware) ServeHTTP(res http.ResponseWriter, req *http.Request
 	res.Header().Set("Access-Control-Allow-Headers", "Origin, X-Requested-With, Content-Type, Accept, authorization, Authorization")
 	res.Header().Set("Access-Control-Allow-Credentials", "true")
 	res.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
+	res.Header().Set("Access-Control-Expose-Headers", "Link, X-Total-Count, X-Cancel-Token")
 
 	if req.Method == "OPTIONS" {
 		res.WriteHeader(http.StatusOK)
@@ -33,11 +33,28 @@ type Config struct {
 	KeycloakUrl              string
 	KeycloakRealm            string
 	KeycloakPageMax          int
+	KeycloakLookupWorkers    int    //concurrent keycloak requests of batch lookups (e.g. POST /user/names)
+	KeycloakLookupMaxIds     int    //max number of ids of a batch lookup; 0 uses 100
 	AuthClientId             string `config:"secret"`
 	AuthClientSecret         string `config:"secret"`
 	AuthExpirationTimeBuffer float64
 
+	AuthMode                string   //"jwks" verifies incoming tokens against the JWKS of the realm; "gateway" trusts tokens verified by an upstream gateway
+	AuthJwksUrl             string   //empty derives the certs endpoint of the realm from KeycloakUrl
+	AuthJwksRefreshInterval string   //min duration between JWKS refreshes triggered by unknown key ids; empty or 0 uses 10s
+	AuthLeeway              string   //tolerated clock skew for the exp and nbf claims of verified tokens
+	AuthIssuer              string   //expected iss claim; empty derives it from KeycloakUrl and KeycloakRealm
+	AuthAudiences           []string //accepted aud or azp claims; empty skips the audience check
+
+	AuthorizationRules []AuthorizationRule //requests to routes without matching rule are denied
+
+	AttributeVisibility        map[string]string //visibility ("public", "group-members", "self" or "admin") of user attributes by attribute name
+	AttributeVisibilityDefault string            //visibility of attributes without entry in AttributeVisibility
+
 	UserTopic                string
+	UserDeadLetterTopic      string //receives user commands, which failed after all retries or are unable to be parsed; empty or "-" to disable
+	UserEventsTopic          string //receives the progress of user deletions (UserEventMsg); empty or "-" to disable
+	UserCommandTombstones    bool   //write a tombstone for the key of a completed user command to the compacted UserTopic
 	KafkaBootstrap           string
 	ConsumerGroup            string
 	Debug                    bool
@@ -53,8 +70,46 @@ type Config struct {
 	AnalyticsPipelineUrl     string
 	NotifierUrl              string
 	DeviceRepositoryUrl      string
+	PermissionsV2Url         string //optional; lists the owners of device-repository resources for the orphan scan
+
+	RemoveExportDatabaseMetadataOnUserDelete bool //default of the export-databases resource policy
+
+	ResourcePolicies              map[string]string //policy ("delete", "keep", "transfer-to:<user id>" or "anonymize") by resource type (e.g. "public-operators")
+	ResourcePolicyAnonymousUserId string            //owner of anonymized resources
+
+	HttpCleaners []HttpCleanerConfig
+
+	DeletionParallelServices      int            //number of services cleaned concurrently
+	DeletionServiceWorkers        int            //number of concurrent delete requests per service
+	DeletionServiceWorkerOverride map[string]int //DeletionServiceWorkers by cleaner name
+	DeletionVerify                bool           //re-query every service after its cleanup; the deletion fails if resources remain
+	DeletionVerifyRetries         int            //additional cleanup attempts of a service with remaining resources
+
+	HttpTimeout            string            //timeout of a single request to keycloak or a service; empty or "0" to disable
+	ServiceTimeout         string            //max duration of the work in one service (e.g. the cleanup step of a deletion); empty or "0" to disable
+	ServiceTimeoutOverride map[string]string //ServiceTimeout by cleaner name
+
+	DeletionGracePeriod      string //duration between deletion request and cleanup, during which the user is disabled and the deletion may be canceled; empty or "0" deletes immediately
+	DeletionScheduleInterval string //interval in which scheduled deletions are checked for expired grace periods
+
+	DataExportExpiration string //duration after which the archive of a data export is removed
+
+	OrphanScanInterval string //interval of the scan for resource owners without keycloak user; empty or "0" to disable
+	OrphanScanEnqueue  bool   //scheduled scans enqueue cleanups of orphaned owners
 
-	RemoveExportDatabaseMetadataOnUserDelete bool
+	InactiveUserCheckInterval  string   //interval of the inactive user check; empty or "0" to disable
+	InactiveUserPeriod         string   //duration without login, after which a user is flagged as inactive
+	InactiveUserDeletionDelay  string   //duration between flagging and the deletion request of an inactive user
+	InactiveUserDryRun         bool     //only report inactive users, without notifying or deleting them (true in config.json)
+	InactiveUserNotify         bool     //notify flagged users with the notifier service
+	InactiveUserExcludedGroups []string //group names, whose members are never flagged
+	InactiveUserExcludedRoles  []string //realm role names, whose users are never flagged
+
+	PersistenceType string //"memory" or "file"
+	PersistenceDir  string
+
+	AuditPersistenceType string //store of the deletion audit log: "memory" or "file" (append-only); defaults to PersistenceType
+	AuditRetention       string //duration after which audit entries are removed, counted from their end (or request, if unfinished); empty keeps them forever
 
 	EnableSwaggerUi bool
 
@@ -63,6 +118,31 @@ type Config struct {
 	InitTopics bool
 }
 
+// HttpCleanerConfig describes the cleanup of a service with a paginated list endpoint and a delete endpoint
+type HttpCleanerConfig struct {
+	Name       string
+	ListUrl    string //GET; is requested with the token of the deleted user
+	Pagination string //"none" (default) or "limit-offset"
+	PageSize   int    //default 100
+	ListPath   string //dot separated path to the resource array in the list response; empty if the response is the array
+	IdField    string //default "id"
+	OwnerField string //optional; resources are only deleted if the field matches the user id
+	DeleteUrl  string //single mode: "{id}" is replaced by the escaped resource id; batch mode: receives a json array of ids
+	DeleteMode string //"single" (default) or "batch"
+	OwnersUrl  string //optional; GET with an admin token, returns a json array of the ids of all resource owners; used by the orphan scan
+}
+
+// AuthorizationRule grants access to requests matching Method and Route, if any of Roles, Self or Groups applies.
+// A rule without Roles, Self and Groups grants access to every authenticated user.
+type AuthorizationRule struct {
+	Method string   //http method; "*" matches every method
+	Route  string   //httprouter pattern, e.g. "/user/id/:id" or "/admin/*path"
+	Public bool     //no token required
+	Roles  []string //realm roles granting access
+	Self   string   //name of the path parameter granting access, if it matches the user id of the token
+	Groups []string //names or paths of keycloak groups, whose members are granted access
+}
+
 // loads config from json in location and used environment variables (e.g ZookeeperUrl --> ZOOKEEPER_URL)
 func Load(location string) (config Config, err error) {
 	file, err := os.Open(location)
@@ -108,7 +188,7 @@ func handleEnvironmentVars(config *Config) {
 			if !strings.Contains(fieldConfig, "secret") {
 				fmt.Println("use environment variable: ", envName, " = ", envValue)
 			}
-			if configValue.FieldByName(fieldName).Kind() == reflect.Int64 {
+			if configValue.FieldByName(fieldName).Kind() == reflect.Int64 || configValue.FieldByName(fieldName).Kind() == reflect.Int {
 				i, _ := strconv.ParseInt(envValue, 10, 64)
 				configValue.FieldByName(fieldName).SetInt(i)
 			}
@@ -123,17 +203,21 @@ func handleEnvironmentVars(config *Config) {
 				f, _ := strconv.ParseFloat(envValue, 64)
 				configValue.FieldByName(fieldName).SetFloat(f)
 			}
-			if configValue.FieldByName(fieldName).Kind() == reflect.Slice {
+			if configValue.FieldByName(fieldName).Kind() == reflect.Slice && configValue.FieldByName(fieldName).Type().Elem().Kind() == reflect.String {
 				val := []string{}
 				for _, element := range strings.Split(envValue, ",") {
 					val = append(val, strings.TrimSpace(element))
 				}
 				configValue.FieldByName(fieldName).Set(reflect.ValueOf(val))
+			} else if configValue.FieldByName(fieldName).Kind() == reflect.Slice {
+				setJsonEnvValue(configValue.FieldByName(fieldName), envName, envValue)
 			}
-			if configValue.FieldByName(fieldName).Kind() == reflect.Map {
+			if configValue.FieldByName(fieldName).Kind() == reflect.Map && configValue.FieldByName(fieldName).Type() != reflect.TypeOf(map[string]string{}) {
+				setJsonEnvValue(configValue.FieldByName(fieldName), envName, envValue)
+			} else if configValue.FieldByName(fieldName).Kind() == reflect.Map {
 				value := map[string]string{}
 				for _, element := range strings.Split(envValue, ",") {
-					keyVal := strings.Split(element, ":")
+					keyVal := strings.SplitN(element, ":", 2)
 					key := strings.TrimSpace(keyVal[0])
 					val := strings.TrimSpace(keyVal[1])
 					value[key] = val
@@ -143,3 +227,14 @@ func handleEnvironmentVars(config *Config) {
 		}
 	}
 }
+
+// setJsonEnvValue handles fields of complex types, which are expected as json in the environment variable
+func setJsonEnvValue(field reflect.Value, envName string, envValue string) {
+	val := reflect.New(field.Type())
+	err := json.Unmarshal([]byte(envValue), val.Interface())
+	if err != nil {
+		log.Println("WARNING: unable to parse json environment variable", envName, err)
+		return
+	}
+	field.Set(val.Elem())
+}
@@ -17,52 +17,77 @@
 package ctrl
 
 import (
+	"context"
 	"errors"
-	"github.com/SENERGY-Platform/analytics-pipeline/client"
 	"github.com/SENERGY-Platform/analytics-pipeline/lib"
 	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"net/url"
+	"strconv"
 )
 
-func DeleteAnalyticsFlowEngineUser(token Token, conf configuration.Config) error {
-	if conf.AnalyticsFlowEngineUrl == "" || conf.AnalyticsFlowEngineUrl == "-" {
-		return nil
-	}
+type AnalyticsFlowEngineCleaner struct{}
+
+func (this AnalyticsFlowEngineCleaner) Name() string {
+	return "analytics-flow-engine"
+}
+
+func (this AnalyticsFlowEngineCleaner) Enabled(conf configuration.Config) bool {
+	return isSet(conf.AnalyticsFlowEngineUrl)
+}
+
+func (this AnalyticsFlowEngineCleaner) List(ctx context.Context, token Token, conf configuration.Config) ([]ServiceResources, error) {
+	ids, err := getAnalyticsFlowEngineIds(token, conf)
+	return []ServiceResources{{Resource: "pipelines", Ids: ids}}, err
+}
+
+func (this AnalyticsFlowEngineCleaner) Delete(ctx context.Context, token Token, conf configuration.Config) (deleted []string, err error) {
 	ids, err := getAnalyticsFlowEngineIds(token, conf)
 	if err != nil {
-		return err
-	}
-	for _, id := range ids {
-		err = deleteAnalyticsFlowEngine(token, conf, id)
-		if err != nil {
-			return err
-		}
+		return deleted, err
 	}
-	return nil
+	return forEachParallel(ids, getServiceWorkers(conf, this.Name()), func(id string) error {
+		return deleteAnalyticsFlowEngine(token, conf, id)
+	})
+}
+
+func (this AnalyticsFlowEngineCleaner) Export(ctx context.Context, token Token, conf configuration.Config) (files map[string]interface{}, err error) {
+	pipelines, err := getAnalyticsPipelines(token, conf)
+	return map[string]interface{}{"pipelines": pipelines}, err
+}
+
+func (this AnalyticsFlowEngineCleaner) Verify(ctx context.Context, token Token, conf configuration.Config) (remaining []string, err error) {
+	return verifyByList(ctx, this, token, conf)
 }
 
 func deleteAnalyticsFlowEngine(token Token, conf configuration.Config, id string) error {
-	r, err, code := client.NewClient(conf.AnalyticsPipelineUrl).DeletePipeline(token.Token, token.GetUserId(), id)
-	if err != nil || code >= 300 {
-		return errors.New("deleteAnalyticsFlow(): " + r.Message)
+	resp, err := token.Impersonate().Delete(conf.AnalyticsPipelineUrl+"/pipeline/"+url.PathEscape(id), nil)
+	if err != nil {
+		return errors.New("deleteAnalyticsFlow(): " + err.Error())
 	}
+	resp.Body.Close()
 	return nil
 }
 
 func getAnalyticsFlowEngineIds(token Token, config configuration.Config) (ids []string, err error) {
-	temp := []IdWrapper{}
+	pipelines, err := getAnalyticsPipelines(token, config)
+	for _, p := range pipelines {
+		ids = append(ids, p.Id)
+	}
+	return ids, err
+}
+
+func getAnalyticsPipelines(token Token, config configuration.Config) (result []lib.Pipeline, err error) {
 	limit := 1000
 	first := true
-	c := client.NewClient(config.AnalyticsPipelineUrl)
 	var pipelines lib.PipelinesResponse
 	for first || len(pipelines.Data) == limit {
 		first = false
-		pipelines, err, _ = c.GetPipelines(token.Token, token.GetUserId(), limit, len(temp), "name", true)
+		pipelines = lib.PipelinesResponse{}
+		err = token.Impersonate().GetJSON(config.AnalyticsPipelineUrl+"/pipeline?limit="+strconv.Itoa(limit)+"&offset="+strconv.Itoa(len(result))+"&order=name.asc", &pipelines)
 		if err != nil {
-			return ids, err
-		}
-		for _, p := range pipelines.Data {
-			temp = append(temp, IdWrapper{Id: p.Id})
+			return result, err
 		}
+		result = append(result, pipelines.Data...)
 	}
-	return ids, err
+	return result, err
 }
@@ -17,44 +17,121 @@
 package ctrl
 
 import (
-	"github.com/SENERGY-Platform/analytics-flow-repo-v2/client"
+	"context"
+	"github.com/SENERGY-Platform/analytics-flow-repo-v2/lib"
 	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"maps"
+	"net/url"
+	"slices"
 )
 
-func DeleteAnalyticsFlowRepoUser(token Token, conf configuration.Config) error {
-	if conf.AnalyticsFlowRepoUrl == "" || conf.AnalyticsFlowRepoUrl == "-" {
-		return nil
-	}
-	ids, err := getAnalyticsFlowIds(token, conf)
+type AnalyticsFlowRepoCleaner struct{}
+
+func (this AnalyticsFlowRepoCleaner) Name() string {
+	return "analytics-flow-repo"
+}
+
+func (this AnalyticsFlowRepoCleaner) Enabled(conf configuration.Config) bool {
+	return isSet(conf.AnalyticsFlowRepoUrl)
+}
+
+func (this AnalyticsFlowRepoCleaner) List(ctx context.Context, token Token, conf configuration.Config) ([]ServiceResources, error) {
+	result := ServiceResources{Resource: "flows"}
+	flows, foreignIds, err := getAnalyticsFlowsOfUser(token, conf)
 	if err != nil {
-		return err
+		return []ServiceResources{result}, err
 	}
-	for _, id := range ids {
-		err = deleteAnalyticsFlow(token, conf, id)
-		if err != nil {
-			return err
+	for _, id := range foreignIds {
+		result.Kept = append(result.Kept, KeptResource{Id: id, Reason: "public flow of another user"})
+	}
+	reason, kept := getPolicyKeepReason(conf, PolicyResourceFlows)
+	for _, id := range slices.Sorted(maps.Keys(flows)) {
+		if kept {
+			result.Kept = append(result.Kept, KeptResource{Id: id, Reason: reason})
+		} else {
+			result.Ids = append(result.Ids, id)
 		}
 	}
-	return nil
+	return []ServiceResources{result}, nil
 }
 
-func deleteAnalyticsFlow(token Token, conf configuration.Config, id string) error {
-	_, err := client.NewClient(conf.AnalyticsFlowRepoUrl).DeleteFlow(token.Token, token.GetUserId(), id)
-	return err
+func (this AnalyticsFlowRepoCleaner) Delete(ctx context.Context, token Token, conf configuration.Config) (deleted []string, err error) {
+	deleted, _, _, err = this.DeleteWithPolicies(ctx, token, nil, conf)
+	return deleted, err
+}
+
+// DeleteWithPolicies applies the PolicyResourceFlows policy to all flows of the user, because the flow repository does not expose their visibility
+func (this AnalyticsFlowRepoCleaner) DeleteWithPolicies(ctx context.Context, token Token, copies *CopyLog, conf configuration.Config) (deleted []string, transferred []string, decisions []PolicyDecision, err error) {
+	flows, _, err := getAnalyticsFlowsOfUser(token, conf)
+	if err != nil {
+		return deleted, transferred, decisions, err
+	}
+	return applyResourcePolicies(conf, copies, this.Name(), PolicyResourceFlows, slices.Sorted(maps.Keys(flows)), func(id string) error {
+		return deleteAnalyticsFlow(token, conf, id)
+	}, func(target Token, id string) error {
+		return createAnalyticsFlowCopy(target, conf, flows[id])
+	})
+}
+
+func (this AnalyticsFlowRepoCleaner) Transfer(ctx context.Context, token Token, target Token, copies *CopyLog, conf configuration.Config) (transferred []string, err error) {
+	flows, _, err := getAnalyticsFlowsOfUser(token, conf)
+	if err != nil {
+		return transferred, err
+	}
+	return forEachParallel(slices.Sorted(maps.Keys(flows)), getServiceWorkers(conf, this.Name()), func(id string) error {
+		return moveResource(copies, id, func() error {
+			return createAnalyticsFlowCopy(target, conf, flows[id])
+		}, func() error {
+			return deleteAnalyticsFlow(token, conf, id)
+		})
+	})
+}
+
+// createAnalyticsFlowCopy creates the flow as new flow of the target user
+func createAnalyticsFlowCopy(target Token, conf configuration.Config, flow lib.Flow) error {
+	flow.Id = nil
+	flow.UserId = target.GetUserId()
+	return target.Impersonate().PutJSON(conf.AnalyticsFlowRepoUrl+"/flow/", flow, nil)
+}
+
+func (this AnalyticsFlowRepoCleaner) Export(ctx context.Context, token Token, conf configuration.Config) (files map[string]interface{}, err error) {
+	flows, _, err := getAnalyticsFlowsOfUser(token, conf)
+	ownFlows := []lib.Flow{}
+	for _, id := range slices.Sorted(maps.Keys(flows)) {
+		ownFlows = append(ownFlows, flows[id])
+	}
+	return map[string]interface{}{"flows": ownFlows}, err
 }
 
-func getAnalyticsFlowIds(token Token, config configuration.Config) (ids []string, err error) {
-	ids = []string{}
+func (this AnalyticsFlowRepoCleaner) Verify(ctx context.Context, token Token, conf configuration.Config) (remaining []string, err error) {
+	return verifyByList(ctx, this, token, conf)
+}
 
-	flows, _, err := client.NewClient(config.AnalyticsFlowRepoUrl).GetFlows(token.Token, token.GetUserId())
+func deleteAnalyticsFlow(token Token, conf configuration.Config, id string) error {
+	resp, err := token.Impersonate().Delete(conf.AnalyticsFlowRepoUrl+"/flow/"+url.PathEscape(id)+"/", nil)
 	if err != nil {
-		return ids, err
+		return err
 	}
+	return resp.Body.Close()
+}
 
-	for _, element := range flows.Flows {
-		if element.UserId == token.GetUserId() { //filter public flows of other users
-			ids = append(ids, element.Id.Hex())
+// getAnalyticsFlowsOfUser returns the flows of the token user by id and the ids of public flows of other users, which are never touched.
+// List, Delete, Transfer and Export use it, so the preview reports exactly what the other operations handle.
+func getAnalyticsFlowsOfUser(token Token, config configuration.Config) (flows map[string]lib.Flow, foreignIds []string, err error) {
+	flows = map[string]lib.Flow{}
+	result := lib.FlowsResponse{}
+	err = token.Impersonate().GetJSON(config.AnalyticsFlowRepoUrl+"/flow", &result)
+	if err != nil {
+		return flows, foreignIds, err
+	}
+	for _, element := range result.Flows {
+		switch {
+		case element.Id == nil:
+		case element.UserId != token.GetUserId(): //filter public flows of other users
+			foreignIds = append(foreignIds, element.Id.Hex())
+		default:
+			flows[element.Id.Hex()] = element
 		}
 	}
-	return ids, err
+	return flows, foreignIds, nil
 }
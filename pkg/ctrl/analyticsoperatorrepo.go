@@ -17,24 +17,75 @@
 package ctrl
 
 import (
+	"context"
 	"errors"
 	"github.com/SENERGY-Platform/user-management/pkg/configuration"
 	"io"
 )
 
-func DeleteAnalyticsOperatorRepoUser(token Token, conf configuration.Config) error {
-	if conf.AnalyticsOperatorRepoUrl == "" || conf.AnalyticsOperatorRepoUrl == "-" {
-		return nil
+type AnalyticsOperatorRepoCleaner struct{}
+
+func (this AnalyticsOperatorRepoCleaner) Name() string {
+	return "analytics-operator-repo"
+}
+
+func (this AnalyticsOperatorRepoCleaner) Enabled(conf configuration.Config) bool {
+	return isSet(conf.AnalyticsOperatorRepoUrl)
+}
+
+func (this AnalyticsOperatorRepoCleaner) List(ctx context.Context, token Token, conf configuration.Config) ([]ServiceResources, error) {
+	result := ServiceResources{Resource: "operators"}
+	private, public, foreignIds, err := getAnalyticsOperatorIds(token, conf)
+	if err != nil {
+		return []ServiceResources{result}, err
+	}
+	for _, id := range foreignIds {
+		result.Kept = append(result.Kept, KeptResource{Id: id, Reason: "public operator of another user"})
 	}
-	ids, err := getAnalyticsOperatorIds(token, conf)
+	result.Ids = append(result.Ids, private...)
+	reason, kept := getPolicyKeepReason(conf, PolicyResourcePublicOperators)
+	for _, id := range public {
+		if kept {
+			result.Kept = append(result.Kept, KeptResource{Id: id, Reason: reason})
+		} else {
+			result.Ids = append(result.Ids, id)
+		}
+	}
+	return []ServiceResources{result}, nil
+}
+
+func (this AnalyticsOperatorRepoCleaner) Delete(ctx context.Context, token Token, conf configuration.Config) (deleted []string, err error) {
+	deleted, _, _, err = this.DeleteWithPolicies(ctx, token, nil, conf)
+	return deleted, err
+}
+
+// DeleteWithPolicies removes the private operators of the user and applies the PolicyResourcePublicOperators policy to the public ones
+func (this AnalyticsOperatorRepoCleaner) DeleteWithPolicies(ctx context.Context, token Token, copies *CopyLog, conf configuration.Config) (deleted []string, transferred []string, decisions []PolicyDecision, err error) {
+	private, public, _, err := getAnalyticsOperatorIds(token, conf)
 	if err != nil {
-		return err
+		return deleted, transferred, decisions, err
 	}
-	err = deleteAnalyticsOperator(token, conf, ids)
+	err = deleteAnalyticsOperator(token, conf, private)
 	if err != nil {
-		return err
+		return deleted, transferred, decisions, err
 	}
-	return nil
+	deleted = append(deleted, private...)
+	publicDeleted, transferred, decisions, err := applyResourcePolicies(conf, copies, this.Name(), PolicyResourcePublicOperators, public, func(id string) error {
+		return deleteAnalyticsOperator(token, conf, []string{id})
+	}, func(target Token, id string) error {
+		return copyResource(token, target, conf.AnalyticsOperatorRepoUrl+"/operator", id, "_id", "userId")
+	})
+	return append(deleted, publicDeleted...), transferred, decisions, err
+}
+
+func (this AnalyticsOperatorRepoCleaner) Export(ctx context.Context, token Token, conf configuration.Config) (files map[string]interface{}, err error) {
+	//limit=0 -> mongodb: all elements
+	operators, err := getRawJson(token, conf.AnalyticsOperatorRepoUrl+"/operator?limit=0&offset=0")
+	return map[string]interface{}{"operators": filterRawByOwner(getRawList(operators, "operators"), "userId", token.GetUserId())}, err
+}
+
+func (this AnalyticsOperatorRepoCleaner) Verify(ctx context.Context, token Token, conf configuration.Config) (remaining []string, err error) {
+	return verifyByList(ctx, this, token, conf)
 }
 
 func deleteAnalyticsOperator(token Token, conf configuration.Config, ids []string) error {
@@ -52,19 +103,26 @@ func deleteAnalyticsOperator(token Token, conf configuration.Config, ids []strin
 	return nil
 }
 
-func getAnalyticsOperatorIds(token Token, config configuration.Config) (ids []string, err error) {
+// getAnalyticsOperatorIds returns the ids of the private and public operators of the token user and of public operators of other users,
+// which are never touched. List and DeleteWithPolicies use it, so the preview reports exactly what a deletion handles.
+func getAnalyticsOperatorIds(token Token, config configuration.Config) (private []string, public []string, foreignIds []string, err error) {
 	temp := OperatorList{}
 	//limit=0 -> mongodb: all elements
 	err = token.Impersonate().GetJSON(config.AnalyticsOperatorRepoUrl+"/operator?limit=0&offset=0", &temp)
 	if err != nil {
-		return ids, err
+		return private, public, foreignIds, err
 	}
 	for _, element := range temp.Operators {
-		if element.UserId == token.GetUserId() { //filter public operators of other users
-			ids = append(ids, element.Id)
+		switch {
+		case element.UserId != token.GetUserId(): //filter public operators of other users
+			foreignIds = append(foreignIds, element.Id)
+		case element.Public:
+			public = append(public, element.Id)
+		default:
+			private = append(private, element.Id)
 		}
 	}
-	return ids, err
+	return private, public, foreignIds, nil
 }
 
 type Operator struct {
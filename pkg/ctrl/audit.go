@@ -0,0 +1,257 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctrl
+
+import (
+	"errors"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/store"
+	"github.com/google/uuid"
+	"log"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	AuditSourceApi   = "api"
+	AuditSourceKafka = "kafka"
+)
+
+// AuditCanceled marks audit entries of scheduled deletions, which were canceled in their grace period
+const AuditCanceled DeletionState = "canceled"
+
+// AuditEntry records a delete (or transfer) request and its outcome
+type AuditEntry struct {
+	Id             string         `json:"id"`
+	Source         string         `json:"source"`                 //api or kafka
+	RequesterId    string         `json:"requester_id,omitempty"` //sub of the requesting token; empty for kafka commands without requester
+	RequesterAdmin bool           `json:"requester_admin"`        //the requester used admin rights
+	UserId         string         `json:"user_id"`
+	Command        string         `json:"command"`
+	TargetUserId   string         `json:"target_user_id,omitempty"`
+	State          DeletionState  `json:"state"`
+	Requested      time.Time      `json:"requested"`
+	Started        *time.Time     `json:"started,omitempty"`
+	Finished       *time.Time     `json:"finished,omitempty"`
+	Error          string         `json:"error,omitempty"`
+	Services       []AuditService `json:"services"`
+}
+
+type AuditService struct {
+	Service     string        `json:"service"`
+	State       DeletionState `json:"state"`
+	Deleted     int           `json:"deleted"`
+	Transferred int           `json:"transferred,omitempty"`
+	Orphans     []string      `json:"orphans,omitempty"` //resources found by the verification after the cleanup
+	Error       string        `json:"error,omitempty"`
+}
+
+type AuditStore = store.Store[AuditEntry]
+
+// Requester identifies the user requesting a deletion
+type Requester struct {
+	Id    string
+	Admin bool
+}
+
+// AuditFilter selects audit entries; empty fields match every entry
+type AuditFilter struct {
+	UserId      string
+	RequesterId string
+	From        time.Time
+	To          time.Time
+}
+
+func NewAuditEntry(source string, requester Requester, userId string, targetUserId string) AuditEntry {
+	entry := AuditEntry{
+		Id:             uuid.NewString(),
+		Source:         source,
+		RequesterId:    requester.Id,
+		RequesterAdmin: requester.Admin,
+		UserId:         userId,
+		Command:        CommandDelete,
+		TargetUserId:   targetUserId,
+		State:          DeletionPending,
+		Requested:      time.Now(),
+		Services:       []AuditService{},
+	}
+	if targetUserId != "" {
+		entry.Command = CommandTransfer
+	}
+	return entry
+}
+
+// getCommandAuditEntry returns the audit entry referenced by the command.
+// Commands without audit id (e.g. published by other services) get an id derived from the message time, to update the same entry on retries.
+func getCommandAuditEntry(audits AuditStore, command UserCommandMsg, msgTime time.Time) (entry AuditEntry, err error) {
+	id := command.AuditId
+	if id == "" {
+		id = AuditSourceKafka + ":" + command.Id + ":" + strconv.FormatInt(msgTime.UnixNano(), 10)
+	}
+	entry, exists, err := audits.Get(id)
+	if err != nil || exists {
+		return entry, err
+	}
+	entry = NewAuditEntry(AuditSourceKafka, Requester{Id: command.RequesterId, Admin: command.RequesterAdmin}, command.Id, command.TargetId)
+	entry.Id = id
+	entry.Requested = msgTime
+	return entry, nil
+}
+
+// RunAudited calls run for the command and records the outcome of the deletion job in the audit entry of the command.
+// Failing audit updates are logged and do not fail the command.
+func RunAudited(audits AuditStore, jobs JobStore, command UserCommandMsg, msgTime time.Time, run func() error) error {
+	entry, err := getCommandAuditEntry(audits, command, msgTime)
+	if err != nil {
+		log.Println("ERROR: unable to load audit entry", command.Id, err)
+		return run()
+	}
+	now := time.Now()
+	entry.Started = &now
+	entry.Finished = nil
+	entry.State = DeletionRunning
+	setAuditEntry(audits, entry)
+
+	runErr := run()
+
+	now = time.Now()
+	entry.Finished = &now
+	entry.State = DeletionDone
+	entry.Error = ""
+	if runErr != nil {
+		entry.State = DeletionFailed
+		entry.Error = runErr.Error()
+	}
+	job, exists, err := jobs.Get(command.Id)
+	if err != nil {
+		log.Println("ERROR: unable to load deletion job for audit entry", command.Id, err)
+	}
+	if exists && job.Command == entry.Command {
+		entry.Services = getAuditServices(job)
+	}
+	setAuditEntry(audits, entry)
+	return runErr
+}
+
+func getAuditServices(job DeletionJob) (result []AuditService) {
+	result = []AuditService{}
+	for _, step := range job.Steps {
+		service := AuditService{
+			Service:     step.Service,
+			State:       step.State,
+			Deleted:     step.Deleted,
+			Transferred: step.Transferred,
+			Orphans:     step.Orphans,
+		}
+		if step.State == DeletionFailed {
+			service.Error = step.LastError
+		}
+		result = append(result, service)
+	}
+	return result
+}
+
+// SetAuditState updates the state of an existing audit entry, e.g. to record a canceled deletion
+func SetAuditState(audits AuditStore, id string, state DeletionState) error {
+	entry, exists, err := audits.Get(id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("unknown audit entry: " + id)
+	}
+	entry.State = state
+	if state == AuditCanceled {
+		now := time.Now()
+		entry.Finished = &now
+	}
+	return audits.Set(entry.Id, entry)
+}
+
+func setAuditEntry(audits AuditStore, entry AuditEntry) {
+	err := audits.Set(entry.Id, entry)
+	if err != nil {
+		log.Println("ERROR: unable to store audit entry", entry.Id, err)
+	}
+}
+
+// GetAuditRetention returns the configured AuditRetention; 0 keeps audit entries forever
+func GetAuditRetention(conf configuration.Config) (time.Duration, error) {
+	if conf.AuditRetention == "" {
+		return 0, nil
+	}
+	retention, err := time.ParseDuration(conf.AuditRetention)
+	if err != nil {
+		return 0, err
+	}
+	if retention < 0 {
+		return 0, errors.New("AuditRetention has to be positive")
+	}
+	return retention, nil
+}
+
+// RemoveExpiredAuditEntries removes audit entries, which finished (or, if unfinished, were requested) more than AuditRetention before now
+func RemoveExpiredAuditEntries(conf configuration.Config, audits AuditStore, now time.Time) error {
+	retention, err := GetAuditRetention(conf)
+	if err != nil || retention == 0 {
+		return err
+	}
+	list, err := audits.List()
+	if err != nil {
+		return err
+	}
+	errs := []error{}
+	for _, entry := range list {
+		end := entry.Requested
+		if entry.Finished != nil {
+			end = *entry.Finished
+		}
+		if end.Add(retention).Before(now) {
+			errs = append(errs, audits.Remove(entry.Id))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ListAuditEntries returns the matching entries sorted by request time
+func ListAuditEntries(audits AuditStore, filter AuditFilter) (result []AuditEntry, err error) {
+	list, err := audits.List()
+	if err != nil {
+		return result, err
+	}
+	result = []AuditEntry{}
+	for _, entry := range list {
+		if filter.UserId != "" && entry.UserId != filter.UserId {
+			continue
+		}
+		if filter.RequesterId != "" && entry.RequesterId != filter.RequesterId {
+			continue
+		}
+		if !filter.From.IsZero() && entry.Requested.Before(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && entry.Requested.After(filter.To) {
+			continue
+		}
+		result = append(result, entry)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Requested.Before(result[j].Requested)
+	})
+	return result, nil
+}
@@ -17,6 +17,7 @@
 package ctrl
 
 import (
+	"context"
 	"errors"
 	"github.com/SENERGY-Platform/user-management/pkg/configuration"
 	"io"
@@ -24,37 +25,76 @@ import (
 	"strconv"
 )
 
-func DeleteBrokerExportsUser(token Token, conf configuration.Config) error {
-	if conf.BrokerExportsUrl == "" || conf.BrokerExportsUrl == "-" {
-		return nil
-	}
+type BrokerExportsCleaner struct{}
+
+func (this BrokerExportsCleaner) Name() string {
+	return "broker-exports"
+}
+
+func (this BrokerExportsCleaner) Enabled(conf configuration.Config) bool {
+	return isSet(conf.BrokerExportsUrl)
+}
+
+func (this BrokerExportsCleaner) List(ctx context.Context, token Token, conf configuration.Config) ([]ServiceResources, error) {
+	ids, err := listAllPages(func(limit int, offset int) ([]string, error) {
+		return getBatchOfBrokerExportIds(token, conf, limit, offset)
+	})
+	return []ServiceResources{{Resource: "instances", Ids: ids}}, err
+}
 
+func (this BrokerExportsCleaner) Delete(ctx context.Context, token Token, conf configuration.Config) (deleted []string, err error) {
 	loopLimit := 10000
 	loopCount := 0
 	for {
 		ids, err := getBatchOfBrokerExportIds(token, conf, BatchSize, 0)
 		if err != nil {
-			return err
+			return deleted, err
 		}
 		if len(ids) == 0 {
-			return nil
+			return deleted, nil
 		}
 
-		for _, id := range ids {
-			err = deleteBrokerExport(token, conf, id)
-			if err != nil {
-				return err
-			}
+		done, err := forEachParallel(ids, getServiceWorkers(conf, this.Name()), func(id string) error {
+			return deleteBrokerExport(token, conf, id)
+		})
+		deleted = append(deleted, done...)
+		if err != nil {
+			return deleted, err
 		}
 
 		loopCount = loopCount + 1
 		if loopCount == loopLimit {
-			return errors.New("DeleteBrokerExportUser() reach loop limit")
+			return deleted, errors.New("DeleteBrokerExportUser() reach loop limit")
 		}
 	}
 
 }
 
+func (this BrokerExportsCleaner) Transfer(ctx context.Context, token Token, target Token, copies *CopyLog, conf configuration.Config) (transferred []string, err error) {
+	ids, err := listAllPages(func(limit int, offset int) ([]string, error) {
+		return getBatchOfBrokerExportIds(token, conf, limit, offset)
+	})
+	if err != nil {
+		return transferred, err
+	}
+	return forEachParallel(ids, getServiceWorkers(conf, this.Name()), func(id string) error {
+		return moveResource(copies, id, func() error {
+			return copyResource(token, target, conf.BrokerExportsUrl+"/instances", id, "ID", "UserId")
+		}, func() error {
+			return deleteBrokerExport(token, conf, id)
+		})
+	})
+}
+
+func (this BrokerExportsCleaner) Export(ctx context.Context, token Token, conf configuration.Config) (files map[string]interface{}, err error) {
+	instances, err := getAllRawPages(token, conf.BrokerExportsUrl+"/instances", "instances")
+	return map[string]interface{}{"instances": instances}, err
+}
+
+func (this BrokerExportsCleaner) Verify(ctx context.Context, token Token, conf configuration.Config) (remaining []string, err error) {
+	return verifyByList(ctx, this, token, conf)
+}
+
 func deleteBrokerExport(token Token, conf configuration.Config, id string) error {
 	resp, err := token.Impersonate().Delete(conf.BrokerExportsUrl+"/instances/"+url.QueryEscape(id), nil)
 	if err != nil {
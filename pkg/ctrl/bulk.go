@@ -0,0 +1,174 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctrl
+
+import (
+	"context"
+	"errors"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/store"
+	"github.com/google/uuid"
+	"log"
+	"time"
+)
+
+// BulkDeletionRequest selects the users of a bulk deletion by exactly one of ids, group or attributes
+type BulkDeletionRequest struct {
+	Ids        []string          `json:"ids,omitempty"`
+	GroupId    string            `json:"group_id,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"` //all attributes have to match
+	Preview    bool              `json:"preview,omitempty"`    //only resolve the users, without deleting them
+}
+
+type BulkDeletionPreview struct {
+	Users   []User   `json:"users"`
+	Unknown []string `json:"unknown,omitempty"` //requested ids without keycloak user
+}
+
+// DeletionBatch groups the deletions enqueued by a bulk deletion
+type DeletionBatch struct {
+	Id          string            `json:"id"`
+	RequesterId string            `json:"requester_id"`
+	Created     time.Time         `json:"created"`
+	UserIds     []string          `json:"user_ids"`
+	Errors      map[string]string `json:"errors,omitempty"` //users, which could not be enqueued
+}
+
+type DeletionBatchStore = store.Store[DeletionBatch]
+
+// DeletionBatchProgress aggregates the states of the deletion jobs of a batch
+type DeletionBatchProgress struct {
+	DeletionBatch
+	Total    int                   `json:"total"`
+	States   map[DeletionState]int `json:"states"`
+	Finished bool                  `json:"finished"` //all enqueued deletions are done or failed
+}
+
+var ErrInvalidBulkDeletion = errors.New("exactly one of ids, group_id or attributes is required")
+var ErrDeletionBatchNotFound = errors.New("deletion batch not found")
+
+// ResolveBulkDeletion returns the users selected by the request; the requester is never included
+func ResolveBulkDeletion(ctx context.Context, request BulkDeletionRequest, requesterId string, conf configuration.Config) (result BulkDeletionPreview, err error) {
+	selectors := 0
+	for _, set := range []bool{len(request.Ids) > 0, request.GroupId != "", len(request.Attributes) > 0} {
+		if set {
+			selectors++
+		}
+	}
+	if selectors != 1 {
+		return result, ErrInvalidBulkDeletion
+	}
+	result.Users = []User{}
+	switch {
+	case request.GroupId != "":
+		users, err := GetGroupMembersCombined(ctx, []Group{{ID: request.GroupId}}, requesterId, conf)
+		if err != nil {
+			return result, err
+		}
+		result.Users = append(result.Users, users...)
+	case len(request.Attributes) > 0:
+		users, err := SearchUsersByAttributes(ctx, request.Attributes, requesterId, conf)
+		if err != nil {
+			return result, err
+		}
+		result.Users = append(result.Users, users...)
+	default:
+		known := map[string]bool{}
+		for _, id := range request.Ids {
+			if id == requesterId || known[id] {
+				continue
+			}
+			known[id] = true
+			exists, err := KeycloakUserExists(ctx, id, conf)
+			if err != nil {
+				return result, err
+			}
+			if !exists {
+				result.Unknown = append(result.Unknown, id)
+				continue
+			}
+			user, err := GetUserById(ctx, id, conf)
+			if err != nil {
+				return result, err
+			}
+			result.Users = append(result.Users, user)
+		}
+	}
+	return result, nil
+}
+
+// CreateDeletionBatch calls request for every user and stores the batch; failing requests are recorded in the batch
+func CreateDeletionBatch(users []User, requesterId string, batches DeletionBatchStore, request func(userId string) error) (batch DeletionBatch, err error) {
+	batch = DeletionBatch{
+		Id:          uuid.NewString(),
+		RequesterId: requesterId,
+		Created:     time.Now(),
+		UserIds:     []string{},
+	}
+	for _, user := range users {
+		batch.UserIds = append(batch.UserIds, user.Id)
+	}
+	err = batches.Set(batch.Id, batch)
+	if err != nil {
+		return batch, err
+	}
+	for _, id := range batch.UserIds {
+		err = request(id)
+		if err != nil {
+			log.Println("ERROR: unable to enqueue deletion of batch", batch.Id, id, err)
+			if batch.Errors == nil {
+				batch.Errors = map[string]string{}
+			}
+			batch.Errors[id] = err.Error()
+		}
+	}
+	return batch, batches.Set(batch.Id, batch)
+}
+
+// GetDeletionBatchProgress counts the states of the deletion jobs of the batch; users without job are pending,
+// users which could not be enqueued are failed
+func GetDeletionBatchProgress(batches DeletionBatchStore, jobs JobStore, id string) (progress DeletionBatchProgress, err error) {
+	batch, exists, err := batches.Get(id)
+	if err != nil {
+		return progress, err
+	}
+	if !exists {
+		return progress, ErrDeletionBatchNotFound
+	}
+	progress = DeletionBatchProgress{
+		DeletionBatch: batch,
+		Total:         len(batch.UserIds),
+		States:        map[DeletionState]int{},
+	}
+	for _, userId := range batch.UserIds {
+		state := DeletionPending
+		if _, failed := batch.Errors[userId]; failed {
+			state = DeletionFailed
+		} else {
+			job, exists, err := jobs.Get(userId)
+			if err != nil {
+				return progress, err
+			}
+			if exists {
+				state = job.State
+			}
+		}
+		progress.States[state]++
+	}
+	progress.Finished = progress.States[DeletionDone]+progress.States[DeletionFailed] == progress.Total
+	return progress, nil
+}
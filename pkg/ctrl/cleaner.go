@@ -0,0 +1,305 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctrl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"log"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+)
+
+// ServiceCleaner removes the resources of a user from one downstream service.
+// The user is identified by the token, which is created for the user that is to be deleted.
+// Requests of the token are bound to ctx, which is canceled on shutdown or when the service timeout is exceeded;
+// requests without the token (e.g. with service clients) have to use ctx themselves. Cleaners are called synchronously,
+// so every request has to respect ctx for the caller to stop in time.
+type ServiceCleaner interface {
+	// Name identifies the cleaner in deletion jobs and results; it has to be unique
+	Name() string
+	// Enabled reports if the service is configured
+	Enabled(conf configuration.Config) bool
+	// List returns the resources a deletion would remove or keep, without deleting anything
+	List(ctx context.Context, token Token, conf configuration.Config) ([]ServiceResources, error)
+	// Delete removes the resources of the user and returns the ids of the deleted resources
+	Delete(ctx context.Context, token Token, conf configuration.Config) (deleted []string, err error)
+	// Verify returns the ids of resources that should have been deleted but still exist
+	Verify(ctx context.Context, token Token, conf configuration.Config) (remaining []string, err error)
+}
+
+// ResourceTransferer is implemented by cleaners of services, which are able to hand the resources of a user to another user
+type ResourceTransferer interface {
+	// Transfer moves the resources of the token user to the target user and returns the ids of the transferred resources, as known before the transfer.
+	// Resources recorded in copies are not copied again.
+	Transfer(ctx context.Context, token Token, target Token, copies *CopyLog, conf configuration.Config) (transferred []string, err error)
+}
+
+var cleanersMux sync.Mutex
+
+var cleaners = []ServiceCleaner{
+	DeviceRepositoryCleaner{},
+	WaitingRoomCleaner{},
+	DashboardCleaner{},
+	ProcessSchedulerCleaner{},
+	ImportsCleaner{},
+	BrokerExportsCleaner{},
+	DatabaseExportsCleaner{},
+	ExportDatabasesCleaner{},
+	AnalyticsOperatorRepoCleaner{},
+	AnalyticsFlowRepoCleaner{},
+	AnalyticsFlowEngineCleaner{},
+	NotifierCleaner{},
+}
+
+// RegisterCleaner adds a cleaner, which is executed after the built-in cleaners and before the keycloak user is removed
+func RegisterCleaner(cleaner ServiceCleaner) error {
+	cleanersMux.Lock()
+	defer cleanersMux.Unlock()
+	if slices.Contains(getRegisteredCleanerNames(), cleaner.Name()) {
+		return errors.New("cleaner name already registered: " + cleaner.Name())
+	}
+	cleaners = append(cleaners, cleaner)
+	return nil
+}
+
+// getRegisteredCleanerNames expects the caller to hold cleanersMux
+func getRegisteredCleanerNames() []string {
+	return append(getCleanerNames(cleaners), KeycloakCleaner{}.Name())
+}
+
+// GetCleaners returns the enabled cleaners in execution order: built-in, registered, configured http cleaners and finally the keycloak cleaner
+func GetCleaners(conf configuration.Config) (result []ServiceCleaner) {
+	cleanersMux.Lock()
+	defer cleanersMux.Unlock()
+	for _, cleaner := range cleaners {
+		if cleaner.Enabled(conf) {
+			result = append(result, cleaner)
+		}
+	}
+	for _, config := range conf.HttpCleaners {
+		cleaner := HttpCleaner{Config: config}
+		if cleaner.Enabled(conf) {
+			result = append(result, cleaner)
+		}
+	}
+	return append(result, KeycloakCleaner{})
+}
+
+func getCleanerNames(cleaners []ServiceCleaner) (names []string) {
+	for _, cleaner := range cleaners {
+		names = append(names, cleaner.Name())
+	}
+	return names
+}
+
+// verifyByList is the default Verify implementation: every listed id is a remaining resource
+func verifyByList(ctx context.Context, cleaner ServiceCleaner, token Token, conf configuration.Config) (remaining []string, err error) {
+	resources, err := cleaner.List(ctx, token, conf)
+	if err != nil {
+		return remaining, err
+	}
+	for _, resource := range resources {
+		remaining = append(remaining, resource.Ids...)
+	}
+	return remaining, nil
+}
+
+// getServiceWorkers returns the number of concurrent delete requests allowed for the named cleaner
+func getServiceWorkers(conf configuration.Config, name string) int {
+	if workers, ok := conf.DeletionServiceWorkerOverride[name]; ok && workers > 0 {
+		return workers
+	}
+	if conf.DeletionServiceWorkers > 0 {
+		return conf.DeletionServiceWorkers
+	}
+	return 1
+}
+
+// GetServiceTimeout returns the max duration of the work in the named service; 0 if unlimited
+func GetServiceTimeout(conf configuration.Config, name string) (time.Duration, error) {
+	timeout := conf.ServiceTimeout
+	if override, ok := conf.ServiceTimeoutOverride[name]; ok && override != "" {
+		timeout = override
+	}
+	if timeout == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(timeout)
+}
+
+// ValidateServiceTimeouts checks the HttpTimeout, ServiceTimeout and ServiceTimeoutOverride durations
+func ValidateServiceTimeouts(conf configuration.Config) error {
+	_, err := GetHttpTimeout(conf)
+	if err != nil {
+		return fmt.Errorf("invalid HttpTimeout: %w", err)
+	}
+	_, err = GetServiceTimeout(conf, "")
+	if err != nil {
+		return fmt.Errorf("invalid ServiceTimeout: %w", err)
+	}
+	for name := range conf.ServiceTimeoutOverride {
+		_, err = GetServiceTimeout(conf, name)
+		if err != nil {
+			return fmt.Errorf("invalid ServiceTimeoutOverride of %v: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// GetHttpTimeout returns the timeout of single requests; 0 if unlimited
+func GetHttpTimeout(conf configuration.Config) (time.Duration, error) {
+	if conf.HttpTimeout == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(conf.HttpTimeout)
+}
+
+// withServiceTimeout returns a context, which is canceled with ctx or after the ServiceTimeout of the named service
+func withServiceTimeout(ctx context.Context, conf configuration.Config, name string) (context.Context, context.CancelFunc) {
+	timeout, err := GetServiceTimeout(conf, name)
+	if err != nil {
+		log.Println("WARNING: invalid service timeout of", name, err)
+	}
+	if err != nil || timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// forEachParallel calls f for every item with at most workers concurrent calls; instead of stopping at the first error all errors are joined
+func forEachParallel[T any](items []T, workers int, f func(item T) error) (done []T, err error) {
+	if workers < 1 {
+		workers = 1
+	}
+	mux := sync.Mutex{}
+	errs := []error{}
+	wg := sync.WaitGroup{}
+	limiter := make(chan struct{}, workers)
+	for _, item := range items {
+		limiter <- struct{}{}
+		wg.Add(1)
+		go func(item T) {
+			defer wg.Done()
+			defer func() { <-limiter }()
+			fErr := f(item)
+			mux.Lock()
+			defer mux.Unlock()
+			if fErr != nil {
+				errs = append(errs, fErr)
+			} else {
+				done = append(done, item)
+			}
+		}(item)
+	}
+	wg.Wait()
+	return done, errors.Join(errs...)
+}
+
+// copyResource reads collectionUrl/id as the token user and creates it as the target user by posting it to collectionUrl.
+// The removeFields (like id and owner) are removed from the copy and are expected to be set by the service.
+func copyResource(token Token, target Token, collectionUrl string, id string, removeFields ...string) error {
+	resource := map[string]interface{}{}
+	err := token.Impersonate().GetJSON(collectionUrl+"/"+url.PathEscape(id), &resource)
+	if err != nil {
+		return err
+	}
+	for _, field := range removeFields {
+		delete(resource, field)
+	}
+	return target.Impersonate().PostJSON(collectionUrl, resource, nil)
+}
+
+// CopyLog records the ids of resources, which were copied to another user while their original may still exist.
+// A retried transfer only removes the original of a recorded resource, so the target user gets no duplicate.
+// A nil CopyLog records nothing.
+type CopyLog struct {
+	mux    sync.Mutex
+	copied map[string]bool
+	save   func(id string) error
+}
+
+// NewCopyLog returns a CopyLog of the already copied ids; save persists every new copy
+func NewCopyLog(copied []string, save func(id string) error) *CopyLog {
+	result := &CopyLog{copied: map[string]bool{}, save: save}
+	for _, id := range copied {
+		result.copied[id] = true
+	}
+	return result
+}
+
+func (this *CopyLog) isCopied(id string) bool {
+	if this == nil {
+		return false
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return this.copied[id]
+}
+
+func (this *CopyLog) record(id string) error {
+	if this == nil {
+		return nil
+	}
+	this.mux.Lock()
+	this.copied[id] = true
+	this.mux.Unlock()
+	if this.save == nil {
+		return nil
+	}
+	return this.save(id)
+}
+
+// moveResource copies the resource, unless copies records a previous copy, and removes the original afterward.
+// The original is kept, if the copy can not be recorded.
+func moveResource(copies *CopyLog, id string, copy func() error, remove func() error) error {
+	if !copies.isCopied(id) {
+		err := copy()
+		if err != nil {
+			return err
+		}
+		err = copies.record(id)
+		if err != nil {
+			return fmt.Errorf("unable to record copy of %v: %w", id, err)
+		}
+	}
+	return remove()
+}
+
+func isSet(url string) bool {
+	return url != "" && url != "-"
+}
+
+// listAllPages collects all ids of a limit/offset paginated list
+func listAllPages(getBatch func(limit int, offset int) ([]string, error)) (ids []string, err error) {
+	loopLimit := 10000
+	for loopCount := 0; loopCount < loopLimit; loopCount++ {
+		batch, err := getBatch(BatchSize, len(ids))
+		if err != nil {
+			return ids, err
+		}
+		ids = append(ids, batch...)
+		if len(batch) < BatchSize {
+			return ids, nil
+		}
+	}
+	return ids, errors.New("listAllPages() reach loop limit")
+}
@@ -17,27 +17,59 @@
 package ctrl
 
 import (
+	"context"
 	"errors"
 	"github.com/SENERGY-Platform/user-management/pkg/configuration"
 	"io"
 	"net/url"
 )
 
-func DeleteDashboardUser(token Token, conf configuration.Config) error {
-	if conf.DashboardServiceUrl == "" || conf.DashboardServiceUrl == "-" {
-		return nil
-	}
+type DashboardCleaner struct{}
+
+func (this DashboardCleaner) Name() string {
+	return "dashboard"
+}
+
+func (this DashboardCleaner) Enabled(conf configuration.Config) bool {
+	return isSet(conf.DashboardServiceUrl)
+}
+
+func (this DashboardCleaner) List(ctx context.Context, token Token, conf configuration.Config) ([]ServiceResources, error) {
+	ids, err := getDashboardIds(token, conf)
+	return []ServiceResources{{Resource: "dashboards", Ids: ids}}, err
+}
+
+func (this DashboardCleaner) Delete(ctx context.Context, token Token, conf configuration.Config) (deleted []string, err error) {
 	ids, err := getDashboardIds(token, conf)
 	if err != nil {
-		return err
+		return deleted, err
 	}
-	for _, id := range ids {
-		err = deleteDashboard(token, conf, id)
-		if err != nil {
-			return err
-		}
+	return forEachParallel(ids, getServiceWorkers(conf, this.Name()), func(id string) error {
+		return deleteDashboard(token, conf, id)
+	})
+}
+
+func (this DashboardCleaner) Transfer(ctx context.Context, token Token, target Token, copies *CopyLog, conf configuration.Config) (transferred []string, err error) {
+	ids, err := getDashboardIds(token, conf)
+	if err != nil {
+		return transferred, err
 	}
-	return nil
+	return forEachParallel(ids, getServiceWorkers(conf, this.Name()), func(id string) error {
+		return moveResource(copies, id, func() error {
+			return copyResource(token, target, conf.DashboardServiceUrl+"/dashboards", id, "id", "user_id")
+		}, func() error {
+			return deleteDashboard(token, conf, id)
+		})
+	})
+}
+
+func (this DashboardCleaner) Export(ctx context.Context, token Token, conf configuration.Config) (files map[string]interface{}, err error) {
+	dashboards, err := getRawJson(token, conf.DashboardServiceUrl+"/dashboards")
+	return map[string]interface{}{"dashboards": dashboards}, err
+}
+
+func (this DashboardCleaner) Verify(ctx context.Context, token Token, conf configuration.Config) (remaining []string, err error) {
+	return verifyByList(ctx, this, token, conf)
 }
 
 func deleteDashboard(token Token, conf configuration.Config, id string) error {
@@ -17,6 +17,7 @@
 package ctrl
 
 import (
+	"context"
 	"errors"
 	"github.com/SENERGY-Platform/user-management/pkg/configuration"
 	"io"
@@ -24,35 +25,74 @@ import (
 	"strconv"
 )
 
-func DeleteDatabaseExportsUser(token Token, conf configuration.Config) error {
-	if conf.DatabaseExportsUrl == "" || conf.DatabaseExportsUrl == "-" {
-		return nil
-	}
+type DatabaseExportsCleaner struct{}
+
+func (this DatabaseExportsCleaner) Name() string {
+	return "database-exports"
+}
+
+func (this DatabaseExportsCleaner) Enabled(conf configuration.Config) bool {
+	return isSet(conf.DatabaseExportsUrl)
+}
 
+func (this DatabaseExportsCleaner) List(ctx context.Context, token Token, conf configuration.Config) ([]ServiceResources, error) {
+	ids, err := listAllPages(func(limit int, offset int) ([]string, error) {
+		return getBatchOfDatabaseExportIds(token, conf, limit, offset)
+	})
+	return []ServiceResources{{Resource: "instances", Ids: ids}}, err
+}
+
+func (this DatabaseExportsCleaner) Delete(ctx context.Context, token Token, conf configuration.Config) (deleted []string, err error) {
 	loopLimit := 10000
 	loopCount := 0
 	for {
 		ids, err := getBatchOfDatabaseExportIds(token, conf, BatchSize, 0)
 		if err != nil {
-			return err
+			return deleted, err
 		}
 		if len(ids) == 0 {
-			return nil
+			return deleted, nil
 		}
 
 		err = deleteBatchOfDatabaseExports(token, conf, ids)
 		if err != nil {
-			return err
+			return deleted, err
 		}
+		deleted = append(deleted, ids...)
 
 		loopCount = loopCount + 1
 		if loopCount == loopLimit {
-			return errors.New("DeleteDatabaseExportUser() reach loop limit")
+			return deleted, errors.New("DeleteDatabaseExportUser() reach loop limit")
 		}
 	}
 
 }
 
+func (this DatabaseExportsCleaner) Transfer(ctx context.Context, token Token, target Token, copies *CopyLog, conf configuration.Config) (transferred []string, err error) {
+	ids, err := listAllPages(func(limit int, offset int) ([]string, error) {
+		return getBatchOfDatabaseExportIds(token, conf, limit, offset)
+	})
+	if err != nil {
+		return transferred, err
+	}
+	return forEachParallel(ids, getServiceWorkers(conf, this.Name()), func(id string) error {
+		return moveResource(copies, id, func() error {
+			return copyResource(token, target, conf.DatabaseExportsUrl+"/instances", id, "ID", "UserId")
+		}, func() error {
+			return deleteBatchOfDatabaseExports(token, conf, []string{id})
+		})
+	})
+}
+
+func (this DatabaseExportsCleaner) Export(ctx context.Context, token Token, conf configuration.Config) (files map[string]interface{}, err error) {
+	instances, err := getAllRawPages(token, conf.DatabaseExportsUrl+"/instance", "instances")
+	return map[string]interface{}{"instances": instances}, err
+}
+
+func (this DatabaseExportsCleaner) Verify(ctx context.Context, token Token, conf configuration.Config) (remaining []string, err error) {
+	return verifyByList(ctx, this, token, conf)
+}
+
 func deleteBatchOfDatabaseExports(token Token, conf configuration.Config, ids []string) error {
 	if len(ids) > 0 {
 		resp, err := token.Impersonate().DeleteWithBody(conf.DatabaseExportsUrl+"/instances", ids)
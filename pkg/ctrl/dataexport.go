@@ -0,0 +1,293 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctrl
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/store"
+	"github.com/google/uuid"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DataExporter is implemented by cleaners, which are able to export the data of a user for a data takeout
+type DataExporter interface {
+	// Export returns the data of the token user by file name (without extension); every entry is written as json file
+	Export(ctx context.Context, token Token, conf configuration.Config) (files map[string]interface{}, err error)
+}
+
+type DataExportState string
+
+const (
+	DataExportPending DataExportState = "pending"
+	DataExportRunning DataExportState = "running"
+	DataExportDone    DataExportState = "done"
+	DataExportFailed  DataExportState = "failed"
+)
+
+type DataExport struct {
+	Id          string          `json:"id"`
+	UserId      string          `json:"user_id"`
+	RequesterId string          `json:"requester_id"`
+	State       DataExportState `json:"state"`
+	Created     time.Time       `json:"created"`
+	Finished    *time.Time      `json:"finished,omitempty"`
+	Expires     *time.Time      `json:"expires,omitempty"` //the archive is removed after this time
+	Size        int64           `json:"size,omitempty"`
+	Errors      []string        `json:"errors,omitempty"` //services, which could not be exported
+	Error       string          `json:"error,omitempty"`
+}
+
+type DataExportStore = store.Store[DataExport]
+
+// DataExportManifest is written as manifest.json into the archive
+type DataExportManifest struct {
+	UserId   string                      `json:"user_id"`
+	Created  time.Time                   `json:"created"`
+	Services []DataExportManifestService `json:"services"`
+}
+
+type DataExportManifestService struct {
+	Service string   `json:"service"`
+	Files   []string `json:"files"`
+	Error   string   `json:"error,omitempty"`
+}
+
+var ErrDataExportNotFound = errors.New("data export not found")
+
+func NewDataExport(userId string, requesterId string) DataExport {
+	return DataExport{
+		Id:          uuid.NewString(),
+		UserId:      userId,
+		RequesterId: requesterId,
+		State:       DataExportPending,
+		Created:     time.Now(),
+	}
+}
+
+func GetDataExportExpiration(conf configuration.Config) (time.Duration, error) {
+	if conf.DataExportExpiration == "" {
+		return 7 * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(conf.DataExportExpiration)
+}
+
+// GetDataExportLocation returns the file path of the archive of a data export
+func GetDataExportLocation(conf configuration.Config, id string) string {
+	dir := conf.PersistenceDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "exports", id+".zip")
+}
+
+// RunDataExport writes the archive of the export and updates its state
+func RunDataExport(ctx context.Context, exportId string, conf configuration.Config, exports DataExportStore) (err error) {
+	export, exists, err := exports.Get(exportId)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrDataExportNotFound
+	}
+	export.State = DataExportRunning
+	err = exports.Set(export.Id, export)
+	if err != nil {
+		return err
+	}
+	//without expiration the archive would never be removed, so it is checked before the archive is written
+	expiration, err := GetDataExportExpiration(conf)
+	var manifest DataExportManifest
+	var size int64
+	if err == nil {
+		manifest, size, err = writeDataExportFile(ctx, export.UserId, conf, GetDataExportLocation(conf, export.Id))
+	}
+	now := time.Now()
+	export.Finished = &now
+	if err != nil {
+		log.Println("ERROR: unable to export user data", export.UserId, err)
+		export.State = DataExportFailed
+		export.Error = err.Error()
+		return errors.Join(err, exports.Set(export.Id, export))
+	}
+	expires := now.Add(expiration)
+	export.State = DataExportDone
+	export.Expires = &expires
+	export.Size = size
+	for _, service := range manifest.Services {
+		if service.Error != "" {
+			export.Errors = append(export.Errors, service.Service+": "+service.Error)
+		}
+	}
+	return exports.Set(export.Id, export)
+}
+
+func writeDataExportFile(ctx context.Context, userId string, conf configuration.Config, location string) (manifest DataExportManifest, size int64, err error) {
+	err = os.MkdirAll(filepath.Dir(location), 0o700)
+	if err != nil {
+		return manifest, size, err
+	}
+	temp := location + ".tmp"
+	file, err := os.OpenFile(temp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return manifest, size, err
+	}
+	manifest, err = WriteDataExport(ctx, userId, conf, file)
+	err = errors.Join(err, file.Close())
+	if err != nil {
+		os.Remove(temp)
+		return manifest, size, err
+	}
+	info, err := os.Stat(temp)
+	if err != nil {
+		return manifest, size, err
+	}
+	return manifest, info.Size(), os.Rename(temp, location)
+}
+
+// WriteDataExport writes a zip archive with the data of every DataExporter as <service>/<name>.json and a manifest.json.
+// Failing services are listed in the manifest instead of failing the whole export.
+func WriteDataExport(ctx context.Context, userId string, conf configuration.Config, w io.Writer) (manifest DataExportManifest, err error) {
+	token, err := CreateToken("users-service", userId)
+	if err != nil {
+		return manifest, err
+	}
+	manifest = DataExportManifest{UserId: userId, Created: time.Now(), Services: []DataExportManifestService{}}
+	archive := zip.NewWriter(w)
+	for _, cleaner := range GetCleaners(conf) {
+		exporter, ok := cleaner.(DataExporter)
+		if !ok {
+			continue
+		}
+		entry := DataExportManifestService{Service: cleaner.Name(), Files: []string{}}
+		serviceCtx, cancel := withServiceTimeout(ctx, conf, cleaner.Name())
+		files, exportErr := exporter.Export(serviceCtx, token.WithContext(serviceCtx), conf)
+		cancel()
+		if exportErr != nil {
+			log.Println("ERROR: unable to export user data of", cleaner.Name(), exportErr)
+			entry.Error = exportErr.Error()
+		}
+		names := []string{}
+		for name := range files {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fileName := cleaner.Name() + "/" + name + ".json"
+			err = writeJsonToZip(archive, fileName, files[name])
+			if err != nil {
+				return manifest, err
+			}
+			entry.Files = append(entry.Files, fileName)
+		}
+		manifest.Services = append(manifest.Services, entry)
+	}
+	err = writeJsonToZip(archive, "manifest.json", manifest)
+	if err != nil {
+		return manifest, err
+	}
+	return manifest, archive.Close()
+}
+
+func writeJsonToZip(archive *zip.Writer, name string, value interface{}) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(value)
+}
+
+// RemoveExpiredDataExports removes exports and archives with an expiry before now
+func RemoveExpiredDataExports(conf configuration.Config, exports DataExportStore, now time.Time) error {
+	list, err := exports.List()
+	if err != nil {
+		return err
+	}
+	errs := []error{}
+	for _, export := range list {
+		if export.Expires == nil || export.Expires.After(now) {
+			continue
+		}
+		err = os.Remove(GetDataExportLocation(conf, export.Id))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			errs = append(errs, err)
+			continue
+		}
+		errs = append(errs, exports.Remove(export.Id))
+	}
+	return errors.Join(errs...)
+}
+
+// getRawJson requests a json value without a known structure
+func getRawJson(token Token, url string) (result interface{}, err error) {
+	err = token.Impersonate().GetJSON(url, &result)
+	return result, err
+}
+
+// getAllRawPages collects the elements of a limit/offset paginated list; listKey selects the array in an object response and is empty if the response is the array
+func getAllRawPages(token Token, listUrl string, listKey string) (result []interface{}, err error) {
+	result = []interface{}{}
+	loopLimit := 10000
+	for loopCount := 0; loopCount < loopLimit; loopCount++ {
+		query := url.Values{}
+		query.Add("limit", strconv.Itoa(BatchSize))
+		query.Add("offset", strconv.Itoa(len(result)))
+		temp, err := getRawJson(token, listUrl+"?"+query.Encode())
+		if err != nil {
+			return result, err
+		}
+		page := getRawList(temp, listKey)
+		result = append(result, page...)
+		if len(page) < BatchSize {
+			return result, nil
+		}
+	}
+	return result, errors.New("getAllRawPages() reach loop limit")
+}
+
+func getRawList(value interface{}, listKey string) []interface{} {
+	if listKey != "" {
+		obj, _ := value.(map[string]interface{})
+		value = obj[listKey]
+	}
+	list, _ := value.([]interface{})
+	return list
+}
+
+// filterRawByOwner keeps the elements with a matching ownerField
+func filterRawByOwner(elements []interface{}, ownerField string, userId string) (result []interface{}) {
+	result = []interface{}{}
+	for _, element := range elements {
+		obj, ok := element.(map[string]interface{})
+		if ok && obj[ownerField] == userId {
+			result = append(result, element)
+		}
+	}
+	return result
+}
@@ -0,0 +1,90 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctrl
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/user-management/pkg/kafka"
+	"github.com/SENERGY-Platform/user-management/pkg/store"
+	"slices"
+	"time"
+)
+
+// DeadLetter is a user command, which could not be handled and was moved to the dead-letter topic
+type DeadLetter struct {
+	Id        string    `json:"id"`
+	Topic     string    `json:"topic"`
+	Partition int       `json:"partition"`
+	Offset    int64     `json:"offset"`
+	Key       string    `json:"key"`
+	Value     string    `json:"value"`
+	Error     string    `json:"error"`
+	Attempts  int64     `json:"attempts"`
+	Created   time.Time `json:"created"`
+}
+
+type DeadLetterStore = store.Store[DeadLetter]
+
+func NewDeadLetter(letter kafka.DeadLetter) DeadLetter {
+	result := DeadLetter{
+		Id:        fmt.Sprintf("%s:%d:%d", letter.Topic, letter.Partition, letter.Offset),
+		Topic:     letter.Topic,
+		Partition: letter.Partition,
+		Offset:    letter.Offset,
+		Key:       string(letter.Key),
+		Value:     string(letter.Value),
+		Attempts:  letter.Attempts,
+		Created:   time.Now(),
+	}
+	if letter.Error != nil {
+		result.Error = letter.Error.Error()
+	}
+	return result
+}
+
+// ReplayDeadLetters passes the stored dead letters with the given ids (all, if ids is empty) to produce and removes them from the store.
+// Returns the replayed letters.
+func ReplayDeadLetters(letters DeadLetterStore, ids []string, produce func(key []byte, value []byte) error) (replayed []DeadLetter, err error) {
+	list, err := letters.List()
+	if err != nil {
+		return replayed, err
+	}
+	replayed = []DeadLetter{}
+	for _, letter := range list {
+		if len(ids) > 0 && !slices.Contains(ids, letter.Id) {
+			continue
+		}
+		err = produce([]byte(letter.Key), []byte(letter.Value))
+		if err != nil {
+			return replayed, err
+		}
+		err = letters.Remove(letter.Id)
+		if err != nil {
+			return replayed, err
+		}
+		replayed = append(replayed, letter)
+	}
+	return replayed, nil
+}
+
+// poison marks err as not resolvable by retries
+func poison(err error) error {
+	return fmt.Errorf("%w: %w", kafka.ErrPoisonMessage, err)
+}
+
+var errUnknownCommand = errors.New("unknown command")
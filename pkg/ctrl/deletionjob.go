@@ -0,0 +1,143 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctrl
+
+import (
+	"github.com/SENERGY-Platform/user-management/pkg/store"
+	"time"
+)
+
+type DeletionState string
+
+const (
+	DeletionPending   DeletionState = "pending"
+	DeletionRunning   DeletionState = "running"
+	DeletionDone      DeletionState = "done"
+	DeletionFailed    DeletionState = "failed"
+	DeletionSkipped   DeletionState = "skipped"
+	DeletionScheduled DeletionState = "scheduled"
+)
+
+const (
+	CommandDelete   = "DELETE"
+	CommandTransfer = "TRANSFER"
+)
+
+type DeletionJob struct {
+	UserId          string            `json:"user_id"`
+	Command         string            `json:"command"`
+	TargetUserId    string            `json:"target_user_id,omitempty"` //receiver of the resources of a TRANSFER
+	State           DeletionState     `json:"state"`
+	ScheduledFor    *time.Time        `json:"scheduled_for,omitempty"`     //end of the grace period of a scheduled job
+	CancelTokenHash string            `json:"cancel_token_hash,omitempty"` //sha256 of the token, which cancels a scheduled job without login
+	AuditId         string            `json:"audit_id,omitempty"`          //audit entry of the request
+	Policies        map[string]string `json:"policies,omitempty"`          //ResourcePolicies overrides of the request
+	Steps           []DeletionStep    `json:"steps"`
+	Created         time.Time         `json:"created"`
+	Updated         time.Time         `json:"updated"`
+	Finished        *time.Time        `json:"finished,omitempty"`
+}
+
+type DeletionStep struct {
+	Service     string           `json:"service"`
+	State       DeletionState    `json:"state"`
+	Attempts    int              `json:"attempts"`
+	Deleted     int              `json:"deleted"`
+	Transferred int              `json:"transferred,omitempty"`
+	Copied      []string         `json:"copied,omitempty"`    //ids of resources copied to the transfer target; not copied again by retries
+	Decisions   []PolicyDecision `json:"decisions,omitempty"` //handling of resources with a ResourcePolicy
+	Orphans     []string         `json:"orphans,omitempty"`   //resources still found by the verification after the last attempt
+	Note        string           `json:"note,omitempty"`
+	LastError   string           `json:"last_error,omitempty"`
+	Finished    *time.Time       `json:"finished,omitempty"`
+}
+
+type JobStore = store.Store[DeletionJob]
+
+func NewDeletionJob(userId string, services []string) DeletionJob {
+	return NewTransferJob(userId, "", services)
+}
+
+// NewTransferJob creates a job, which hands the resources of the user to targetUserId instead of deleting them; an empty targetUserId creates a deletion job
+func NewTransferJob(userId string, targetUserId string, services []string) DeletionJob {
+	now := time.Now()
+	job := DeletionJob{
+		UserId:       userId,
+		Command:      CommandDelete,
+		TargetUserId: targetUserId,
+		State:        DeletionPending,
+		Created:      now,
+		Updated:      now,
+	}
+	if targetUserId != "" {
+		job.Command = CommandTransfer
+	}
+	job.setServices(services)
+	return job
+}
+
+// setServices aligns the steps with the given services, keeping the progress of already known services
+func (this *DeletionJob) setServices(services []string) {
+	steps := make([]DeletionStep, 0, len(services))
+	for _, service := range services {
+		step, ok := this.getStep(service)
+		if !ok {
+			step = DeletionStep{Service: service, State: DeletionPending}
+		}
+		steps = append(steps, step)
+	}
+	this.Steps = steps
+}
+
+func (this *DeletionJob) getStep(service string) (DeletionStep, bool) {
+	for _, step := range this.Steps {
+		if step.Service == service {
+			return step, true
+		}
+	}
+	return DeletionStep{}, false
+}
+
+// loadDeletionJob returns the unfinished job of the user or a new one, if no such job exists.
+// An unfinished job is continued with the requested command; already finished steps are kept.
+func loadDeletionJob(jobs JobStore, userId string, targetUserId string, services []string) (job DeletionJob, err error) {
+	job, exists, err := jobs.Get(userId)
+	if err != nil {
+		return job, err
+	}
+	if !exists || job.State == DeletionDone {
+		return NewTransferJob(userId, targetUserId, services), nil
+	}
+	job.Command = CommandDelete
+	job.TargetUserId = targetUserId
+	if targetUserId != "" {
+		job.Command = CommandTransfer
+	}
+	job.setServices(services)
+	for i, step := range job.Steps {
+		if step.State == DeletionRunning || step.State == DeletionSkipped {
+			//interrupted by crash or restart
+			job.Steps[i].State = DeletionPending
+		}
+	}
+	return job, nil
+}
+
+func saveDeletionJob(jobs JobStore, job *DeletionJob) error {
+	job.Updated = time.Now()
+	return jobs.Set(job.UserId, *job)
+}
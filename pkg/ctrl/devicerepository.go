@@ -0,0 +1,96 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctrl
+
+import (
+	"context"
+	devicerepo "github.com/SENERGY-Platform/device-repository/lib/client"
+	permissions "github.com/SENERGY-Platform/permissions-v2/pkg/client"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"net/url"
+	"slices"
+	"strconv"
+)
+
+// DeviceRepositoryCleaner lets the device-repository remove the user in a single admin call; its resources can not be listed
+type DeviceRepositoryCleaner struct{}
+
+func (this DeviceRepositoryCleaner) Name() string {
+	return "device-repository"
+}
+
+func (this DeviceRepositoryCleaner) Enabled(conf configuration.Config) bool {
+	return isSet(conf.DeviceRepositoryUrl)
+}
+
+func (this DeviceRepositoryCleaner) List(ctx context.Context, token Token, conf configuration.Config) ([]ServiceResources, error) {
+	return []ServiceResources{{
+		Resource: "devices",
+		Note:     "removed by the device-repository in a single call; resources are not listed",
+	}}, nil
+}
+
+func (this DeviceRepositoryCleaner) Delete(ctx context.Context, token Token, conf configuration.Config) (deleted []string, err error) {
+	admin := JwtImpersonate{Token: devicerepo.InternalAdminToken}.WithContext(ctx)
+	resp, err := admin.Delete(conf.DeviceRepositoryUrl+"/users/"+url.PathEscape(token.GetUserId()), nil)
+	if err != nil {
+		return deleted, err
+	}
+	return deleted, resp.Body.Close()
+}
+
+func (this DeviceRepositoryCleaner) Verify(ctx context.Context, token Token, conf configuration.Config) (remaining []string, err error) {
+	return remaining, nil
+}
+
+// ListOwners returns the users with administrate permission on any resource managed by permissions-v2, which holds the rights of the device-repository resources
+func (this DeviceRepositoryCleaner) ListOwners(ctx context.Context, conf configuration.Config) (owners []string, ok bool, err error) {
+	if !isSet(conf.PermissionsV2Url) {
+		return owners, false, nil
+	}
+	admin := JwtImpersonate{Token: permissions.InternalAdminToken}.WithContext(ctx)
+	topics := []permissions.Topic{}
+	err = admin.GetJSON(conf.PermissionsV2Url+"/admin/topics?version="+url.QueryEscape(permissions.ClientVersion), &topics)
+	if err != nil {
+		return owners, true, err
+	}
+	for _, topic := range topics {
+		loopLimit := 10000
+		for loopCount := 0; loopCount < loopLimit; loopCount++ {
+			query := url.Values{}
+			query.Set("limit", strconv.Itoa(BatchSize))
+			query.Set("offset", strconv.Itoa(loopCount*BatchSize))
+			query.Set("version", permissions.ClientVersion)
+			resources := []permissions.Resource{}
+			err = admin.GetJSON(conf.PermissionsV2Url+"/manage/"+url.PathEscape(topic.Id)+"?"+query.Encode(), &resources)
+			if err != nil {
+				return owners, true, err
+			}
+			for _, resource := range resources {
+				for userId, permission := range resource.UserPermissions {
+					if permission.Administrate && !slices.Contains(owners, userId) {
+						owners = append(owners, userId)
+					}
+				}
+			}
+			if len(resources) < BatchSize {
+				break
+			}
+		}
+	}
+	return owners, true, nil
+}
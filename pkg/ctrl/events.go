@@ -20,27 +20,145 @@ import (
 	"context"
 	"github.com/SENERGY-Platform/user-management/pkg/configuration"
 	"github.com/SENERGY-Platform/user-management/pkg/kafka"
+	"github.com/SENERGY-Platform/user-management/pkg/store"
 	"log"
 	"sync"
 	"time"
 
 	"encoding/json"
 	"errors"
+	"fmt"
 )
 
 type UserCommandMsg struct {
-	Command string `json:"command"`
-	Id      string `json:"id"`
+	Command        string            `json:"command"`
+	Id             string            `json:"id"`
+	TargetId       string            `json:"target_id,omitempty"`       //receiver of the resources of a TRANSFER command
+	AuditId        string            `json:"audit_id,omitempty"`        //audit entry of the request
+	RequesterId    string            `json:"requester_id,omitempty"`    //recorded in the audit log
+	RequesterAdmin bool              `json:"requester_admin,omitempty"` //recorded in the audit log
+	Force          bool              `json:"force,omitempty"`           //execute even if the command key is already completed according to the ledger
+	Policies       map[string]string `json:"policies,omitempty"`        //ResourcePolicies overrides of the request
 }
 
 type EventHandler struct {
-	conf          configuration.Config
-	usersProducer *kafka.Producer
+	conf               configuration.Config
+	usersProducer      *kafka.Producer
+	deadLetterProducer *kafka.Producer
+	eventsProducer     *kafka.Producer
+	jobs               JobStore
+	deadLetters        DeadLetterStore
+	exports            DataExportStore
+	audits             AuditStore
+	batches            DeletionBatchStore
+	ledger             LedgerStore
+	inactiveUsers      InactiveUserStore
+	inactiveReport     *InactiveUserReport
+	inactiveReportMux  sync.Mutex
+	orphanReport       *OrphanScanReport
+	orphanReportMux    sync.Mutex
+	gracePeriod        time.Duration
+	ctx                context.Context //canceled on shutdown; bounds work in the background
 }
 
 func InitEventConn(ctx context.Context, wg *sync.WaitGroup, conf configuration.Config) (handler *EventHandler, err error) {
 	handler = &EventHandler{
 		conf: conf,
+		ctx:  ctx,
+	}
+
+	err = ValidateHttpCleaners(conf)
+	if err != nil {
+		return handler, err
+	}
+
+	err = ValidateResourcePolicies(conf.ResourcePolicies)
+	if err != nil {
+		return handler, fmt.Errorf("invalid ResourcePolicies: %w", err)
+	}
+
+	err = ValidateServiceTimeouts(conf)
+	if err != nil {
+		return handler, err
+	}
+	httpTimeout, _ := GetHttpTimeout(conf)
+	SetHttpTimeout(httpTimeout)
+
+	handler.jobs, err = store.New[DeletionJob](conf.PersistenceType, conf.PersistenceDir, "deletion-jobs")
+	if err != nil {
+		return handler, err
+	}
+
+	auditPersistenceType := conf.AuditPersistenceType
+	if auditPersistenceType == "" {
+		auditPersistenceType = conf.PersistenceType
+	}
+	if auditPersistenceType == store.TypeFile {
+		auditPersistenceType = store.TypeLog //audit entries are updated often; appending avoids rewriting the whole log
+	}
+	handler.audits, err = store.New[AuditEntry](auditPersistenceType, conf.PersistenceDir, "deletion-audit")
+	if err != nil {
+		return handler, err
+	}
+	_, err = GetAuditRetention(conf)
+	if err != nil {
+		return handler, err
+	}
+	err = RemoveExpiredAuditEntries(conf, handler.audits, time.Now())
+	if err != nil {
+		return handler, err
+	}
+
+	handler.ledger, err = store.New[LedgerEntry](conf.PersistenceType, conf.PersistenceDir, "user-command-ledger")
+	if err != nil {
+		return handler, err
+	}
+
+	handler.batches, err = store.New[DeletionBatch](conf.PersistenceType, conf.PersistenceDir, "deletion-batches")
+	if err != nil {
+		return handler, err
+	}
+
+	handler.inactiveUsers, err = store.New[InactiveUser](conf.PersistenceType, conf.PersistenceDir, "inactive-users")
+	if err != nil {
+		return handler, err
+	}
+	inactiveUserCheckInterval, err := GetInactiveUserCheckInterval(conf)
+	if err != nil {
+		return handler, err
+	}
+	if inactiveUserCheckInterval > 0 {
+		_, err = GetInactiveUserSettings(conf)
+		if err != nil {
+			return handler, err
+		}
+	}
+
+	handler.exports, err = store.New[DataExport](conf.PersistenceType, conf.PersistenceDir, "data-exports")
+	if err != nil {
+		return handler, err
+	}
+	_, err = GetDataExportExpiration(conf)
+	if err != nil {
+		return handler, err
+	}
+	err = handler.resumeDataExports()
+	if err != nil {
+		return handler, err
+	}
+
+	orphanScanInterval, err := GetOrphanScanInterval(conf)
+	if err != nil {
+		return handler, err
+	}
+
+	handler.gracePeriod, err = GetDeletionGracePeriod(conf)
+	if err != nil {
+		return handler, err
+	}
+	scheduleInterval, err := GetDeletionScheduleInterval(conf)
+	if err != nil {
+		return handler, err
 	}
 
 	log.Println("init producer")
@@ -49,8 +167,40 @@ func InitEventConn(ctx context.Context, wg *sync.WaitGroup, conf configuration.C
 		return handler, err
 	}
 
+	if isSet(conf.UserEventsTopic) {
+		if conf.InitTopics {
+			err = kafka.InitTopic(conf.KafkaBootstrap, conf.UserEventsTopic)
+			if err != nil {
+				log.Println("WARN: unable to create user events topic", err)
+			}
+		}
+		handler.eventsProducer, err = kafka.NewProducer(conf.KafkaBootstrap, conf.UserEventsTopic, conf.Debug)
+		if err != nil {
+			return handler, err
+		}
+	}
+
+	var deadLetterHandler func(letter kafka.DeadLetter) error
+	if isSet(conf.UserDeadLetterTopic) {
+		handler.deadLetters, err = store.New[DeadLetter](conf.PersistenceType, conf.PersistenceDir, "dead-letters")
+		if err != nil {
+			return handler, err
+		}
+		if conf.InitTopics {
+			err = kafka.InitTopic(conf.KafkaBootstrap, conf.UserDeadLetterTopic)
+			if err != nil {
+				log.Println("WARN: unable to create dead-letter topic", err)
+			}
+		}
+		handler.deadLetterProducer, err = kafka.NewProducer(conf.KafkaBootstrap, conf.UserDeadLetterTopic, conf.Debug)
+		if err != nil {
+			return handler, err
+		}
+		deadLetterHandler = handler.handleDeadLetter
+	}
+
 	log.Println("init consumer")
-	_, err = kafka.NewConsumer(ctx, wg, conf.KafkaBootstrap, conf.ConsumerGroup, conf.UserTopic, conf.InitTopics, handler.handleUserCommand, func(err error, c *kafka.Consumer) {
+	_, err = kafka.NewConsumerWithDeadLetters(ctx, wg, conf.KafkaBootstrap, conf.ConsumerGroup, conf.UserTopic, conf.InitTopics, handler.handleUserCommand, deadLetterHandler, func(err error, c *kafka.Consumer) {
 		log.Println("ERROR: Encountered error on consumer", err.Error())
 	})
 	if err != nil {
@@ -58,6 +208,69 @@ func InitEventConn(ctx context.Context, wg *sync.WaitGroup, conf configuration.C
 		log.Println("WARN: client will retry until successful")
 		err = nil
 	}
+
+	if inactiveUserCheckInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(inactiveUserCheckInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case now := <-ticker.C:
+					handler.checkInactiveUsers(ctx, now)
+				}
+			}
+		}()
+	}
+
+	if orphanScanInterval > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ticker := time.NewTicker(orphanScanInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					_, err := handler.ScanOrphans(ctx, handler.conf.OrphanScanEnqueue, Requester{Id: OrphanScannerId, Admin: true})
+					if err != nil {
+						log.Println("ERROR: unable to scan for orphaned resources", err)
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(scheduleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				err := StartDueDeletions(handler.jobs, now, handler.sendJobCommand)
+				if err != nil {
+					log.Println("ERROR: unable to start due deletions", err)
+				}
+				err = RemoveExpiredDataExports(handler.conf, handler.exports, now)
+				if err != nil {
+					log.Println("ERROR: unable to remove expired data exports", err)
+				}
+				err = RemoveExpiredAuditEntries(handler.conf, handler.audits, now)
+				if err != nil {
+					log.Println("ERROR: unable to remove expired audit entries", err)
+				}
+			}
+		}
+	}()
 	return
 }
 
@@ -70,30 +283,396 @@ func (handler *EventHandler) sendUsersEvent(key string, command interface{}) err
 	return handler.usersProducer.Produce([]byte(key), payload)
 }
 
-func (handler *EventHandler) DeleteUser(id string) error {
-	user, err := GetUserById(id, handler.conf)
+// publisher returns nil if no UserEventsTopic is configured
+func (handler *EventHandler) publisher() UserEventPublisher {
+	if handler.eventsProducer == nil {
+		return nil
+	}
+	return func(event UserEventMsg) error {
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		return handler.eventsProducer.Produce([]byte(event.Id), payload)
+	}
+}
+
+// DeleteUser requests the deletion of the user; policies overwrite the configured ResourcePolicies for this deletion.
+// If the deletion is scheduled, the returned cancelToken allows the disabled user to cancel it.
+func (handler *EventHandler) DeleteUser(ctx context.Context, id string, requester Requester, policies map[string]string) (cancelToken string, err error) {
+	err = ValidateResourcePolicies(policies)
 	if err != nil {
-		return err
+		return "", err
+	}
+	user, err := GetUserById(ctx, id, handler.conf)
+	if err != nil {
+		return "", err
 	}
 	if user.Id != id {
-		return errors.New("no matching user found")
+		return "", errors.New("no matching user found")
+	}
+	return handler.requestDeletion(ctx, id, "", requester, policies)
+}
+
+// TransferUser hands the resources of the user to the target user and deletes the user afterward;
+// the cancelToken is returned like by DeleteUser
+func (handler *EventHandler) TransferUser(ctx context.Context, id string, targetId string, requester Requester) (cancelToken string, err error) {
+	if targetId == "" || targetId == id {
+		return "", errors.New("invalid transfer target")
+	}
+	for _, userId := range []string{id, targetId} {
+		user, err := GetUserById(ctx, userId, handler.conf)
+		if err != nil {
+			return "", err
+		}
+		if user.Id != userId {
+			return "", errors.New("no matching user found: " + userId)
+		}
+	}
+	return handler.requestDeletion(ctx, id, targetId, requester, nil)
+}
+
+// requestDeletion schedules a new deletion if a grace period is configured; otherwise the job is created as pending
+// and the command is published immediately. Unfinished jobs are continued without a new grace period.
+// Every request is recorded in the audit log. The cancelToken is only returned for newly scheduled deletions.
+func (handler *EventHandler) requestDeletion(ctx context.Context, id string, targetId string, requester Requester, policies map[string]string) (cancelToken string, err error) {
+	entry := NewAuditEntry(AuditSourceApi, requester, id, targetId)
+	job, exists, err := handler.jobs.Get(id)
+	if err != nil {
+		return "", err
+	}
+	if exists && job.State == DeletionScheduled {
+		entry.State = DeletionScheduled
+		return "", handler.audits.Set(entry.Id, entry)
+	}
+	if !exists || job.State == DeletionDone {
+		if handler.gracePeriod > 0 {
+			job, cancelToken, err = ScheduleDeletion(ctx, id, targetId, handler.gracePeriod, handler.conf, handler.jobs)
+			if err != nil {
+				return "", err
+			}
+			entry.State = DeletionScheduled
+			err = handler.audits.Set(entry.Id, entry)
+			if err != nil {
+				return "", err
+			}
+			job.AuditId = entry.Id
+			job.Policies = policies
+			err = saveDeletionJob(handler.jobs, &job)
+			if err != nil {
+				return "", err
+			}
+			return cancelToken, nil
+		}
+		job = NewTransferJob(id, targetId, getCleanerNames(GetCleaners(handler.conf)))
+	}
+	err = handler.audits.Set(entry.Id, entry)
+	if err != nil {
+		return "", err
+	}
+	job.AuditId = entry.Id
+	job.Policies = policies
+	job.TargetUserId = targetId
+	err = saveDeletionJob(handler.jobs, &job)
+	if err != nil {
+		return "", err
+	}
+	return "", handler.sendJobCommand(job)
+}
+
+// sendJobCommand publishes the command of the job; the ledger entry of the command key is removed, because a new request has to be executed
+func (handler *EventHandler) sendJobCommand(job DeletionJob) error {
+	command := UserCommandMsg{
+		Command:  CommandDelete,
+		Id:       job.UserId,
+		AuditId:  job.AuditId,
+		Policies: job.Policies,
+	}
+	if job.TargetUserId != "" {
+		command.Command = CommandTransfer
+		command.TargetId = job.TargetUserId
+	}
+	err := handler.ledger.Remove(GetUserCommandKey(command))
+	if err != nil {
+		return err
+	}
+	return handler.sendUsersEvent(GetUserCommandKey(command), command)
+}
+
+// ForceDeleteUser publishes a forced DELETE command, which is executed even if the user is already deleted
+// according to the ledger or keycloak; used to reconcile resources left behind in services
+func (handler *EventHandler) ForceDeleteUser(id string, requester Requester, policies map[string]string) error {
+	err := ValidateResourcePolicies(policies)
+	if err != nil {
+		return err
 	}
-	return handler.sendUsersEvent("DELETE_"+id, UserCommandMsg{
-		Command: "DELETE",
-		Id:      id,
+	entry := NewAuditEntry(AuditSourceApi, requester, id, "")
+	err = handler.audits.Set(entry.Id, entry)
+	if err != nil {
+		return err
+	}
+	command := UserCommandMsg{
+		Command:  CommandDelete,
+		Id:       id,
+		AuditId:  entry.Id,
+		Force:    true,
+		Policies: policies,
+	}
+	return handler.sendUsersEvent(GetUserCommandKey(command), command)
+}
+
+func (handler *EventHandler) CancelDeletion(ctx context.Context, id string) error {
+	return handler.cancelDeletion(id, func() error {
+		return CancelScheduledDeletion(ctx, id, handler.conf, handler.jobs)
+	})
+}
+
+// CancelDeletionWithToken cancels the scheduled deletion of the user with the cancel token returned by the deletion request
+func (handler *EventHandler) CancelDeletionWithToken(ctx context.Context, id string, cancelToken string) error {
+	return handler.cancelDeletion(id, func() error {
+		return CancelScheduledDeletionWithToken(ctx, id, cancelToken, handler.conf, handler.jobs)
 	})
 }
 
-func (handler *EventHandler) handleUserCommand(_ string, msg []byte, _ time.Time) (err error) {
+// cancelDeletion calls cancel and records the cancellation in the audit entry of the job
+func (handler *EventHandler) cancelDeletion(id string, cancel func() error) error {
+	job, _, err := handler.jobs.Get(id)
+	if err != nil {
+		return err
+	}
+	err = cancel()
+	if err != nil {
+		return err
+	}
+	if job.AuditId != "" {
+		err = SetAuditState(handler.audits, job.AuditId, AuditCanceled)
+		if err != nil {
+			log.Println("ERROR: unable to record canceled deletion in audit log", id, err)
+		}
+	}
+	return nil
+}
+
+func (handler *EventHandler) PreviewBulkDeletion(ctx context.Context, request BulkDeletionRequest, requesterId string) (BulkDeletionPreview, error) {
+	return ResolveBulkDeletion(ctx, request, requesterId, handler.conf)
+}
+
+// BulkDelete requests the deletion of every user selected by the request
+func (handler *EventHandler) BulkDelete(ctx context.Context, request BulkDeletionRequest, requester Requester) (DeletionBatch, error) {
+	preview, err := ResolveBulkDeletion(ctx, request, requester.Id, handler.conf)
+	if err != nil {
+		return DeletionBatch{}, err
+	}
+	return CreateDeletionBatch(preview.Users, requester.Id, handler.batches, func(userId string) error {
+		_, err := handler.requestDeletion(ctx, userId, "", requester, nil)
+		return err
+	})
+}
+
+func (handler *EventHandler) GetDeletionBatchProgress(id string) (DeletionBatchProgress, error) {
+	return GetDeletionBatchProgress(handler.batches, handler.jobs, id)
+}
+
+func (handler *EventHandler) checkInactiveUsers(ctx context.Context, now time.Time) {
+	report, err := ReapInactiveUsers(ctx, handler.conf, handler.inactiveUsers, now, func(userId string) error {
+		_, err := handler.requestDeletion(ctx, userId, "", Requester{Id: InactiveUserReaperId, Admin: true}, nil)
+		return err
+	})
+	if err != nil {
+		log.Println("ERROR: unable to check inactive users", err)
+		return
+	}
+	log.Printf("inactive users (dry run: %v): %v flagged, %v deletions requested, %v unflagged, %v errors\n", report.DryRun, len(report.Flagged), len(report.Deleted), len(report.Unflagged), len(report.Errors))
+	handler.inactiveReportMux.Lock()
+	defer handler.inactiveReportMux.Unlock()
+	handler.inactiveReport = &report
+}
+
+func (handler *EventHandler) ListInactiveUsers() ([]InactiveUser, error) {
+	return handler.inactiveUsers.List()
+}
+
+// GetInactiveUserReport returns the report of the last inactive user check; nil if no check finished yet
+func (handler *EventHandler) GetInactiveUserReport() *InactiveUserReport {
+	handler.inactiveReportMux.Lock()
+	defer handler.inactiveReportMux.Unlock()
+	return handler.inactiveReport
+}
+
+// ScanOrphans reports owners of resources without keycloak user; with enqueue, a forced deletion is requested for each of them
+func (handler *EventHandler) ScanOrphans(ctx context.Context, enqueue bool, requester Requester) (report OrphanScanReport, err error) {
+	var enqueueCleanup func(userId string) error
+	if enqueue {
+		enqueueCleanup = func(userId string) error {
+			return handler.ForceDeleteUser(userId, requester, nil)
+		}
+	}
+	report, err = ScanOrphans(ctx, handler.conf, enqueueCleanup)
+	if err != nil {
+		return report, err
+	}
+	log.Printf("orphan scan: %v orphaned owners, %v cleanups enqueued, %v errors\n", len(report.Orphans), len(report.Enqueued), len(report.Errors))
+	handler.orphanReportMux.Lock()
+	defer handler.orphanReportMux.Unlock()
+	handler.orphanReport = &report
+	return report, nil
+}
+
+// GetOrphanScanReport returns the report of the last orphan scan; nil if no scan finished yet
+func (handler *EventHandler) GetOrphanScanReport() *OrphanScanReport {
+	handler.orphanReportMux.Lock()
+	defer handler.orphanReportMux.Unlock()
+	return handler.orphanReport
+}
+
+func (handler *EventHandler) ListAuditEntries(filter AuditFilter) ([]AuditEntry, error) {
+	return ListAuditEntries(handler.audits, filter)
+}
+
+func (handler *EventHandler) ListScheduledDeletions() ([]DeletionJob, error) {
+	return ListScheduledDeletions(handler.jobs)
+}
+
+func (handler *EventHandler) GetDeletionJob(id string) (job DeletionJob, exists bool, err error) {
+	return handler.jobs.Get(id)
+}
+
+func (handler *EventHandler) ListDeletionJobs() ([]DeletionJob, error) {
+	return handler.jobs.List()
+}
+
+func (handler *EventHandler) handleUserCommand(ctx context.Context, _ string, msg []byte, msgTime time.Time) (err error) {
 	log.Println(handler.conf.UserTopic, string(msg))
-	command := UserCommandMsg{}
+	if len(msg) == 0 {
+		return nil //tombstone of the compacted topic
+	}
+	command, err := ParseUserCommand(msg)
+	if err != nil {
+		return err
+	}
+	held, err := HoldForGracePeriod(ctx, command, msgTime, handler.gracePeriod, handler.conf, handler.jobs, handler.audits)
+	if err != nil || held {
+		return err
+	}
+	skipped, err := RunWithLedger(handler.ledger, command, func() error {
+		return RunAudited(handler.audits, handler.jobs, command, msgTime, func() error {
+			if command.Command == CommandTransfer {
+				return TransferUser(ctx, command.Id, command.TargetId, handler.conf, handler.jobs, handler.publisher())
+			}
+			return DeleteUser(ctx, command.Id, MergeResourcePolicies(handler.conf, command.Policies), handler.jobs, handler.publisher())
+		})
+	})
+	if err != nil || skipped || !handler.conf.UserCommandTombstones {
+		return err
+	}
+	//remove the completed command from the compacted topic
+	err = handler.usersProducer.Produce([]byte(GetUserCommandKey(command)), nil)
+	if err != nil {
+		log.Println("WARNING: unable to write tombstone for user command", GetUserCommandKey(command), err)
+	}
+	return nil
+}
+
+// ParseUserCommand returns errors wrapping kafka.ErrPoisonMessage for messages, which are unable to be handled
+func ParseUserCommand(msg []byte) (command UserCommandMsg, err error) {
 	err = json.Unmarshal(msg, &command)
 	if err != nil {
-		return
+		return command, poison(err)
 	}
 	switch command.Command {
-	case "DELETE":
-		return DeleteUser(command.Id, handler.conf)
+	case CommandDelete:
+	case CommandTransfer:
+		if command.TargetId == "" || command.TargetId == command.Id {
+			return command, poison(errors.New("invalid transfer target"))
+		}
+	default:
+		return command, poison(fmt.Errorf("%w: %v", errUnknownCommand, command.Command))
+	}
+	if command.Id == "" {
+		return command, poison(errors.New("missing user id"))
+	}
+	//invalid overrides would fall back to the configured policies, which may delete resources the producer wanted to keep
+	err = ValidateResourcePolicies(command.Policies)
+	if err != nil {
+		return command, poison(fmt.Errorf("invalid policies: %w", err))
+	}
+	return command, nil
+}
+
+// handleDeadLetter writes the letter to the dead-letter topic and keeps it for replays
+func (handler *EventHandler) handleDeadLetter(letter kafka.DeadLetter) error {
+	err := handler.deadLetterProducer.ProduceDeadLetter(letter)
+	if err != nil {
+		return err
+	}
+	deadLetter := NewDeadLetter(letter)
+	return handler.deadLetters.Set(deadLetter.Id, deadLetter)
+}
+
+var ErrDeadLettersDisabled = errors.New("no dead-letter topic configured")
+
+func (handler *EventHandler) ListDeadLetters() ([]DeadLetter, error) {
+	if handler.deadLetters == nil {
+		return nil, ErrDeadLettersDisabled
+	}
+	return handler.deadLetters.List()
+}
+
+// ReplayDeadLetters publishes the dead letters with the given ids (all, if empty) to the user topic again
+func (handler *EventHandler) ReplayDeadLetters(ids []string) ([]DeadLetter, error) {
+	if handler.deadLetters == nil {
+		return nil, ErrDeadLettersDisabled
+	}
+	return ReplayDeadLetters(handler.deadLetters, ids, handler.usersProducer.Produce)
+}
+
+// RequestDataExport creates a data export of the user, which is generated in the background
+func (handler *EventHandler) RequestDataExport(ctx context.Context, userId string, requesterId string) (DataExport, error) {
+	user, err := GetUserById(ctx, userId, handler.conf)
+	if err != nil {
+		return DataExport{}, err
+	}
+	if user.Id != userId {
+		return DataExport{}, errors.New("no matching user found")
+	}
+	export := NewDataExport(userId, requesterId)
+	err = handler.exports.Set(export.Id, export)
+	if err != nil {
+		return export, err
+	}
+	go handler.runDataExport(export.Id)
+	return export, nil
+}
+
+func (handler *EventHandler) runDataExport(id string) {
+	err := RunDataExport(handler.ctx, id, handler.conf, handler.exports)
+	if err != nil {
+		log.Println("ERROR: data export failed", id, err)
+	}
+}
+
+// resumeDataExports restarts exports, which were interrupted by a restart
+func (handler *EventHandler) resumeDataExports() error {
+	exports, err := handler.exports.List()
+	if err != nil {
+		return err
+	}
+	for _, export := range exports {
+		if export.State == DataExportPending || export.State == DataExportRunning {
+			go handler.runDataExport(export.Id)
+		}
+	}
+	return nil
+}
+
+func (handler *EventHandler) GetDataExport(id string) (export DataExport, exists bool, err error) {
+	return handler.exports.Get(id)
+}
+
+// GetDataExportLocation returns the archive location of a finished and not expired export
+func (handler *EventHandler) GetDataExportLocation(export DataExport) (location string, err error) {
+	if export.State != DataExportDone || export.Expires == nil || export.Expires.Before(time.Now()) {
+		return "", ErrDataExportNotFound
 	}
-	return errors.New("unable to handle permission command: " + string(msg))
+	return GetDataExportLocation(handler.conf, export.Id), nil
 }
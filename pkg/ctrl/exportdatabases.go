@@ -17,6 +17,7 @@
 package ctrl
 
 import (
+	"context"
 	"errors"
 	"github.com/SENERGY-Platform/user-management/pkg/configuration"
 	"io"
@@ -24,70 +25,130 @@ import (
 	"strconv"
 )
 
-func DeleteExportDatabasesUser(token Token, conf configuration.Config) error {
-	if conf.DatabaseExportsUrl == "" || conf.DatabaseExportsUrl == "-" {
-		return nil
-	}
+// ExportDatabasesCleaner handles the export database metadata of the user by the policies of PolicyResourceExportDatabases
+// (by default removed, if RemoveExportDatabaseMetadataOnUserDelete is set) and PolicyResourcePublicExportDatabases (by default kept)
+type ExportDatabasesCleaner struct{}
 
-	loopLimit := 10000
-	loopCount := 0
-	offset := 0
-	for {
-		toBeRemoved, publicIds, err := getBatchOfExportDatabasesIds(token, conf, BatchSize, offset)
-		if err != nil {
-			return err
-		}
-		if len(toBeRemoved) == 0 && len(publicIds) == 0 {
-			return nil
-		}
+func (this ExportDatabasesCleaner) Name() string {
+	return "export-databases"
+}
 
-		err = deleteBatchOfExportDatabases(token, conf, toBeRemoved)
-		if err != nil {
-			return err
+func (this ExportDatabasesCleaner) Enabled(conf configuration.Config) bool {
+	return isSet(conf.DatabaseExportsUrl)
+}
+
+func (this ExportDatabasesCleaner) List(ctx context.Context, token Token, conf configuration.Config) ([]ServiceResources, error) {
+	result := ServiceResources{Resource: "databases"}
+	idsByResource, foreignIds, err := getExportDatabaseIds(token, conf)
+	if err != nil {
+		return []ServiceResources{result}, err
+	}
+	for _, id := range foreignIds {
+		result.Kept = append(result.Kept, KeptResource{Id: id, Reason: "public export database of another user"})
+	}
+	for _, resource := range []string{PolicyResourceExportDatabases, PolicyResourcePublicExportDatabases} {
+		reason, kept := getPolicyKeepReason(conf, resource)
+		for _, id := range idsByResource[resource] {
+			if kept {
+				result.Kept = append(result.Kept, KeptResource{Id: id, Reason: reason})
+			} else {
+				result.Ids = append(result.Ids, id)
+			}
 		}
+	}
+	return []ServiceResources{result}, nil
+}
 
-		offset = offset + len(publicIds)
+func (this ExportDatabasesCleaner) Delete(ctx context.Context, token Token, conf configuration.Config) (deleted []string, err error) {
+	deleted, _, _, err = this.DeleteWithPolicies(ctx, token, nil, conf)
+	return deleted, err
+}
 
-		loopCount = loopCount + 1
-		if loopCount == loopLimit {
-			return errors.New("DeleteDatabaseExportUser() reach loop limit")
-		}
+func (this ExportDatabasesCleaner) DeleteWithPolicies(ctx context.Context, token Token, copies *CopyLog, conf configuration.Config) (deleted []string, transferred []string, decisions []PolicyDecision, err error) {
+	idsByResource, _, err := getExportDatabaseIds(token, conf)
+	if err != nil {
+		return deleted, transferred, decisions, err
+	}
+	errs := []error{}
+	for _, resource := range []string{PolicyResourceExportDatabases, PolicyResourcePublicExportDatabases} {
+		resourceDeleted, resourceTransferred, resourceDecisions, err := applyResourcePolicies(conf, copies, this.Name(), resource, idsByResource[resource], func(id string) error {
+			return deleteExportDatabase(token, conf, id)
+		}, func(target Token, id string) error {
+			return copyResource(token, target, conf.DatabaseExportsUrl+"/databases", id, "ID", "UserId")
+		})
+		deleted = append(deleted, resourceDeleted...)
+		transferred = append(transferred, resourceTransferred...)
+		decisions = append(decisions, resourceDecisions...)
+		errs = append(errs, err)
 	}
+	return deleted, transferred, decisions, errors.Join(errs...)
+}
 
+func (this ExportDatabasesCleaner) Export(ctx context.Context, token Token, conf configuration.Config) (files map[string]interface{}, err error) {
+	databases, err := getAllRawPages(token, conf.DatabaseExportsUrl+"/databases", "")
+	return map[string]interface{}{"databases": filterRawByOwner(databases, "UserId", token.GetUserId())}, err
 }
 
-func deleteBatchOfExportDatabases(token Token, conf configuration.Config, ids []string) error {
-	for _, id := range ids {
-		resp, err := token.Impersonate().Delete(conf.DatabaseExportsUrl+"/databases/"+url.PathEscape(id), nil)
-		if err != nil {
-			return err
-		}
-		defer resp.Body.Close()
-		if resp.StatusCode >= 300 {
-			temp, _ := io.ReadAll(resp.Body)
-			return errors.New("deleteBatchOfExportDatabases(): " + string(temp))
-		}
+func (this ExportDatabasesCleaner) Verify(ctx context.Context, token Token, conf configuration.Config) (remaining []string, err error) {
+	return verifyByList(ctx, this, token, conf)
+}
+
+func deleteExportDatabase(token Token, conf configuration.Config, id string) error {
+	resp, err := token.Impersonate().Delete(conf.DatabaseExportsUrl+"/databases/"+url.PathEscape(id), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		temp, _ := io.ReadAll(resp.Body)
+		return errors.New("deleteExportDatabase(): " + string(temp))
 	}
 	return nil
 }
 
-func getBatchOfExportDatabasesIds(token Token, config configuration.Config, limit int, offset int) (toBeRemoved []string, publicIds []string, err error) {
-	query := url.Values{}
-	query.Add("limit", strconv.Itoa(limit))
-	query.Add("offset", strconv.Itoa(offset))
-	temp := []ExportDatabase{}
-	err = token.Impersonate().GetJSON(config.DatabaseExportsUrl+"/databases?"+query.Encode(), &temp)
+func getExportDatabasePolicyResource(database ExportDatabase) string {
+	if database.Public {
+		return PolicyResourcePublicExportDatabases
+	}
+	return PolicyResourceExportDatabases
+}
+
+// getExportDatabaseIds returns the ids of the export databases of the token user by policy resource and the ids of public
+// export databases of other users, which are never touched. List and DeleteWithPolicies use it, so the preview reports exactly what a deletion handles.
+func getExportDatabaseIds(token Token, config configuration.Config) (idsByResource map[string][]string, foreignIds []string, err error) {
+	idsByResource = map[string][]string{}
+	databases, err := getExportDatabases(token, config)
 	if err != nil {
-		return toBeRemoved, publicIds, err
+		return idsByResource, foreignIds, err
 	}
-	for _, element := range temp {
-		if element.Public {
-			publicIds = append(publicIds, element.ID)
-		} else {
-			toBeRemoved = append(toBeRemoved, element.ID)
+	for _, element := range databases {
+		if element.Public && element.UserId != token.GetUserId() {
+			foreignIds = append(foreignIds, element.ID)
+			continue
+		}
+		resource := getExportDatabasePolicyResource(element)
+		idsByResource[resource] = append(idsByResource[resource], element.ID)
+	}
+	return idsByResource, foreignIds, nil
+}
+
+func getExportDatabases(token Token, config configuration.Config) (result []ExportDatabase, err error) {
+	loopLimit := 10000
+	for loopCount := 0; loopCount < loopLimit; loopCount++ {
+		query := url.Values{}
+		query.Add("limit", strconv.Itoa(BatchSize))
+		query.Add("offset", strconv.Itoa(len(result)))
+		temp := []ExportDatabase{}
+		err = token.Impersonate().GetJSON(config.DatabaseExportsUrl+"/databases?"+query.Encode(), &temp)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, temp...)
+		if len(temp) < BatchSize {
+			return result, nil
 		}
 	}
-	return toBeRemoved, publicIds, err
+	return result, errors.New("getExportDatabases() reach loop limit")
 }
 
 type ExportDatabaseRequest struct {
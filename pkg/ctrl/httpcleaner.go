@@ -0,0 +1,234 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctrl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	HttpCleanerPaginationNone        = "none"
+	HttpCleanerPaginationLimitOffset = "limit-offset"
+	HttpCleanerDeleteSingle          = "single"
+	HttpCleanerDeleteBatch           = "batch"
+)
+
+// HttpCleaner is a ServiceCleaner described by a configuration.HttpCleanerConfig
+type HttpCleaner struct {
+	Config configuration.HttpCleanerConfig
+}
+
+func ValidateHttpCleaners(conf configuration.Config) error {
+	names := map[string]bool{}
+	cleanersMux.Lock()
+	for _, name := range getRegisteredCleanerNames() {
+		names[name] = true
+	}
+	cleanersMux.Unlock()
+	for _, config := range conf.HttpCleaners {
+		if config.Name == "" {
+			return errors.New("missing name in HttpCleaners")
+		}
+		if names[config.Name] {
+			return errors.New("duplicate cleaner name in HttpCleaners: " + config.Name)
+		}
+		names[config.Name] = true
+		if config.ListUrl == "" || config.DeleteUrl == "" {
+			return errors.New("missing ListUrl or DeleteUrl in HttpCleaners: " + config.Name)
+		}
+		switch config.Pagination {
+		case "", HttpCleanerPaginationNone, HttpCleanerPaginationLimitOffset:
+		default:
+			return errors.New("unknown Pagination in HttpCleaners: " + config.Name)
+		}
+		switch config.DeleteMode {
+		case "", HttpCleanerDeleteSingle:
+			if !strings.Contains(config.DeleteUrl, "{id}") {
+				return errors.New("missing {id} in DeleteUrl of HttpCleaners: " + config.Name)
+			}
+		case HttpCleanerDeleteBatch:
+		default:
+			return errors.New("unknown DeleteMode in HttpCleaners: " + config.Name)
+		}
+	}
+	return nil
+}
+
+func (this HttpCleaner) Name() string {
+	return this.Config.Name
+}
+
+func (this HttpCleaner) Enabled(conf configuration.Config) bool {
+	return isSet(this.Config.ListUrl) && isSet(this.Config.DeleteUrl)
+}
+
+func (this HttpCleaner) List(ctx context.Context, token Token, conf configuration.Config) ([]ServiceResources, error) {
+	ids, kept, err := this.list(token)
+	return []ServiceResources{{Resource: this.Config.Name, Ids: ids, Kept: kept}}, err
+}
+
+func (this HttpCleaner) Delete(ctx context.Context, token Token, conf configuration.Config) (deleted []string, err error) {
+	ids, _, err := this.list(token)
+	if err != nil {
+		return deleted, err
+	}
+	if this.Config.DeleteMode == HttpCleanerDeleteBatch {
+		pageSize := this.pageSize()
+		for start := 0; start < len(ids); start += pageSize {
+			batch := ids[start:min(start+pageSize, len(ids))]
+			resp, err := token.Impersonate().DeleteWithBody(this.Config.DeleteUrl, batch)
+			if err != nil {
+				return deleted, err
+			}
+			resp.Body.Close()
+			deleted = append(deleted, batch...)
+		}
+		return deleted, nil
+	}
+	return forEachParallel(ids, getServiceWorkers(conf, this.Name()), func(id string) error {
+		resp, err := token.Impersonate().Delete(strings.ReplaceAll(this.Config.DeleteUrl, "{id}", url.PathEscape(id)), nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	})
+}
+
+func (this HttpCleaner) Verify(ctx context.Context, token Token, conf configuration.Config) (remaining []string, err error) {
+	return verifyByList(ctx, this, token, conf)
+}
+
+// ListOwners requests the OwnersUrl, which returns a json array of owner ids, with an admin token
+func (this HttpCleaner) ListOwners(ctx context.Context, conf configuration.Config) (owners []string, ok bool, err error) {
+	if !isSet(this.Config.OwnersUrl) {
+		return owners, false, nil
+	}
+	token, err := createOwnerListingToken()
+	if err != nil {
+		return owners, true, err
+	}
+	token = token.WithContext(ctx)
+	err = token.Impersonate().GetJSON(this.Config.OwnersUrl, &owners)
+	return owners, true, err
+}
+
+func (this HttpCleaner) pageSize() int {
+	if this.Config.PageSize > 0 {
+		return this.Config.PageSize
+	}
+	return BatchSize
+}
+
+func (this HttpCleaner) list(token Token) (ids []string, kept []KeptResource, err error) {
+	if this.Config.Pagination != HttpCleanerPaginationLimitOffset {
+		elements, err := this.getElements(token, this.Config.ListUrl)
+		if err != nil {
+			return ids, kept, err
+		}
+		return this.filterElements(token, elements)
+	}
+	loopLimit := 10000
+	offset := 0
+	limit := this.pageSize()
+	for loopCount := 0; loopCount < loopLimit; loopCount++ {
+		listUrl, err := url.Parse(this.Config.ListUrl)
+		if err != nil {
+			return ids, kept, err
+		}
+		query := listUrl.Query()
+		query.Set("limit", strconv.Itoa(limit))
+		query.Set("offset", strconv.Itoa(offset))
+		listUrl.RawQuery = query.Encode()
+		elements, err := this.getElements(token, listUrl.String())
+		if err != nil {
+			return ids, kept, err
+		}
+		pageIds, pageKept, err := this.filterElements(token, elements)
+		if err != nil {
+			return ids, kept, err
+		}
+		ids = append(ids, pageIds...)
+		kept = append(kept, pageKept...)
+		if len(elements) < limit {
+			return ids, kept, nil
+		}
+		offset = offset + len(elements)
+	}
+	return ids, kept, errors.New("HttpCleaner.list() reach loop limit: " + this.Config.Name)
+}
+
+func (this HttpCleaner) getElements(token Token, listUrl string) (elements []interface{}, err error) {
+	var temp interface{}
+	err = token.Impersonate().GetJSON(listUrl, &temp)
+	if err != nil {
+		return elements, err
+	}
+	if this.Config.ListPath != "" {
+		for _, key := range strings.Split(this.Config.ListPath, ".") {
+			obj, ok := temp.(map[string]interface{})
+			if !ok {
+				return elements, errors.New("unexpected list response: " + this.Config.ListPath + " is not reachable")
+			}
+			temp = obj[key]
+		}
+	}
+	if temp == nil {
+		return elements, nil
+	}
+	elements, ok := temp.([]interface{})
+	if !ok {
+		return elements, errors.New("unexpected list response: expected array")
+	}
+	return elements, nil
+}
+
+func (this HttpCleaner) filterElements(token Token, elements []interface{}) (ids []string, kept []KeptResource, err error) {
+	idField := this.Config.IdField
+	if idField == "" {
+		idField = "id"
+	}
+	for _, element := range elements {
+		obj, ok := element.(map[string]interface{})
+		if !ok {
+			return ids, kept, errors.New("unexpected list element: expected object")
+		}
+		id, ok := obj[idField]
+		if !ok || id == nil {
+			return ids, kept, errors.New("unexpected list element: missing " + idField)
+		}
+		if this.Config.OwnerField != "" && jsonValueToString(obj[this.Config.OwnerField]) != token.GetUserId() {
+			kept = append(kept, KeptResource{Id: jsonValueToString(id), Reason: "owned by another user"})
+			continue
+		}
+		ids = append(ids, jsonValueToString(id))
+	}
+	return ids, kept, nil
+}
+
+func jsonValueToString(value interface{}) string {
+	if f, ok := value.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
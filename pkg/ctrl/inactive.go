@@ -0,0 +1,338 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctrl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/store"
+	"log"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// InactiveUserReaperId is recorded as requester of deletions of inactive users
+const InactiveUserReaperId = "inactive-user-reaper"
+
+// InactiveUser is a user without login in the InactiveUserPeriod, whose deletion is requested after DeleteAfter
+type InactiveUser struct {
+	UserId            string    `json:"user_id"`
+	Username          string    `json:"username"`
+	LastActivity      time.Time `json:"last_activity"`
+	Flagged           time.Time `json:"flagged"`
+	DeleteAfter       time.Time `json:"delete_after"`
+	Notified          bool      `json:"notified"`
+	DeletionRequested bool      `json:"deletion_requested"`
+}
+
+type InactiveUserStore = store.Store[InactiveUser]
+
+// InactiveUserReport lists the results of a check
+type InactiveUserReport struct {
+	Time      time.Time      `json:"time"`
+	DryRun    bool           `json:"dry_run"`
+	Flagged   []InactiveUser `json:"flagged"`   //newly flagged users
+	Deleted   []InactiveUser `json:"deleted"`   //users with requested deletion
+	Unflagged []string       `json:"unflagged"` //previously flagged users, which are active or excluded again
+	Errors    []string       `json:"errors,omitempty"`
+}
+
+type InactiveUserSettings struct {
+	Period         time.Duration
+	DeletionDelay  time.Duration
+	DryRun         bool
+	Notify         bool
+	ExcludedGroups []string
+	ExcludedRoles  []string
+}
+
+// GetInactiveUserCheckInterval returns 0 if the check is disabled
+func GetInactiveUserCheckInterval(conf configuration.Config) (time.Duration, error) {
+	if conf.InactiveUserCheckInterval == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(conf.InactiveUserCheckInterval)
+}
+
+func GetInactiveUserSettings(conf configuration.Config) (settings InactiveUserSettings, err error) {
+	settings = InactiveUserSettings{
+		DryRun:         conf.InactiveUserDryRun,
+		Notify:         conf.InactiveUserNotify && isSet(conf.NotifierUrl),
+		ExcludedGroups: conf.InactiveUserExcludedGroups,
+		ExcludedRoles:  conf.InactiveUserExcludedRoles,
+	}
+	settings.Period, err = time.ParseDuration(conf.InactiveUserPeriod)
+	if err != nil {
+		return settings, fmt.Errorf("invalid InactiveUserPeriod: %w", err)
+	}
+	if settings.Period <= 0 {
+		return settings, errors.New("InactiveUserPeriod has to be positive")
+	}
+	settings.DeletionDelay, err = time.ParseDuration(conf.InactiveUserDeletionDelay)
+	if err != nil {
+		return settings, fmt.Errorf("invalid InactiveUserDeletionDelay: %w", err)
+	}
+	return settings, nil
+}
+
+// ReapInactiveUsers flags users without activity in the configured period, notifies them and calls requestDeletion
+// for flagged users after the deletion delay. Users with new activity or exclusions are unflagged.
+// In dry run mode the report is created without notifications, deletions or changes of the store.
+func ReapInactiveUsers(ctx context.Context, conf configuration.Config, inactive InactiveUserStore, now time.Time, requestDeletion func(userId string) error) (report InactiveUserReport, err error) {
+	settings, err := GetInactiveUserSettings(conf)
+	if err != nil {
+		return report, err
+	}
+	report = InactiveUserReport{Time: now, DryRun: settings.DryRun, Flagged: []InactiveUser{}, Deleted: []InactiveUser{}, Unflagged: []string{}}
+	err = CheckRealmEventSettings(ctx, settings.Period, conf)
+	if err != nil {
+		if !settings.DryRun {
+			log.Println("ERROR: refusing to flag or delete inactive users:", err)
+			return report, err
+		}
+		log.Println("ERROR: inactive user report is unreliable:", err)
+		report.Errors = append(report.Errors, err.Error())
+	}
+	users, err := GetUsers(ctx, "", conf)
+	if err != nil {
+		return report, err
+	}
+	known := map[string]bool{}
+	for _, user := range users {
+		known[user.Id] = true
+		err = reapInactiveUser(ctx, user, settings, conf, inactive, now, requestDeletion, &report)
+		if err != nil {
+			log.Println("ERROR: unable to check inactive user", user.Id, err)
+			report.Errors = append(report.Errors, user.Id+": "+err.Error())
+		}
+	}
+	if settings.DryRun {
+		return report, nil
+	}
+	//flags of deleted users are no longer needed
+	flags, err := inactive.List()
+	if err != nil {
+		return report, err
+	}
+	for _, flag := range flags {
+		if !known[flag.UserId] {
+			err = inactive.Remove(flag.UserId)
+			if err != nil {
+				return report, err
+			}
+		}
+	}
+	return report, nil
+}
+
+func reapInactiveUser(ctx context.Context, user User, settings InactiveUserSettings, conf configuration.Config, inactive InactiveUserStore, now time.Time, requestDeletion func(userId string) error, report *InactiveUserReport) error {
+	flag, flagged, err := inactive.Get(user.Id)
+	if err != nil {
+		return err
+	}
+	unflag := func() error {
+		if !flagged {
+			return nil
+		}
+		report.Unflagged = append(report.Unflagged, user.Id)
+		if settings.DryRun {
+			return nil
+		}
+		return inactive.Remove(user.Id)
+	}
+	excluded, err := isExcludedFromInactiveUserCheck(ctx, user.Id, settings, conf)
+	if err != nil {
+		return err
+	}
+	if excluded {
+		return unflag()
+	}
+	lastActivity, err := GetLastUserActivity(ctx, user.Id, conf)
+	if err != nil {
+		return err
+	}
+	if now.Sub(lastActivity) < settings.Period {
+		return unflag()
+	}
+
+	if !flagged {
+		flag = InactiveUser{
+			UserId:       user.Id,
+			Username:     user.Name,
+			LastActivity: lastActivity,
+			Flagged:      now,
+			DeleteAfter:  now.Add(settings.DeletionDelay),
+		}
+		if !settings.DryRun {
+			if settings.Notify {
+				err = NotifyInactiveUser(ctx, flag, conf)
+				if err != nil {
+					log.Println("WARNING: unable to notify inactive user", user.Id, err)
+				}
+				flag.Notified = err == nil
+			}
+			err = inactive.Set(flag.UserId, flag)
+			if err != nil {
+				return err
+			}
+		}
+		report.Flagged = append(report.Flagged, flag)
+	}
+
+	if flag.DeletionRequested || now.Before(flag.DeleteAfter) {
+		return nil
+	}
+	report.Deleted = append(report.Deleted, flag)
+	if settings.DryRun {
+		return nil
+	}
+	err = requestDeletion(user.Id)
+	if err != nil {
+		return err
+	}
+	flag.DeletionRequested = true
+	return inactive.Set(flag.UserId, flag)
+}
+
+func isExcludedFromInactiveUserCheck(ctx context.Context, userId string, settings InactiveUserSettings, conf configuration.Config) (bool, error) {
+	if len(settings.ExcludedGroups) > 0 {
+		groups, err := GetUsersGroups(ctx, userId, conf)
+		if err != nil {
+			return false, err
+		}
+		for _, group := range groups {
+			if slices.Contains(settings.ExcludedGroups, group.Name) {
+				return true, nil
+			}
+		}
+	}
+	if len(settings.ExcludedRoles) > 0 {
+		roles, err := GetUserRealmRoles(ctx, userId, conf)
+		if err != nil {
+			return false, err
+		}
+		for _, role := range roles {
+			if slices.Contains(settings.ExcludedRoles, role) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// CheckRealmEventSettings returns an error if keycloak does not store LOGIN events of the realm for at least the period.
+// Without them GetLastUserActivity falls back to the creation time, which flags every user without active session.
+func CheckRealmEventSettings(ctx context.Context, period time.Duration, conf configuration.Config) error {
+	token, err := EnsureAccess(ctx, conf)
+	if err != nil {
+		return err
+	}
+	eventsConfig := struct {
+		EventsEnabled     bool     `json:"eventsEnabled"`
+		EventsExpiration  int64    `json:"eventsExpiration"` //seconds; 0 keeps events forever
+		EnabledEventTypes []string `json:"enabledEventTypes"`
+	}{}
+	err = token.GetJSON(conf.KeycloakUrl+"/auth/admin/realms/"+conf.KeycloakRealm+"/events/config", &eventsConfig)
+	if err != nil {
+		return fmt.Errorf("unable to load event settings of realm %v: %w", conf.KeycloakRealm, err)
+	}
+	if !eventsConfig.EventsEnabled {
+		return fmt.Errorf("realm %v does not store user events", conf.KeycloakRealm)
+	}
+	if len(eventsConfig.EnabledEventTypes) > 0 && !slices.Contains(eventsConfig.EnabledEventTypes, "LOGIN") {
+		return fmt.Errorf("realm %v does not store LOGIN events", conf.KeycloakRealm)
+	}
+	if expiration := time.Duration(eventsConfig.EventsExpiration) * time.Second; expiration > 0 && expiration < period {
+		return fmt.Errorf("events of realm %v expire after %v, which is shorter than InactiveUserPeriod %v", conf.KeycloakRealm, expiration, period)
+	}
+	return nil
+}
+
+// GetUserRealmRoles returns the names of the effective realm roles of the user
+func GetUserRealmRoles(ctx context.Context, userId string, conf configuration.Config) (roles []string, err error) {
+	token, err := EnsureAccess(ctx, conf)
+	if err != nil {
+		return roles, err
+	}
+	temp := []struct {
+		Name string `json:"name"`
+	}{}
+	err = token.GetJSON(conf.KeycloakUrl+"/auth/admin/realms/"+conf.KeycloakRealm+"/users/"+url.QueryEscape(userId)+"/role-mappings/realm/composite", &temp)
+	for _, role := range temp {
+		roles = append(roles, role.Name)
+	}
+	return roles, err
+}
+
+// GetLastUserActivity returns the latest of the creation time, the last login event and the last access of an active session
+func GetLastUserActivity(ctx context.Context, userId string, conf configuration.Config) (last time.Time, err error) {
+	token, err := EnsureAccess(ctx, conf)
+	if err != nil {
+		return last, err
+	}
+	baseUrl := conf.KeycloakUrl + "/auth/admin/realms/" + conf.KeycloakRealm
+	user := struct {
+		CreatedTimestamp int64 `json:"createdTimestamp"`
+	}{}
+	err = token.GetJSON(baseUrl+"/users/"+url.QueryEscape(userId), &user)
+	if err != nil {
+		return last, err
+	}
+	timestamps := []int64{user.CreatedTimestamp}
+
+	events := []struct {
+		Time int64 `json:"time"`
+	}{}
+	err = token.GetJSON(baseUrl+"/events?type=LOGIN&max=1&user="+url.QueryEscape(userId), &events)
+	if err != nil {
+		return last, err
+	}
+	for _, event := range events {
+		timestamps = append(timestamps, event.Time)
+	}
+
+	sessions := []struct {
+		LastAccess int64 `json:"lastAccess"`
+	}{}
+	err = token.GetJSON(baseUrl+"/users/"+url.QueryEscape(userId)+"/sessions", &sessions)
+	if err != nil {
+		return last, err
+	}
+	for _, session := range sessions {
+		timestamps = append(timestamps, session.LastAccess)
+	}
+	return time.UnixMilli(slices.Max(timestamps)), nil
+}
+
+// NotifyInactiveUser creates a notification for the user with the notifier service
+func NotifyInactiveUser(ctx context.Context, user InactiveUser, conf configuration.Config) error {
+	token, err := CreateToken("users-service", user.UserId)
+	if err != nil {
+		return err
+	}
+	token = token.WithContext(ctx)
+	return token.Impersonate().PostJSON(conf.NotifierUrl+"/notifications", map[string]interface{}{
+		"userId": user.UserId,
+		"title":  "Inactive Account",
+		"message": fmt.Sprintf("Your account has not been used since %v and will be deleted after %v. Log in to keep your account.",
+			user.LastActivity.Format(time.DateOnly), user.DeleteAfter.Format(time.DateOnly)),
+		"isRead": false,
+	}, nil)
+}
@@ -18,6 +18,7 @@ package ctrl
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"github.com/SENERGY-Platform/user-management/pkg/configuration"
@@ -27,6 +28,7 @@ import (
 	"net/http"
 	"runtime/debug"
 	"strings"
+	"sync"
 	"time"
 
 	"net/url"
@@ -35,10 +37,32 @@ import (
 type JwtImpersonate struct {
 	Token   string
 	XUserId string
+	ctx     context.Context
+}
+
+// httpClient is used for all requests of JwtImpersonate; its timeout is set by SetHttpTimeout
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// SetHttpTimeout sets the timeout of single requests to downstream services and keycloak; 0 disables the timeout
+func SetHttpTimeout(timeout time.Duration) {
+	httpClient = &http.Client{Timeout: timeout}
+}
+
+// WithContext returns a copy, whose requests are canceled with ctx
+func (this JwtImpersonate) WithContext(ctx context.Context) JwtImpersonate {
+	this.ctx = ctx
+	return this
+}
+
+func (this JwtImpersonate) context() context.Context {
+	if this.ctx == nil {
+		return context.Background()
+	}
+	return this.ctx
 }
 
 func (this JwtImpersonate) Post(url string, contentType string, body io.Reader) (resp *http.Response, err error) {
-	req, err := http.NewRequest("POST", url, body)
+	req, err := http.NewRequestWithContext(this.context(), "POST", url, body)
 	if err != nil {
 		return nil, err
 	}
@@ -48,7 +72,7 @@ func (this JwtImpersonate) Post(url string, contentType string, body io.Reader)
 		req.Header.Set("X-UserId", this.XUserId)
 	}
 
-	resp, err = http.DefaultClient.Do(req)
+	resp, err = httpClient.Do(req)
 	if err != nil {
 		return
 	}
@@ -63,7 +87,7 @@ func (this JwtImpersonate) Post(url string, contentType string, body io.Reader)
 }
 
 func (this JwtImpersonate) Put(url string, contentType string, body io.Reader) (resp *http.Response, err error) {
-	req, err := http.NewRequest("PUT", url, body)
+	req, err := http.NewRequestWithContext(this.context(), "PUT", url, body)
 	if err != nil {
 		return nil, err
 	}
@@ -73,7 +97,7 @@ func (this JwtImpersonate) Put(url string, contentType string, body io.Reader) (
 		req.Header.Set("X-UserId", this.XUserId)
 	}
 
-	resp, err = http.DefaultClient.Do(req)
+	resp, err = httpClient.Do(req)
 	if err != nil {
 		return
 	}
@@ -94,9 +118,9 @@ func (this JwtImpersonate) Delete(url string, body interface{}) (resp *http.Resp
 		if err != nil {
 			return
 		}
-		req, err = http.NewRequest("DELETE", url, b)
+		req, err = http.NewRequestWithContext(this.context(), "DELETE", url, b)
 	} else {
-		req, err = http.NewRequest("DELETE", url, nil)
+		req, err = http.NewRequestWithContext(this.context(), "DELETE", url, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -106,7 +130,7 @@ func (this JwtImpersonate) Delete(url string, body interface{}) (resp *http.Resp
 		req.Header.Set("X-UserId", this.XUserId)
 	}
 
-	resp, err = http.DefaultClient.Do(req)
+	resp, err = httpClient.Do(req)
 	if err != nil {
 		return
 	}
@@ -126,7 +150,7 @@ func (this JwtImpersonate) DeleteWithBody(url string, body interface{}) (resp *h
 	if err != nil {
 		return
 	}
-	req, err := http.NewRequest("DELETE", url, b)
+	req, err := http.NewRequestWithContext(this.context(), "DELETE", url, b)
 	if err != nil {
 		return nil, err
 	}
@@ -136,7 +160,7 @@ func (this JwtImpersonate) DeleteWithBody(url string, body interface{}) (resp *h
 		req.Header.Set("X-UserId", this.XUserId)
 	}
 
-	resp, err = http.DefaultClient.Do(req)
+	resp, err = httpClient.Do(req)
 	if err != nil {
 		return
 	}
@@ -185,7 +209,7 @@ func (this JwtImpersonate) PutJSON(url string, body interface{}, result interfac
 }
 
 func (this JwtImpersonate) Get(url string) (resp *http.Response, err error) {
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(this.context(), "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -193,7 +217,7 @@ func (this JwtImpersonate) Get(url string) (resp *http.Response, err error) {
 	if this.XUserId != "" {
 		req.Header.Set("X-UserId", this.XUserId)
 	}
-	resp, err = http.DefaultClient.Do(req)
+	resp, err = httpClient.Do(req)
 	if err != nil {
 		return
 	}
@@ -236,41 +260,57 @@ type OpenidToken struct {
 
 var openid *OpenidToken
 
-func EnsureAccess(conf configuration.Config) (token JwtImpersonate, err error) {
+// openidMux guards openid; token requests are made while holding it, so concurrent callers share one request
+var openidMux sync.Mutex
+
+// openidRequestTimeout bounds token requests, which are detached from the context of the caller
+const openidRequestTimeout = 30 * time.Second
+
+// EnsureAccess returns the service account token of the keycloak admin api, bound to ctx.
+// The token is shared by all callers, so it is requested independently of ctx: a canceled caller does not fail or reset it for others.
+func EnsureAccess(ctx context.Context, conf configuration.Config) (token JwtImpersonate, err error) {
+	openidMux.Lock()
+	defer openidMux.Unlock()
+	if err = ctx.Err(); err != nil {
+		return token, err
+	}
 	if openid == nil {
 		openid = &OpenidToken{}
 	}
 	duration := time.Now().Sub(openid.RequestTime).Seconds()
 
 	if openid.AccessToken != "" && openid.ExpiresIn-conf.AuthExpirationTimeBuffer > duration {
-		token = JwtImpersonate{Token: "Bearer " + openid.AccessToken}
+		token = JwtImpersonate{Token: "Bearer " + openid.AccessToken, ctx: ctx}
 		return
 	}
 
+	requestCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), openidRequestTimeout)
+	defer cancel()
+
 	if openid.RefreshToken != "" && openid.RefreshExpiresIn-conf.AuthExpirationTimeBuffer < duration {
 		log.Println("refresh token", openid.RefreshExpiresIn, duration)
-		err = refreshOpenidToken(openid, conf)
+		err = refreshOpenidToken(requestCtx, openid, conf)
 		if err != nil {
 			log.Println("WARNING: unable to use refreshtoken", err)
 		} else {
-			token = JwtImpersonate{Token: "Bearer " + openid.AccessToken}
+			token = JwtImpersonate{Token: "Bearer " + openid.AccessToken, ctx: ctx}
 			return
 		}
 	}
 
 	log.Println("get new access token")
-	err = getOpenidToken(openid, conf)
+	err = getOpenidToken(requestCtx, openid, conf)
 	if err != nil {
 		log.Println("ERROR: unable to get new access token", err)
 		openid = &OpenidToken{}
 	}
-	token = JwtImpersonate{Token: "Bearer " + openid.AccessToken}
+	token = JwtImpersonate{Token: "Bearer " + openid.AccessToken, ctx: ctx}
 	return
 }
 
-func getOpenidToken(token *OpenidToken, conf configuration.Config) (err error) {
+func getOpenidToken(ctx context.Context, token *OpenidToken, conf configuration.Config) (err error) {
 	requesttime := time.Now()
-	resp, err := http.PostForm(conf.KeycloakUrl+"/auth/realms/"+conf.KeycloakRealm+"/protocol/openid-connect/token", url.Values{
+	resp, err := postOpenidForm(ctx, conf, url.Values{
 		"client_id":     {conf.AuthClientId},
 		"client_secret": {conf.AuthClientSecret},
 		"grant_type":    {"client_credentials"},
@@ -293,9 +333,9 @@ func getOpenidToken(token *OpenidToken, conf configuration.Config) (err error) {
 	return
 }
 
-func refreshOpenidToken(token *OpenidToken, conf configuration.Config) (err error) {
+func refreshOpenidToken(ctx context.Context, token *OpenidToken, conf configuration.Config) (err error) {
 	requesttime := time.Now()
-	resp, err := http.PostForm(conf.KeycloakUrl+"/auth/realms/"+conf.KeycloakRealm+"/protocol/openid-connect/token", url.Values{
+	resp, err := postOpenidForm(ctx, conf, url.Values{
 		"client_id":     {conf.AuthClientId},
 		"client_secret": {conf.AuthClientSecret},
 		"refresh_token": {token.RefreshToken},
@@ -317,10 +357,20 @@ func refreshOpenidToken(token *OpenidToken, conf configuration.Config) (err erro
 	return
 }
 
+func postOpenidForm(ctx context.Context, conf configuration.Config, form url.Values) (resp *http.Response, err error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", conf.KeycloakUrl+"/auth/realms/"+conf.KeycloakRealm+"/protocol/openid-connect/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return httpClient.Do(req)
+}
+
 type Token struct {
 	Token       string      `json:"-"`
 	Sub         string      `json:"sub,omitempty"`
 	RealmAccess RealmAccess `json:"realm_access,omitempty"`
+	ctx         context.Context
 }
 
 type RealmAccess struct {
@@ -335,8 +385,15 @@ func (this *Token) GetUserId() string {
 	return this.Sub
 }
 
+// Impersonate returns a client for requests in the name of the token user; requests are canceled with the context of WithContext
 func (this *Token) Impersonate() JwtImpersonate {
-	return JwtImpersonate{Token: this.Token, XUserId: this.Sub}
+	return JwtImpersonate{Token: this.Token, XUserId: this.Sub, ctx: this.ctx}
+}
+
+// WithContext returns a copy of the token, whose impersonated requests are canceled with ctx
+func (this Token) WithContext(ctx context.Context) Token {
+	this.ctx = ctx
+	return this
 }
 
 func Contains(s []string, e string) bool {
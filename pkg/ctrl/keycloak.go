@@ -17,11 +17,17 @@
 package ctrl
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/SENERGY-Platform/user-management/pkg/configuration"
 	"log"
 	"net/http"
 	"net/url"
+	"slices"
+	"strings"
+	"sync"
 )
 
 type User struct {
@@ -39,40 +45,59 @@ type Group struct {
 	Path string `json:"path"`
 }
 
-//func GetUserByName(name string, conf configuration.Config) (user User, err error) {
-//	token, err := EnsureAccess(conf)
-//	if err != nil {
-//		return user, err
-//	}
-//	temp := []User{}
-//	err = token.GetJSON(conf.KeycloakUrl+"/auth/admin/realms/"+conf.KeycloakRealm+"/users?username="+url.QueryEscape(name), &temp)
-//	if err != nil {
-//		return user, err
-//	}
-//	temp = filterExact(temp, name)
-//	size := len(temp)
-//	switch {
-//	case size > 1:
-//		err = errors.New("ambiguous username")
-//	case size < 1:
-//		err = errors.New("unable to find user")
-//	default:
-//		user = temp[0]
-//	}
-//	return
-//}
+var ErrAmbiguousUser = errors.New("ambiguous user")
+
+// UserSearch selects users by username, email and attributes; all given criteria have to match
+type UserSearch struct {
+	Username   string
+	Email      string
+	Attributes map[string]string
+	Exact      bool //username and email have to be equal instead of containing the value
+}
+
+// SearchUsers returns the users matching the search, ordered by username. Members are restricted to the given user ids, unless members is nil.
+// An exact search matching several users returns them with ErrAmbiguousUser.
+func SearchUsers(ctx context.Context, search UserSearch, members map[string]bool, conf configuration.Config) (users []User, err error) {
+	query := attributeQuery(search.Attributes)
+	if search.Username != "" {
+		query.Set("username", search.Username)
+	}
+	if search.Email != "" {
+		query.Set("email", search.Email)
+	}
+	if search.Exact {
+		query.Set("exact", "true")
+	}
+	users, err = getUsers(ctx, conf.KeycloakUrl+"/auth/admin/realms/"+conf.KeycloakRealm+"/users?"+query.Encode(), "", conf)
+	if err != nil {
+		return nil, err
+	}
+	if members != nil {
+		users = slices.DeleteFunc(users, func(user User) bool { return !members[user.Id] })
+	}
+	if search.Exact && search.Username != "" {
+		users = filterExact(users, search.Username)
+	}
+	if users == nil {
+		users = []User{}
+	}
+	if search.Exact && len(users) > 1 {
+		return users, fmt.Errorf("%w: %v users match", ErrAmbiguousUser, len(users))
+	}
+	return users, nil
+}
 
 func filterExact(users []User, name string) (result []User) {
 	for _, user := range users {
-		if user.Name == name {
+		if strings.EqualFold(user.Name, name) {
 			result = append(result, user)
 		}
 	}
 	return
 }
 
-func GetUserById(id string, conf configuration.Config) (user User, err error) {
-	token, err := EnsureAccess(conf)
+func GetUserById(ctx context.Context, id string, conf configuration.Config) (user User, err error) {
+	token, err := EnsureAccess(ctx, conf)
 	if err != nil {
 		return user, err
 	}
@@ -80,8 +105,87 @@ func GetUserById(id string, conf configuration.Config) (user User, err error) {
 	return
 }
 
-func DeleteKeycloakUser(id string, conf configuration.Config) (err error) {
-	token, err := EnsureAccess(conf)
+// DefaultKeycloakLookupMaxIds is used if KeycloakLookupMaxIds is not set
+const DefaultKeycloakLookupMaxIds = 100
+
+var ErrLookupTooLarge = errors.New("too many ids")
+
+// UsernameLookup is the result of GetUsernames
+type UsernameLookup struct {
+	Names  map[string]*string `json:"names"`            //usernames by id; nil for unknown ids
+	Failed map[string]string  `json:"failed,omitempty"` //errors by id, for ids whose lookup failed
+}
+
+func GetKeycloakLookupMaxIds(conf configuration.Config) int {
+	if conf.KeycloakLookupMaxIds <= 0 {
+		return DefaultKeycloakLookupMaxIds
+	}
+	return conf.KeycloakLookupMaxIds
+}
+
+// GetUsernames returns the usernames by user id; unknown ids are mapped to nil and ids, whose lookup failed, are reported in Failed.
+// The lookup only fails, if keycloak is unreachable, or with ErrLookupTooLarge for more than KeycloakLookupMaxIds ids.
+// Users are requested with at most conf.KeycloakLookupWorkers concurrent requests.
+func GetUsernames(ctx context.Context, ids []string, conf configuration.Config) (result UsernameLookup, err error) {
+	if maxIds := GetKeycloakLookupMaxIds(conf); len(ids) > maxIds {
+		return result, fmt.Errorf("%w: %v ids exceed the limit of %v", ErrLookupTooLarge, len(ids), maxIds)
+	}
+	token, err := EnsureAccess(ctx, conf)
+	if err != nil {
+		return result, err
+	}
+	result = UsernameLookup{Names: map[string]*string{}, Failed: map[string]string{}}
+	mux := sync.Mutex{}
+	unique := slices.Compact(slices.Sorted(slices.Values(ids)))
+	_, err = forEachParallel(unique, conf.KeycloakLookupWorkers, func(id string) error {
+		name, err := getUsername(token, id, conf)
+		if errors.Is(err, errKeycloakUnreachable) {
+			return err
+		}
+		mux.Lock()
+		defer mux.Unlock()
+		if err != nil {
+			log.Println("WARNING: unable to get username", id, err)
+			result.Failed[id] = err.Error()
+			return nil
+		}
+		result.Names[id] = name
+		return nil
+	})
+	if err != nil {
+		return UsernameLookup{}, err
+	}
+	return result, nil
+}
+
+var errKeycloakUnreachable = errors.New("keycloak unreachable")
+
+// getUsername returns nil for unknown or empty ids; failed requests without response are reported as errKeycloakUnreachable
+func getUsername(token JwtImpersonate, id string, conf configuration.Config) (*string, error) {
+	if id == "" {
+		return nil, nil
+	}
+	resp, err := token.Get(conf.KeycloakUrl + "/auth/admin/realms/" + conf.KeycloakRealm + "/users/" + url.PathEscape(id))
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil && resp == nil {
+		return nil, fmt.Errorf("%w: %w", errKeycloakUnreachable, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	user := User{}
+	err = json.NewDecoder(resp.Body).Decode(&user)
+	if err != nil {
+		return nil, err
+	}
+	return &user.Name, nil
+}
+
+func DeleteKeycloakUser(ctx context.Context, id string, conf configuration.Config) (err error) {
+	token, err := EnsureAccess(ctx, conf)
 	if err != nil {
 		log.Println("ERROR: unable to ensure access", err)
 		return err
@@ -94,15 +198,104 @@ func DeleteKeycloakUser(id string, conf configuration.Config) (err error) {
 	return err
 }
 
-func GetUsers(excludeID string, conf configuration.Config) ([]User, error) {
-	return getUsers(conf.KeycloakUrl+"/auth/admin/realms/"+conf.KeycloakRealm+"/users", excludeID, conf)
+// SetKeycloakUserEnabled enables or disables the login of the user
+func SetKeycloakUserEnabled(ctx context.Context, id string, enabled bool, conf configuration.Config) (err error) {
+	token, err := EnsureAccess(ctx, conf)
+	if err != nil {
+		log.Println("ERROR: unable to ensure access", err)
+		return err
+	}
+	return token.PutJSON(conf.KeycloakUrl+"/auth/admin/realms/"+conf.KeycloakRealm+"/users/"+url.QueryEscape(id), map[string]interface{}{"enabled": enabled}, nil)
+}
+
+// LogoutKeycloakUser revokes all sessions of the user
+func LogoutKeycloakUser(ctx context.Context, id string, conf configuration.Config) (err error) {
+	token, err := EnsureAccess(ctx, conf)
+	if err != nil {
+		log.Println("ERROR: unable to ensure access", err)
+		return err
+	}
+	resp, err := token.Post(conf.KeycloakUrl+"/auth/admin/realms/"+conf.KeycloakRealm+"/users/"+url.QueryEscape(id)+"/logout", "application/json", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// KeycloakCleaner removes the user account; it is always executed after all other cleaners
+type KeycloakCleaner struct{}
+
+func (this KeycloakCleaner) Name() string {
+	return "keycloak"
+}
+
+func (this KeycloakCleaner) Enabled(conf configuration.Config) bool {
+	return true
+}
+
+func (this KeycloakCleaner) List(ctx context.Context, token Token, conf configuration.Config) ([]ServiceResources, error) {
+	return []ServiceResources{{Resource: "user", Ids: []string{token.GetUserId()}}}, nil
+}
+
+func (this KeycloakCleaner) Delete(ctx context.Context, token Token, conf configuration.Config) (deleted []string, err error) {
+	err = DeleteKeycloakUser(ctx, token.GetUserId(), conf)
+	if err != nil {
+		return deleted, err
+	}
+	return []string{token.GetUserId()}, nil
+}
+
+func (this KeycloakCleaner) Export(ctx context.Context, token Token, conf configuration.Config) (files map[string]interface{}, err error) {
+	access, err := EnsureAccess(ctx, conf)
+	if err != nil {
+		return files, err
+	}
+	var profile interface{}
+	err = access.GetJSON(conf.KeycloakUrl+"/auth/admin/realms/"+conf.KeycloakRealm+"/users/"+url.QueryEscape(token.GetUserId()), &profile)
+	if err != nil {
+		return files, err
+	}
+	groups, err := GetUsersGroups(ctx, token.GetUserId(), conf)
+	if err != nil {
+		return files, err
+	}
+	return map[string]interface{}{"profile": profile, "groups": groups}, nil
+}
+
+func (this KeycloakCleaner) Verify(ctx context.Context, token Token, conf configuration.Config) (remaining []string, err error) {
+	exists, err := KeycloakUserExists(ctx, token.GetUserId(), conf)
+	if err != nil || !exists {
+		return remaining, err
+	}
+	return []string{token.GetUserId()}, nil
+}
+
+func KeycloakUserExists(ctx context.Context, id string, conf configuration.Config) (exists bool, err error) {
+	token, err := EnsureAccess(ctx, conf)
+	if err != nil {
+		return false, err
+	}
+	resp, err := token.Get(conf.KeycloakUrl + "/auth/admin/realms/" + conf.KeycloakRealm + "/users/" + url.QueryEscape(id))
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	resp.Body.Close()
+	return true, nil
 }
 
-func GetUsersGroups(id string, conf configuration.Config) ([]Group, error) {
+func GetUsers(ctx context.Context, excludeID string, conf configuration.Config) ([]User, error) {
+	return getUsers(ctx, conf.KeycloakUrl+"/auth/admin/realms/"+conf.KeycloakRealm+"/users", excludeID, conf)
+}
+
+func GetUsersGroups(ctx context.Context, id string, conf configuration.Config) ([]Group, error) {
 	var groups []Group
 	pageNum := 0
 	for {
-		token, err := EnsureAccess(conf)
+		token, err := EnsureAccess(ctx, conf)
 		if err != nil {
 			return nil, err
 		}
@@ -119,11 +312,11 @@ func GetUsersGroups(id string, conf configuration.Config) ([]Group, error) {
 	return groups, nil
 }
 
-func GetGroupMembersCombined(groups []Group, excludeID string, conf configuration.Config) ([]User, error) {
+func GetGroupMembersCombined(ctx context.Context, groups []Group, excludeID string, conf configuration.Config) ([]User, error) {
 	var users []User
 	userSet := make(map[string]struct{})
 	for _, group := range groups {
-		members, err := getUsers(conf.KeycloakUrl+"/auth/admin/realms/"+conf.KeycloakRealm+"/groups/"+url.QueryEscape(group.ID)+"/members", excludeID, conf)
+		members, err := getUsers(ctx, conf.KeycloakUrl+"/auth/admin/realms/"+conf.KeycloakRealm+"/groups/"+url.QueryEscape(group.ID)+"/members", excludeID, conf)
 		if err != nil {
 			return nil, err
 		}
@@ -138,16 +331,25 @@ func GetGroupMembersCombined(groups []Group, excludeID string, conf configuratio
 	return users, nil
 }
 
-func getUsers(url string, excludeID string, conf configuration.Config) ([]User, error) {
+// SearchUsersByAttributes returns the users matching all attributes, using the q search parameter of keycloak
+func SearchUsersByAttributes(ctx context.Context, attributes map[string]string, excludeID string, conf configuration.Config) ([]User, error) {
+	return getUsers(ctx, conf.KeycloakUrl+"/auth/admin/realms/"+conf.KeycloakRealm+"/users?"+attributeQuery(attributes).Encode(), excludeID, conf)
+}
+
+func getUsers(ctx context.Context, url string, excludeID string, conf configuration.Config) ([]User, error) {
 	var users []User
 	pageNum := 0
+	separator := "?"
+	if strings.Contains(url, "?") {
+		separator = "&"
+	}
 	for {
-		token, err := EnsureAccess(conf)
+		token, err := EnsureAccess(ctx, conf)
 		if err != nil {
 			return nil, err
 		}
 		var page []User
-		if err = token.GetJSON(url+fmt.Sprintf("?max=%d&first=%d", conf.KeycloakPageMax, conf.KeycloakPageMax*pageNum), &page); err != nil {
+		if err = token.GetJSON(url+separator+fmt.Sprintf("max=%d&first=%d", conf.KeycloakPageMax, conf.KeycloakPageMax*pageNum), &page); err != nil {
 			return nil, err
 		}
 		if len(page) == 0 {
@@ -0,0 +1,66 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctrl
+
+import (
+	"github.com/SENERGY-Platform/user-management/pkg/store"
+	"log"
+	"time"
+)
+
+// LedgerEntry records a completely processed user command, to skip replays of the compacted user topic
+type LedgerEntry struct {
+	Key       string    `json:"key"`
+	Command   string    `json:"command"`
+	UserId    string    `json:"user_id"`
+	TargetId  string    `json:"target_id,omitempty"`
+	Completed time.Time `json:"completed"`
+}
+
+type LedgerStore = store.Store[LedgerEntry]
+
+// GetUserCommandKey returns the message key of the command in the user topic
+func GetUserCommandKey(command UserCommandMsg) string {
+	return command.Command + "_" + command.Id
+}
+
+// RunWithLedger skips commands, whose key is already completed according to the ledger, unless the command is forced.
+// After a successful run, the key is recorded as completed.
+func RunWithLedger(ledger LedgerStore, command UserCommandMsg, run func() error) (skipped bool, err error) {
+	key := GetUserCommandKey(command)
+	if !command.Force {
+		entry, exists, err := ledger.Get(key)
+		if err != nil {
+			return false, err
+		}
+		if exists {
+			log.Println("skip already completed user command", key, "completed at", entry.Completed)
+			return true, nil
+		}
+	}
+	err = run()
+	if err != nil {
+		return false, err
+	}
+	return false, ledger.Set(key, LedgerEntry{
+		Key:       key,
+		Command:   command.Command,
+		UserId:    command.Id,
+		TargetId:  command.TargetId,
+		Completed: time.Now(),
+	})
+}
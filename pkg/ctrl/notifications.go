@@ -17,39 +17,84 @@
 package ctrl
 
 import (
+	"context"
 	"errors"
 	"github.com/SENERGY-Platform/user-management/pkg/configuration"
 	"io"
 )
 
-func DeleteNotificationUser(token Token, conf configuration.Config) error {
-	if conf.NotifierUrl == "" || conf.NotifierUrl == "-" {
-		return nil
+type NotifierCleaner struct{}
+
+func (this NotifierCleaner) Name() string {
+	return "notifier"
+}
+
+func (this NotifierCleaner) Enabled(conf configuration.Config) bool {
+	return isSet(conf.NotifierUrl)
+}
+
+func (this NotifierCleaner) List(ctx context.Context, token Token, conf configuration.Config) ([]ServiceResources, error) {
+	notificationIds, err := getNotificationIds(token, conf)
+	if err != nil {
+		return nil, err
 	}
+	brokerIds, err := getBrokerIds(token, conf)
+	if err != nil {
+		return nil, err
+	}
+	return []ServiceResources{
+		{Resource: "notifications", Ids: notificationIds},
+		{Resource: "brokers", Ids: brokerIds},
+	}, nil
+}
+
+func (this NotifierCleaner) Delete(ctx context.Context, token Token, conf configuration.Config) (deleted []string, err error) {
 	ids, err := getNotificationIds(token, conf)
 	if err != nil {
-		return err
+		return deleted, err
 	}
 	err = deleteNotifications(token, conf, ids)
 	if err != nil {
-		return err
+		return deleted, err
 	}
+	deleted = append(deleted, ids...)
 
 	ids, err = getBrokerIds(token, conf)
 	if err != nil {
-		return err
+		return deleted, err
 	}
 	err = deleteBrokers(token, conf, ids)
 	if err != nil {
-		return err
+		return deleted, err
 	}
+	deleted = append(deleted, ids...)
 
 	err = deletePlatformBrokerConfig(token, conf)
 	if err != nil {
-		return err
+		return deleted, err
 	}
 
-	return nil
+	return deleted, nil
+}
+
+func (this NotifierCleaner) Export(ctx context.Context, token Token, conf configuration.Config) (files map[string]interface{}, err error) {
+	//limit=0 -> mongodb: all elements
+	notifications, err := getRawJson(token, conf.NotifierUrl+"/notifications?limit=0&offset=0")
+	if err != nil {
+		return files, err
+	}
+	brokers, err := getRawJson(token, conf.NotifierUrl+"/brokers?limit=0&offset=0")
+	if err != nil {
+		return files, err
+	}
+	return map[string]interface{}{
+		"notifications": getRawList(notifications, "notifications"),
+		"brokers":       getRawList(brokers, "brokers"),
+	}, nil
+}
+
+func (this NotifierCleaner) Verify(ctx context.Context, token Token, conf configuration.Config) (remaining []string, err error) {
+	return verifyByList(ctx, this, token, conf)
 }
 
 func deleteNotifications(token Token, conf configuration.Config, ids []string) error {
@@ -0,0 +1,140 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctrl
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"log"
+	"slices"
+	"time"
+)
+
+// OrphanScannerId is recorded as requester of cleanups enqueued by scheduled orphan scans
+const OrphanScannerId = "orphan-scanner"
+
+// OwnerLister is implemented by cleaners of services, which are able to enumerate the owners of all their resources
+type OwnerLister interface {
+	// ListOwners returns the ids of all users owning resources in the service; the service is requested with an admin token.
+	// Ok is false if the service is not configured for owner listings.
+	ListOwners(ctx context.Context, conf configuration.Config) (owners []string, ok bool, err error)
+}
+
+// OrphanScanReport lists owners of resources without keycloak user
+type OrphanScanReport struct {
+	Started  time.Time           `json:"started"`
+	Finished time.Time           `json:"finished"`
+	Enqueue  bool                `json:"enqueue"`
+	Services []OrphanScanService `json:"services"`
+	Orphans  []string            `json:"orphans"`  //orphaned owners of all services
+	Enqueued []string            `json:"enqueued"` //orphaned owners with enqueued cleanup
+	Errors   []string            `json:"errors,omitempty"`
+}
+
+type OrphanScanService struct {
+	Service string   `json:"service"`
+	Owners  int      `json:"owners"`
+	Orphans []string `json:"orphans"`
+	Error   string   `json:"error,omitempty"`
+}
+
+// GetOrphanScanInterval returns 0 if the scheduled scan is disabled
+func GetOrphanScanInterval(conf configuration.Config) (time.Duration, error) {
+	if conf.OrphanScanInterval == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(conf.OrphanScanInterval)
+}
+
+// ScanOrphans collects the owners of every service implementing OwnerLister and reports owners, which do not exist in keycloak.
+// If enqueue is not nil, it is called once for every orphaned owner. Failing services and owners, whose existence could not be checked,
+// are reported in the errors without stopping the scan.
+func ScanOrphans(ctx context.Context, conf configuration.Config, enqueue func(userId string) error) (report OrphanScanReport, err error) {
+	report = OrphanScanReport{
+		Started:  time.Now(),
+		Enqueue:  enqueue != nil,
+		Services: []OrphanScanService{},
+		Orphans:  []string{},
+		Enqueued: []string{},
+	}
+	known := map[string]bool{}
+	failed := map[string]bool{} //owners with failed existence check
+	for _, cleaner := range GetCleaners(conf) {
+		lister, ok := cleaner.(OwnerLister)
+		if !ok {
+			continue
+		}
+		serviceCtx, cancel := withServiceTimeout(ctx, conf, cleaner.Name())
+		owners, ok, err := lister.ListOwners(serviceCtx, conf)
+		cancel()
+		if !ok && err == nil {
+			continue
+		}
+		service := OrphanScanService{Service: cleaner.Name(), Owners: len(owners), Orphans: []string{}}
+		if err != nil {
+			log.Println("ERROR: unable to list owners of", cleaner.Name(), err)
+			service.Error = err.Error()
+			report.Services = append(report.Services, service)
+			continue
+		}
+		for _, owner := range owners {
+			if failed[owner] {
+				continue
+			}
+			exists, checked := known[owner]
+			if !checked {
+				exists, err = KeycloakUserExists(ctx, owner, conf)
+				if err != nil {
+					if ctx.Err() != nil {
+						return report, ctx.Err()
+					}
+					//an unchecked owner is never reported as orphan, to never enqueue the cleanup of an existing user
+					log.Println("ERROR: unable to check existence of owner", owner, cleaner.Name(), err)
+					report.Errors = append(report.Errors, cleaner.Name()+": "+owner+": "+err.Error())
+					failed[owner] = true
+					continue
+				}
+				known[owner] = exists
+			}
+			if !exists {
+				service.Orphans = append(service.Orphans, owner)
+				if !slices.Contains(report.Orphans, owner) {
+					report.Orphans = append(report.Orphans, owner)
+				}
+			}
+		}
+		report.Services = append(report.Services, service)
+	}
+	if enqueue != nil {
+		for _, owner := range report.Orphans {
+			err = enqueue(owner)
+			if err != nil {
+				log.Println("ERROR: unable to enqueue cleanup of orphaned owner", owner, err)
+				report.Errors = append(report.Errors, owner+": "+err.Error())
+				continue
+			}
+			report.Enqueued = append(report.Enqueued, owner)
+		}
+	}
+	report.Finished = time.Now()
+	return report, nil
+}
+
+// createOwnerListingToken returns the admin token used to enumerate owners in services
+func createOwnerListingToken() (Token, error) {
+	return CreateTokenWithRoles("users-service", OrphanScannerId, []string{"admin"})
+}
@@ -0,0 +1,218 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctrl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"log"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// resource types with a configurable ResourcePolicy
+const (
+	PolicyResourceExportDatabases       = "export-databases"        //private export database metadata
+	PolicyResourcePublicExportDatabases = "public-export-databases" //public export database metadata
+	PolicyResourcePublicOperators       = "public-operators"        //public analytics operators
+	PolicyResourceFlows                 = "flows"                   //analytics flows; the flow repository does not expose their visibility
+)
+
+var PolicyResources = []string{PolicyResourceExportDatabases, PolicyResourcePublicExportDatabases, PolicyResourcePublicOperators, PolicyResourceFlows}
+
+const (
+	PolicyDelete     = "delete"
+	PolicyKeep       = "keep"
+	PolicyTransferTo = "transfer-to" //written as transfer-to:<user id>
+	PolicyAnonymize  = "anonymize"   //transfer to ResourcePolicyAnonymousUserId
+)
+
+const defaultAnonymousUserId = "anonymous"
+
+// ResourcePolicy decides what happens to a shared or public resource of a deleted user
+type ResourcePolicy struct {
+	Mode   string
+	Target string //receiver of transfer-to and anonymize
+}
+
+func (this ResourcePolicy) String() string {
+	if this.Mode == PolicyTransferTo {
+		return PolicyTransferTo + ":" + this.Target
+	}
+	return this.Mode
+}
+
+// PolicyDecision reports the handling of a resource by its policy
+type PolicyDecision struct {
+	Resource string `json:"resource"`
+	Id       string `json:"id"`
+	Policy   string `json:"policy"`
+	Target   string `json:"target,omitempty"` //new owner of transferred or anonymized resources
+}
+
+// PolicyCleaner is implemented by cleaners of services with shared or public resources, which are handled by ResourcePolicies
+type PolicyCleaner interface {
+	// DeleteWithPolicies removes the resources of the user like Delete, applies the policies to shared or public resources and reports every policy decision
+	// Resources recorded in copies are not copied again by transfer-to and anonymize policies.
+	DeleteWithPolicies(ctx context.Context, token Token, copies *CopyLog, conf configuration.Config) (deleted []string, transferred []string, decisions []PolicyDecision, err error)
+}
+
+func ParseResourcePolicy(value string) (policy ResourcePolicy, err error) {
+	mode, target, hasTarget := strings.Cut(value, ":")
+	switch mode {
+	case PolicyDelete, PolicyKeep, PolicyAnonymize:
+		if hasTarget {
+			return policy, fmt.Errorf("unexpected target in resource policy %v", value)
+		}
+	case PolicyTransferTo:
+		if target == "" {
+			return policy, errors.New("missing target user in resource policy " + value)
+		}
+	default:
+		return policy, fmt.Errorf("unknown resource policy %v", value)
+	}
+	return ResourcePolicy{Mode: mode, Target: target}, nil
+}
+
+// ValidateResourcePolicies checks resource types and modes of the policies
+func ValidateResourcePolicies(policies map[string]string) error {
+	for resource, value := range policies {
+		if !slices.Contains(PolicyResources, resource) {
+			return fmt.Errorf("unknown policy resource type %v (known: %v)", resource, strings.Join(PolicyResources, ", "))
+		}
+		_, err := ParseResourcePolicy(value)
+		if err != nil {
+			return fmt.Errorf("%v: %w", resource, err)
+		}
+	}
+	return nil
+}
+
+// MergeResourcePolicies returns the configured policies overwritten by the overrides of a request
+func MergeResourcePolicies(conf configuration.Config, overrides map[string]string) configuration.Config {
+	if len(overrides) == 0 {
+		return conf
+	}
+	policies := maps.Clone(conf.ResourcePolicies)
+	if policies == nil {
+		policies = map[string]string{}
+	}
+	maps.Copy(policies, overrides)
+	conf.ResourcePolicies = policies
+	return conf
+}
+
+// GetResourcePolicy returns the configured policy of the resource type; unconfigured types keep the behavior of previous versions.
+// Policies are validated on startup and when a command is parsed; an invalid policy keeps the resource, because a deletion is irreversible.
+func GetResourcePolicy(conf configuration.Config, resource string) ResourcePolicy {
+	policy := ResourcePolicy{Mode: PolicyDelete}
+	switch resource {
+	case PolicyResourceExportDatabases:
+		if !conf.RemoveExportDatabaseMetadataOnUserDelete {
+			policy.Mode = PolicyKeep
+		}
+	case PolicyResourcePublicExportDatabases:
+		policy.Mode = PolicyKeep
+	}
+	if value, ok := conf.ResourcePolicies[resource]; ok {
+		parsed, err := ParseResourcePolicy(value)
+		if err != nil {
+			log.Println("WARNING: invalid resource policy of", resource, err)
+			parsed = ResourcePolicy{Mode: PolicyKeep}
+		}
+		policy = parsed
+	}
+	if policy.Mode == PolicyAnonymize {
+		policy.Target = conf.ResourcePolicyAnonymousUserId
+		if policy.Target == "" {
+			policy.Target = defaultAnonymousUserId
+		}
+	}
+	return policy
+}
+
+// applyResourcePolicy removes, keeps or moves the resource according to its policy; copyTo creates the resource for the target user
+func applyResourcePolicy(conf configuration.Config, copies *CopyLog, resource string, id string, remove func() error, copyTo func(target Token) error) (decision PolicyDecision, err error) {
+	policy := GetResourcePolicy(conf, resource)
+	decision = PolicyDecision{Resource: resource, Id: id, Policy: policy.String()}
+	switch policy.Mode {
+	case PolicyKeep:
+		return decision, nil
+	case PolicyTransferTo, PolicyAnonymize:
+		target, err := CreateToken("users-service", policy.Target)
+		if err != nil {
+			return decision, err
+		}
+		decision.Target = policy.Target
+		return decision, moveResource(copies, id, func() error {
+			return copyTo(target)
+		}, remove)
+	default:
+		return decision, remove()
+	}
+}
+
+// applyResourcePolicies calls applyResourcePolicy for every id, using the configured workers of the cleaner.
+// Deleted and transferred contain the ids of resources handled by delete or transfer-to/anonymize policies.
+func applyResourcePolicies(conf configuration.Config, copies *CopyLog, cleaner string, resource string, ids []string, remove func(id string) error, copyTo func(target Token, id string) error) (deleted []string, transferred []string, decisions []PolicyDecision, err error) {
+	mux := sync.Mutex{}
+	results := map[string]PolicyDecision{}
+	_, err = forEachParallel(ids, getServiceWorkers(conf, cleaner), func(id string) error {
+		decision, err := applyResourcePolicy(conf, copies, resource, id, func() error {
+			return remove(id)
+		}, func(target Token) error {
+			return copyTo(target, id)
+		})
+		if err != nil {
+			return err
+		}
+		mux.Lock()
+		defer mux.Unlock()
+		results[id] = decision
+		return nil
+	})
+	for _, id := range ids {
+		decision, ok := results[id]
+		if !ok {
+			continue
+		}
+		decisions = append(decisions, decision)
+		switch {
+		case decision.Policy == PolicyDelete:
+			deleted = append(deleted, id)
+		case decision.Target != "":
+			transferred = append(transferred, id)
+		}
+	}
+	return deleted, transferred, decisions, err
+}
+
+// getPolicyKeepReason describes a resource, which is not deleted because of its policy, in resource listings
+func getPolicyKeepReason(conf configuration.Config, resource string) (reason string, kept bool) {
+	policy := GetResourcePolicy(conf, resource)
+	switch policy.Mode {
+	case PolicyKeep:
+		return "policy " + resource + ": keep", true
+	case PolicyTransferTo, PolicyAnonymize:
+		return "policy " + resource + ": " + policy.Mode + " to " + policy.Target, true
+	}
+	return "", false
+}
@@ -17,27 +17,59 @@
 package ctrl
 
 import (
+	"context"
 	"errors"
 	"github.com/SENERGY-Platform/user-management/pkg/configuration"
 	"io"
 	"net/url"
 )
 
-func DeleteProcessSchedulerUser(token Token, conf configuration.Config) error {
-	if conf.ProcessSchedulerUrl == "" || conf.ProcessSchedulerUrl == "-" {
-		return nil
-	}
+type ProcessSchedulerCleaner struct{}
+
+func (this ProcessSchedulerCleaner) Name() string {
+	return "process-scheduler"
+}
+
+func (this ProcessSchedulerCleaner) Enabled(conf configuration.Config) bool {
+	return isSet(conf.ProcessSchedulerUrl)
+}
+
+func (this ProcessSchedulerCleaner) List(ctx context.Context, token Token, conf configuration.Config) ([]ServiceResources, error) {
+	ids, err := getProcessScheduleIds(token, conf)
+	return []ServiceResources{{Resource: "schedules", Ids: ids}}, err
+}
+
+func (this ProcessSchedulerCleaner) Delete(ctx context.Context, token Token, conf configuration.Config) (deleted []string, err error) {
 	ids, err := getProcessScheduleIds(token, conf)
 	if err != nil {
-		return err
+		return deleted, err
 	}
-	for _, id := range ids {
-		err = deleteProcessSchedule(token, conf, id)
-		if err != nil {
-			return err
-		}
+	return forEachParallel(ids, getServiceWorkers(conf, this.Name()), func(id string) error {
+		return deleteProcessSchedule(token, conf, id)
+	})
+}
+
+func (this ProcessSchedulerCleaner) Transfer(ctx context.Context, token Token, target Token, copies *CopyLog, conf configuration.Config) (transferred []string, err error) {
+	ids, err := getProcessScheduleIds(token, conf)
+	if err != nil {
+		return transferred, err
 	}
-	return nil
+	return forEachParallel(ids, getServiceWorkers(conf, this.Name()), func(id string) error {
+		return moveResource(copies, id, func() error {
+			return copyResource(token, target, conf.ProcessSchedulerUrl+"/schedules", id, "id", "user_id")
+		}, func() error {
+			return deleteProcessSchedule(token, conf, id)
+		})
+	})
+}
+
+func (this ProcessSchedulerCleaner) Export(ctx context.Context, token Token, conf configuration.Config) (files map[string]interface{}, err error) {
+	schedules, err := getRawJson(token, conf.ProcessSchedulerUrl+"/schedules")
+	return map[string]interface{}{"schedules": schedules}, err
+}
+
+func (this ProcessSchedulerCleaner) Verify(ctx context.Context, token Token, conf configuration.Config) (remaining []string, err error) {
+	return verifyByList(ctx, this, token, conf)
 }
 
 func deleteProcessSchedule(token Token, conf configuration.Config, id string) error {
@@ -0,0 +1,70 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctrl
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"log"
+)
+
+type UserResources struct {
+	UserId   string             `json:"user_id"`
+	Services []ServiceResources `json:"services"`
+}
+
+type ServiceResources struct {
+	Service  string         `json:"service"`
+	Resource string         `json:"resource"`
+	Count    int            `json:"count"`
+	Ids      []string       `json:"ids"`
+	Kept     []KeptResource `json:"kept,omitempty"`
+	Note     string         `json:"note,omitempty"`
+	Error    string         `json:"error,omitempty"`
+}
+
+type KeptResource struct {
+	Id     string `json:"id"`
+	Reason string `json:"reason"`
+}
+
+// GetUserResources lists what a deletion of the user would remove, without deleting anything
+func GetUserResources(ctx context.Context, userId string, conf configuration.Config) (result UserResources, err error) {
+	token, err := CreateToken("users-service", userId)
+	if err != nil {
+		log.Println("ERROR: unable to create jwt for userId", userId, err)
+		return result, err
+	}
+	result = UserResources{UserId: userId, Services: []ServiceResources{}}
+	for _, cleaner := range GetCleaners(conf) {
+		serviceCtx, cancel := withServiceTimeout(ctx, conf, cleaner.Name())
+		resources, err := cleaner.List(serviceCtx, token.WithContext(serviceCtx), conf)
+		cancel()
+		if err != nil {
+			resources = append(resources, ServiceResources{Error: err.Error()})
+		}
+		for _, resource := range resources {
+			resource.Service = cleaner.Name()
+			if resource.Ids == nil {
+				resource.Ids = []string{}
+			}
+			resource.Count = len(resource.Ids)
+			result.Services = append(result.Services, resource)
+		}
+	}
+	return result, nil
+}
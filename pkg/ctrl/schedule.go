@@ -0,0 +1,199 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctrl
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"log"
+	"time"
+)
+
+var ErrNotScheduled = errors.New("no scheduled deletion found")
+var ErrInvalidCancelToken = errors.New("invalid cancel token")
+
+func GetDeletionGracePeriod(conf configuration.Config) (time.Duration, error) {
+	if conf.DeletionGracePeriod == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(conf.DeletionGracePeriod)
+}
+
+func GetDeletionScheduleInterval(conf configuration.Config) (time.Duration, error) {
+	if conf.DeletionScheduleInterval == "" {
+		return time.Minute, nil
+	}
+	return time.ParseDuration(conf.DeletionScheduleInterval)
+}
+
+// ScheduleDeletion records a deletion (or transfer, if targetUserId is set), which is executed after the grace period.
+// Until then the keycloak user is disabled and logged out; the returned cancelToken allows the user to cancel the deletion
+// without login (see CancelScheduledDeletionWithToken). Only its hash is stored.
+func ScheduleDeletion(ctx context.Context, userId string, targetUserId string, gracePeriod time.Duration, conf configuration.Config, jobs JobStore) (job DeletionJob, cancelToken string, err error) {
+	secret := make([]byte, 32)
+	_, err = rand.Read(secret)
+	if err != nil {
+		return job, "", err
+	}
+	cancelToken = hex.EncodeToString(secret)
+	err = SetKeycloakUserEnabled(ctx, userId, false, conf)
+	if err != nil {
+		log.Println("ERROR: unable to disable user", userId, err)
+		return job, "", err
+	}
+	err = LogoutKeycloakUser(ctx, userId, conf)
+	if err != nil {
+		log.Println("ERROR: unable to logout user", userId, err)
+		return job, "", err
+	}
+	job = NewTransferJob(userId, targetUserId, getCleanerNames(GetCleaners(conf)))
+	job.State = DeletionScheduled
+	due := job.Created.Add(gracePeriod)
+	job.ScheduledFor = &due
+	job.CancelTokenHash = hashCancelToken(cancelToken)
+	err = saveDeletionJob(jobs, &job)
+	if err != nil {
+		return job, "", err
+	}
+	return job, cancelToken, nil
+}
+
+func hashCancelToken(cancelToken string) string {
+	hash := sha256.Sum256([]byte(cancelToken))
+	return hex.EncodeToString(hash[:])
+}
+
+// CancelScheduledDeletionWithToken cancels the scheduled deletion like CancelScheduledDeletion, if the cancelToken
+// matches the one returned by ScheduleDeletion. It is meant for the disabled user, who is unable to get a jwt.
+// Unknown users and deletions, which are not scheduled, are reported as ErrInvalidCancelToken as well.
+func CancelScheduledDeletionWithToken(ctx context.Context, userId string, cancelToken string, conf configuration.Config, jobs JobStore) error {
+	job, exists, err := jobs.Get(userId)
+	if err != nil {
+		return err
+	}
+	if !exists || job.State != DeletionScheduled || job.CancelTokenHash == "" || cancelToken == "" ||
+		subtle.ConstantTimeCompare([]byte(job.CancelTokenHash), []byte(hashCancelToken(cancelToken))) != 1 {
+		return ErrInvalidCancelToken
+	}
+	return CancelScheduledDeletion(ctx, userId, conf, jobs)
+}
+
+// HoldForGracePeriod decides if a command of the user topic has to wait for the grace period before it is executed.
+// Commands without deletion job (e.g. published by other services) are scheduled like requests of the api and are published
+// again by StartDueDeletions; their cancel token is discarded, so only admins are able to cancel them.
+// Commands of deletions, which are still scheduled, are held as well. Forced commands, commands of released, running,
+// failed or done deletions and commands of users, which no longer exist in keycloak, are executed immediately.
+func HoldForGracePeriod(ctx context.Context, command UserCommandMsg, msgTime time.Time, gracePeriod time.Duration, conf configuration.Config, jobs JobStore, audits AuditStore) (held bool, err error) {
+	if gracePeriod <= 0 || command.Force {
+		return false, nil
+	}
+	job, exists, err := jobs.Get(command.Id)
+	if err != nil {
+		return false, err
+	}
+	if exists {
+		return job.State == DeletionScheduled, nil
+	}
+	userExists, err := KeycloakUserExists(ctx, command.Id, conf)
+	if err != nil || !userExists {
+		return false, err
+	}
+	entry, err := getCommandAuditEntry(audits, command, msgTime)
+	if err != nil {
+		return false, err
+	}
+	job, _, err = ScheduleDeletion(ctx, command.Id, command.TargetId, gracePeriod, conf, jobs)
+	if err != nil {
+		return false, err
+	}
+	entry.State = DeletionScheduled
+	err = audits.Set(entry.Id, entry)
+	if err != nil {
+		return true, err
+	}
+	job.AuditId = entry.Id
+	job.Policies = command.Policies
+	return true, saveDeletionJob(jobs, &job)
+}
+
+// CancelScheduledDeletion removes a deletion, which is still in its grace period, and enables the keycloak user again
+func CancelScheduledDeletion(ctx context.Context, userId string, conf configuration.Config, jobs JobStore) error {
+	job, exists, err := jobs.Get(userId)
+	if err != nil {
+		return err
+	}
+	if !exists || job.State != DeletionScheduled {
+		return ErrNotScheduled
+	}
+	err = SetKeycloakUserEnabled(ctx, userId, true, conf)
+	if err != nil {
+		log.Println("ERROR: unable to enable user", userId, err)
+		return err
+	}
+	return jobs.Remove(userId)
+}
+
+func ListScheduledDeletions(jobs JobStore) (result []DeletionJob, err error) {
+	list, err := jobs.List()
+	if err != nil {
+		return result, err
+	}
+	result = []DeletionJob{}
+	for _, job := range list {
+		if job.State == DeletionScheduled {
+			result = append(result, job)
+		}
+	}
+	return result, nil
+}
+
+// StartDueDeletions marks every scheduled job with an expired grace period as pending and calls start for it.
+// If start fails, the job stays scheduled and is retried on the next call.
+func StartDueDeletions(jobs JobStore, now time.Time, start func(job DeletionJob) error) error {
+	scheduled, err := ListScheduledDeletions(jobs)
+	if err != nil {
+		return err
+	}
+	errs := []error{}
+	for _, job := range scheduled {
+		if job.ScheduledFor == nil || job.ScheduledFor.After(now) {
+			continue
+		}
+		job.State = DeletionPending
+		err = saveDeletionJob(jobs, &job)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		err = start(job)
+		if err != nil {
+			log.Println("ERROR: unable to start scheduled deletion", job.UserId, err)
+			errs = append(errs, err)
+			job.State = DeletionScheduled
+			err = saveDeletionJob(jobs, &job)
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -17,89 +17,195 @@
 package ctrl
 
 import (
-	devicerepo "github.com/SENERGY-Platform/device-repository/lib/client"
+	"context"
+	"errors"
+	"fmt"
 	"github.com/SENERGY-Platform/user-management/pkg/configuration"
 	"log"
+	"sync"
+	"time"
 )
 
-func DeleteUser(userId string, conf configuration.Config) (err error) {
+// DeleteUser executes the deletion job of the user; a failed job is resumed at the failed steps on the next call.
+// Services are cleaned concurrently (limited by conf.DeletionParallelServices), the keycloak user is removed last
+// and only if all other steps succeeded. With conf.DeletionVerify, a step only succeeds if the service lists no remaining
+// resources afterward. The progress is passed to the optional publisher.
+func DeleteUser(ctx context.Context, userId string, conf configuration.Config, jobs JobStore, publisher UserEventPublisher) (err error) {
+	return executeDeletionJob(ctx, userId, "", conf, jobs, publisher)
+}
+
+// TransferUser hands the resources of the user to targetUserId in every service implementing ResourceTransferer,
+// keeps the resources in all other services (reported as skipped steps) and finally removes the user from keycloak.
+func TransferUser(ctx context.Context, userId string, targetUserId string, conf configuration.Config, jobs JobStore, publisher UserEventPublisher) (err error) {
+	if targetUserId == "" || targetUserId == userId {
+		return errors.New("invalid transfer target")
+	}
+	return executeDeletionJob(ctx, userId, targetUserId, conf, jobs, publisher)
+}
+
+func executeDeletionJob(ctx context.Context, userId string, targetUserId string, conf configuration.Config, jobs JobStore, publisher UserEventPublisher) (err error) {
 	token, err := CreateToken("users-service", userId)
 	if err != nil {
 		log.Println("ERROR: unable to create jwt for userId", userId, err)
 		return err
 	}
-	err, _ = devicerepo.NewClient(conf.DeviceRepositoryUrl, nil).DeleteUser(devicerepo.InternalAdminToken, userId)
-	if err != nil {
-		log.Println("ERROR: devicerepo.DeleteUser()", err)
-		return err
-	}
-	err = DeleteWaitingRoomUser(token, conf)
-	if err != nil {
-		log.Println("ERROR: DeleteWaitingRoomUser()", err)
-		return err
-	}
-	err = DeleteDashboardUser(token, conf)
-	if err != nil {
-		log.Println("ERROR: DeleteDashboardUser()", err)
-		return err
-	}
-	err = DeleteProcessSchedulerUser(token, conf)
-	if err != nil {
-		log.Println("ERROR: DeleteProcessSchedulerUser()", err)
-		return err
-	}
-	err = DeleteImportsUser(token, conf)
-	if err != nil {
-		log.Println("ERROR: DeleteImportsUser()", err)
-		return err
+	var target *Token
+	if targetUserId != "" {
+		targetToken, err := CreateToken("users-service", targetUserId)
+		if err != nil {
+			log.Println("ERROR: unable to create jwt for userId", targetUserId, err)
+			return err
+		}
+		target = &targetToken
 	}
-	err = DeleteBrokerExportsUser(token, conf)
+	cleaners := GetCleaners(conf)
+	job, err := loadDeletionJob(jobs, userId, targetUserId, getCleanerNames(cleaners))
 	if err != nil {
-		log.Println("ERROR: DeleteBrokerExportsUser()", err)
+		log.Println("ERROR: unable to load deletion job", userId, err)
 		return err
 	}
-	err = DeleteDatabaseExportsUser(token, conf)
+	job.State = DeletionRunning
+	err = saveDeletionJob(jobs, &job)
 	if err != nil {
-		log.Println("ERROR: DeleteDatabaseExportsUser()", err)
+		log.Println("ERROR: unable to save deletion job", userId, err)
 		return err
 	}
+	publisher.publish(newUserEvent(EventUserDeletionStarted, job))
 
-	if conf.RemoveExportDatabaseMetadataOnUserDelete {
-		err = DeleteExportDatabasesUser(token, conf)
+	jobMux := sync.Mutex{}
+	runStep := func(i int) error {
+		jobMux.Lock()
+		if job.Steps[i].State == DeletionDone {
+			jobMux.Unlock()
+			return nil
+		}
+		job.Steps[i].State = DeletionRunning
+		job.Steps[i].Attempts++
+		//copies of transferred resources are persisted immediately, so a retry after a failed removal of the original does not copy it again
+		copies := NewCopyLog(job.Steps[i].Copied, func(id string) error {
+			jobMux.Lock()
+			defer jobMux.Unlock()
+			job.Steps[i].Copied = append(job.Steps[i].Copied, id)
+			return saveDeletionJob(jobs, &job)
+		})
+		err := saveDeletionJob(jobs, &job)
+		jobMux.Unlock()
 		if err != nil {
-			log.Println("ERROR: DeleteExportDatabasesUser()", err)
+			log.Println("ERROR: unable to save deletion job", userId, err)
 			return err
 		}
-	}
-
-	err = DeleteAnalyticsOperatorRepoUser(token, conf)
-	if err != nil {
-		log.Println("ERROR: DeleteAnalyticsOperatorRepoUser()", err)
+		stepCtx, cancel := withServiceTimeout(ctx, conf, cleaners[i].Name())
+		deleted, transferred, decisions, skipped, stepErr := runCleaner(stepCtx, cleaners[i], token, target, copies, conf)
+		var orphans []string
+		if stepErr == nil && !skipped {
+			orphans, stepErr = verifyCleaner(stepCtx, cleaners[i], token, conf, func() error {
+				retryDeleted, retryTransferred, retryDecisions, _, err := runCleaner(stepCtx, cleaners[i], token, target, copies, conf)
+				deleted = append(deleted, retryDeleted...)
+				transferred = append(transferred, retryTransferred...)
+				decisions = append(decisions, retryDecisions...)
+				return err
+			})
+		}
+		cancel()
+		now := time.Now()
+		jobMux.Lock()
+		job.Steps[i].Deleted = job.Steps[i].Deleted + len(deleted)
+		job.Steps[i].Transferred = job.Steps[i].Transferred + len(transferred)
+		job.Steps[i].Decisions = append(job.Steps[i].Decisions, decisions...)
+		job.Steps[i].Orphans = orphans
+		switch {
+		case stepErr != nil:
+			log.Println("ERROR: unable to delete user in", cleaners[i].Name(), stepErr)
+			job.Steps[i].State = DeletionFailed
+			job.Steps[i].LastError = stepErr.Error()
+		case skipped:
+			job.Steps[i].State = DeletionSkipped
+			job.Steps[i].Note = "transfer not supported; resources are kept"
+			job.Steps[i].LastError = ""
+			job.Steps[i].Finished = &now
+		default:
+			job.Steps[i].State = DeletionDone
+			job.Steps[i].Note = ""
+			job.Steps[i].LastError = ""
+			job.Steps[i].Finished = &now
+		}
+		err = saveDeletionJob(jobs, &job)
+		event := newUserEvent(EventUserServiceCleaned, job)
+		jobMux.Unlock()
+		if err != nil {
+			log.Println("ERROR: unable to save deletion job", userId, err)
+		}
+		if stepErr != nil {
+			return fmt.Errorf("%v: %w", cleaners[i].Name(), stepErr)
+		}
+		if !skipped {
+			event.Service = cleaners[i].Name()
+			event.Count = len(deleted)
+			event.Transferred = len(transferred)
+			publisher.publish(event)
+		}
 		return err
 	}
-	err = DeleteAnalyticsFlowRepoUser(token, conf)
-	if err != nil {
-		log.Println("ERROR: DeleteAnalyticsFlowRepoUser()", err)
-		return err
+
+	//the last cleaner is the keycloak cleaner, which must not run before all others succeeded
+	steps := []int{}
+	for i := range cleaners[:len(cleaners)-1] {
+		steps = append(steps, i)
 	}
-	err = DeleteAnalyticsFlowEngineUser(token, conf)
-	if err != nil {
-		log.Println("ERROR: DeleteAnalyticsFlowEngineUser()", err)
-		return err
+	_, err = forEachParallel(steps, conf.DeletionParallelServices, runStep)
+	if err == nil {
+		err = runStep(len(cleaners) - 1)
 	}
-	err = DeleteNotificationUser(token, conf)
 	if err != nil {
-		log.Println("ERROR: DeleteNotificationUser()", err)
+		job.State = DeletionFailed
+		saveErr := saveDeletionJob(jobs, &job)
+		if saveErr != nil {
+			log.Println("ERROR: unable to save deletion job", userId, saveErr)
+		}
+		event := newUserEvent(EventUserDeletionFailed, job)
+		event.Error = err.Error()
+		event.Orphans = getJobOrphans(job)
+		publisher.publish(event)
 		return err
 	}
-	err = DeleteKeycloakUser(userId, conf)
+
+	now := time.Now()
+	job.State = DeletionDone
+	job.Finished = &now
+	err = saveDeletionJob(jobs, &job)
 	if err != nil {
-		log.Println("ERROR: DeleteKeycloakUser()", err)
+		log.Println("ERROR: unable to save deletion job", userId, err)
 		return err
 	}
+	publisher.publish(newUserEvent(EventUserDeleted, job))
 	return nil
 }
 
+// runCleaner deletes the resources of the token user; if a transfer target is given, the resources are transferred instead
+// or skipped, if the cleaner is unable to transfer. The keycloak user is always deleted.
+// Deletions of cleaners implementing PolicyCleaner apply the ResourcePolicies of conf and report their decisions.
+func runCleaner(ctx context.Context, cleaner ServiceCleaner, token Token, target *Token, copies *CopyLog, conf configuration.Config) (deleted []string, transferred []string, decisions []PolicyDecision, skipped bool, err error) {
+	token = token.WithContext(ctx)
+	if target != nil {
+		bound := target.WithContext(ctx)
+		target = &bound
+	}
+	if _, isKeycloak := cleaner.(KeycloakCleaner); target == nil || isKeycloak {
+		if policyCleaner, ok := cleaner.(PolicyCleaner); ok && !isKeycloak {
+			deleted, transferred, decisions, err = policyCleaner.DeleteWithPolicies(ctx, token, copies, conf)
+			return deleted, transferred, decisions, false, err
+		}
+		deleted, err = cleaner.Delete(ctx, token, conf)
+		return deleted, transferred, decisions, false, err
+	}
+	transferer, ok := cleaner.(ResourceTransferer)
+	if !ok {
+		return deleted, transferred, decisions, true, nil
+	}
+	transferred, err = transferer.Transfer(ctx, token, *target, copies, conf)
+	return deleted, transferred, decisions, false, err
+}
+
 type IdWrapper struct {
 	Id string `json:"id"`
 }
@@ -0,0 +1,67 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctrl
+
+import (
+	"log"
+	"time"
+)
+
+const (
+	EventUserDeletionStarted = "USER_DELETION_STARTED"
+	EventUserServiceCleaned  = "USER_SERVICE_CLEANED"
+	EventUserDeleted         = "USER_DELETED"
+	EventUserDeletionFailed  = "USER_DELETION_FAILED"
+)
+
+// UserEventMsg reports the progress of a user deletion on the UserEventsTopic
+type UserEventMsg struct {
+	Event       string              `json:"event"`
+	Id          string              `json:"id"`
+	Command     string              `json:"command"`
+	TargetId    string              `json:"target_id,omitempty"`   //receiver of the resources of a TRANSFER
+	Service     string              `json:"service,omitempty"`     //USER_SERVICE_CLEANED only
+	Count       int                 `json:"count"`                 //USER_SERVICE_CLEANED: number of deleted resources
+	Transferred int                 `json:"transferred,omitempty"` //USER_SERVICE_CLEANED: number of transferred resources
+	Error       string              `json:"error,omitempty"`       //USER_DELETION_FAILED only
+	Orphans     map[string][]string `json:"orphans,omitempty"`     //USER_DELETION_FAILED: resources remaining after the cleanup by service
+	Time        time.Time           `json:"time"`
+}
+
+// UserEventPublisher receives the events of user deletions
+type UserEventPublisher func(event UserEventMsg) error
+
+func newUserEvent(event string, job DeletionJob) UserEventMsg {
+	return UserEventMsg{
+		Event:    event,
+		Id:       job.UserId,
+		Command:  job.Command,
+		TargetId: job.TargetUserId,
+		Time:     time.Now(),
+	}
+}
+
+// publish is a noop for a nil publisher; errors are only logged, to not fail the deletion because of a missing event
+func (publisher UserEventPublisher) publish(event UserEventMsg) {
+	if publisher == nil {
+		return
+	}
+	err := publisher(event)
+	if err != nil {
+		log.Println("ERROR: unable to publish user event", event.Event, event.Id, err)
+	}
+}
@@ -0,0 +1,401 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctrl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+)
+
+const (
+	UserSortUsername     = "username"
+	UserSortUsernameDesc = "-username"
+)
+
+// UserListOptions selects a page of users; keycloak orders users by username, so paging, search and attribute filters
+// are pushed down to keycloak where possible
+type UserListOptions struct {
+	Limit      int               //max number of users; 0 lists all users from Offset
+	Offset     int               //number of skipped users
+	Search     string            //keycloak search in username, email, first and last name
+	Attributes map[string]string //all attributes have to match
+	Sort       string            //UserSortUsername (default) or UserSortUsernameDesc
+	ExcludeId  string            //user omitted from the list (e.g. the caller)
+}
+
+func ValidateUserListOptions(options UserListOptions) error {
+	if options.Limit < 0 || options.Offset < 0 {
+		return errors.New("limit and offset have to be positive")
+	}
+	if options.Sort != "" && options.Sort != UserSortUsername && options.Sort != UserSortUsernameDesc {
+		return fmt.Errorf("unknown sort %v (known: %v, %v)", options.Sort, UserSortUsername, UserSortUsernameDesc)
+	}
+	return nil
+}
+
+// ListUsers returns a page of all users matching the options and the total number of matching users.
+// Keycloak ignores attribute filters in combination with a search; this combination, and a search excluding a user,
+// are paged in memory.
+func ListUsers(ctx context.Context, options UserListOptions, conf configuration.Config) (users []User, total int, err error) {
+	usersUrl := conf.KeycloakUrl + "/auth/admin/realms/" + conf.KeycloakRealm + "/users"
+	if options.Search != "" && (len(options.Attributes) > 0 || options.ExcludeId != "") {
+		users, err = getUsers(ctx, usersUrl+"?"+searchQuery(options.Search).Encode(), options.ExcludeId, conf)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(options.Attributes) > 0 {
+			ids, err := findUserIds(ctx, attributeQuery(options.Attributes), conf)
+			if err != nil {
+				return nil, 0, err
+			}
+			users = slices.DeleteFunc(users, func(user User) bool { return !ids[user.Id] })
+		}
+		users, total = pageUsers(users, options)
+		return users, total, nil
+	}
+
+	query := attributeQuery(options.Attributes)
+	if options.Search != "" {
+		query = searchQuery(options.Search)
+	}
+	token, err := EnsureAccess(ctx, conf)
+	if err != nil {
+		return nil, 0, err
+	}
+	err = token.GetJSON(usersUrl+"/count?"+query.Encode(), &total)
+	if err != nil {
+		return nil, 0, err
+	}
+	var excluded *User //excluded user, if it is part of the unfiltered list
+	if options.ExcludeId != "" {
+		user, err := GetUserById(ctx, options.ExcludeId, conf)
+		if err != nil {
+			return nil, 0, err
+		}
+		if matchesAttributes(user, options.Attributes) {
+			excluded = &user
+			total--
+		}
+	}
+
+	//the window [first, first+limit) of the ascending list is requested; descending pages are mirrored
+	first, limit := options.Offset, options.Limit
+	if limit == 0 {
+		limit = total - first
+	}
+	desc := options.Sort == UserSortUsernameDesc
+	if desc {
+		end := total - options.Offset
+		first = max(end-limit, 0)
+		limit = end - first
+	}
+	if limit <= 0 {
+		return []User{}, total, nil
+	}
+	size := limit
+	if excluded != nil {
+		size++ //replaces the excluded user, if it is part of the window or precedes it
+	}
+	users, err = getUsersWindow(ctx, usersUrl+"?"+query.Encode(), first, size, conf)
+	if err != nil {
+		return nil, 0, err
+	}
+	if excluded != nil {
+		if i := slices.IndexFunc(users, func(user User) bool { return user.Id == excluded.Id }); i >= 0 {
+			users = slices.Delete(users, i, i+1)
+		} else if first > 0 {
+			//keycloak orders usernames by the collation of its database, so the position of the excluded user is looked up instead of compared
+			briefQuery := url.Values{}
+			for key, values := range query {
+				briefQuery[key] = values
+			}
+			briefQuery.Set("briefRepresentation", "true")
+			precedes, err := isAmongFirstUsers(ctx, usersUrl+"?"+briefQuery.Encode(), first, excluded.Id, conf)
+			if err != nil {
+				return nil, 0, err
+			}
+			if precedes && len(users) > 0 {
+				users = users[1:] //the excluded user precedes the window, which shifts the list by one
+			}
+		}
+		users = users[:min(limit, len(users))]
+	}
+	if desc {
+		slices.Reverse(users)
+	}
+	return users, total, nil
+}
+
+// ListGroupMembers returns a page of the combined members of the groups matching the options and the total number of matching members.
+// The members of a single group are paged by keycloak, if neither Search nor Attributes are set (see listGroupMembersPage).
+// Otherwise, because keycloak can neither page the union of groups nor search or filter members, the members of every group are
+// requested page by page in full representation, then filtered, sorted and paged in memory. Like the keycloak search, Search
+// matches username, email, first and last name case-insensitively.
+func ListGroupMembers(ctx context.Context, groups []Group, options UserListOptions, conf configuration.Config) (users []User, total int, err error) {
+	if len(groups) == 1 && options.Search == "" && len(options.Attributes) == 0 {
+		return listGroupMembersPage(ctx, groups[0].ID, options, conf)
+	}
+	users = []User{}
+	known := map[string]bool{}
+	for _, group := range groups {
+		members, err := getGroupMembers(ctx, group.ID, false, conf)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, member := range members {
+			if known[member.Id] || member.Id == options.ExcludeId || !matchesSearch(member, options.Search) || !matchesAttributes(member.User, options.Attributes) {
+				continue
+			}
+			known[member.Id] = true
+			users = append(users, member.User)
+		}
+	}
+	users, total = pageUsers(users, options)
+	return users, total, nil
+}
+
+// listGroupMembersPage returns a page of the members of the group in the order of keycloak.
+// Keycloak has no count of group members: the ordered ids are listed in brief representation, which also locates the excluded user;
+// only the requested page is requested in full representation.
+func listGroupMembersPage(ctx context.Context, groupId string, options UserListOptions, conf configuration.Config) (users []User, total int, err error) {
+	members, err := getGroupMembers(ctx, groupId, true, conf)
+	if err != nil {
+		return nil, 0, err
+	}
+	ids := []string{}
+	for _, member := range members {
+		if member.Id != options.ExcludeId {
+			ids = append(ids, member.Id)
+		}
+	}
+	total = len(ids)
+
+	//the window [first, end) of the ascending list is requested; descending pages are mirrored
+	first, end := min(options.Offset, total), total
+	if options.Limit > 0 {
+		end = min(first+options.Limit, total)
+	}
+	if options.Sort == UserSortUsernameDesc {
+		first, end = total-end, total-first
+	}
+	if first >= end {
+		return []User{}, total, nil
+	}
+	wanted := map[string]bool{}
+	for _, id := range ids[first:end] {
+		wanted[id] = true
+	}
+	//the window starts at the position of its first member in the unfiltered list and may contain the excluded user
+	start := slices.IndexFunc(members, func(member groupMember) bool { return member.Id == ids[first] })
+	page, err := getGroupMembersWindow(ctx, groupId, start, end-first+1, false, conf)
+	if err != nil {
+		return nil, 0, err
+	}
+	users = []User{}
+	for _, member := range page {
+		if wanted[member.Id] {
+			users = append(users, member.User)
+		}
+	}
+	if options.Sort == UserSortUsernameDesc {
+		slices.Reverse(users)
+	}
+	return users, total, nil
+}
+
+// GetGroupMemberIds returns the ids of the combined members of the groups
+func GetGroupMemberIds(ctx context.Context, groups []Group, conf configuration.Config) (map[string]bool, error) {
+	ids := map[string]bool{}
+	for _, group := range groups {
+		members, err := getGroupMembers(ctx, group.ID, true, conf)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			ids[member.Id] = true
+		}
+	}
+	return ids, nil
+}
+
+// groupMember is the keycloak representation of a user with the fields matched by the keycloak search
+type groupMember struct {
+	User
+	Email     string `json:"email"`
+	FirstName string `json:"firstName"`
+	LastName  string `json:"lastName"`
+}
+
+// getGroupMembers returns all members of the group; brief members have no attributes
+func getGroupMembers(ctx context.Context, groupId string, brief bool, conf configuration.Config) (members []groupMember, err error) {
+	return getGroupMembersWindow(ctx, groupId, 0, 0, brief, conf)
+}
+
+// getGroupMembersWindow returns up to size members of the group, starting at first; 0 requests all remaining members
+func getGroupMembersWindow(ctx context.Context, groupId string, first int, size int, brief bool, conf configuration.Config) (members []groupMember, err error) {
+	membersUrl := conf.KeycloakUrl + "/auth/admin/realms/" + conf.KeycloakRealm + "/groups/" + url.PathEscape(groupId) + "/members"
+	for size <= 0 || len(members) < size {
+		token, err := EnsureAccess(ctx, conf)
+		if err != nil {
+			return nil, err
+		}
+		pageMax := conf.KeycloakPageMax
+		if size > 0 {
+			pageMax = min(pageMax, size-len(members))
+		}
+		var page []groupMember
+		err = token.GetJSON(membersUrl+fmt.Sprintf("?first=%d&max=%d&briefRepresentation=%v", first+len(members), pageMax, brief), &page)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, page...)
+		if len(page) == 0 || len(page) < pageMax {
+			break
+		}
+	}
+	return members, nil
+}
+
+func matchesSearch(member groupMember, search string) bool {
+	if search == "" {
+		return true
+	}
+	search = strings.ToLower(search)
+	for _, field := range []string{member.Name, member.Email, member.FirstName, member.LastName} {
+		if strings.Contains(strings.ToLower(field), search) {
+			return true
+		}
+	}
+	return false
+}
+
+// pageUsers sorts and pages users in memory
+func pageUsers(users []User, options UserListOptions) (page []User, total int) {
+	sort.SliceStable(users, func(i, j int) bool {
+		if options.Sort == UserSortUsernameDesc {
+			return users[i].Name > users[j].Name
+		}
+		return users[i].Name < users[j].Name
+	})
+	total = len(users)
+	start := min(options.Offset, total)
+	end := total
+	if options.Limit > 0 {
+		end = min(start+options.Limit, total)
+	}
+	return append([]User{}, users[start:end]...), total
+}
+
+// getUsersWindow returns up to size users of the keycloak list, starting at first
+func getUsersWindow(ctx context.Context, usersUrl string, first int, size int, conf configuration.Config) ([]User, error) {
+	users := []User{}
+	separator := "?"
+	if strings.Contains(usersUrl, "?") {
+		separator = "&"
+	}
+	for len(users) < size {
+		token, err := EnsureAccess(ctx, conf)
+		if err != nil {
+			return nil, err
+		}
+		var page []User
+		err = token.GetJSON(usersUrl+separator+fmt.Sprintf("max=%d&first=%d", min(conf.KeycloakPageMax, size-len(users)), first+len(users)), &page)
+		if err != nil {
+			return nil, err
+		}
+		if len(page) == 0 {
+			break
+		}
+		users = append(users, page...)
+	}
+	return users[:min(size, len(users))], nil
+}
+
+// isAmongFirstUsers reports whether the user is one of the first count users of the keycloak list; pages are requested until the user is found
+func isAmongFirstUsers(ctx context.Context, usersUrl string, count int, id string, conf configuration.Config) (bool, error) {
+	for first := 0; first < count; first += conf.KeycloakPageMax {
+		page, err := getUsersWindow(ctx, usersUrl, first, min(conf.KeycloakPageMax, count-first), conf)
+		if err != nil {
+			return false, err
+		}
+		if slices.ContainsFunc(page, func(user User) bool { return user.Id == id }) {
+			return true, nil
+		}
+		if len(page) == 0 {
+			return false, nil
+		}
+	}
+	return false, nil
+}
+
+// findUserIds returns the ids of all users matching the keycloak query
+func findUserIds(ctx context.Context, query url.Values, conf configuration.Config) (map[string]bool, error) {
+	query.Set("briefRepresentation", "true")
+	users, err := getUsers(ctx, conf.KeycloakUrl+"/auth/admin/realms/"+conf.KeycloakRealm+"/users?"+query.Encode(), "", conf)
+	if err != nil {
+		return nil, err
+	}
+	ids := map[string]bool{}
+	for _, user := range users {
+		ids[user.Id] = true
+	}
+	return ids, nil
+}
+
+func searchQuery(search string) url.Values {
+	query := url.Values{}
+	if search != "" {
+		query.Set("search", search)
+	}
+	return query
+}
+
+// attributeQuery returns the q search parameter of keycloak, which matches all attributes
+func attributeQuery(attributes map[string]string) url.Values {
+	query := url.Values{}
+	if len(attributes) == 0 {
+		return query
+	}
+	keys := []string{}
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := []string{}
+	for _, key := range keys {
+		pairs = append(pairs, key+":"+attributes[key])
+	}
+	query.Set("q", strings.Join(pairs, " "))
+	return query
+}
+
+// matchesAttributes reports whether the user has all attributes; like keycloak, any value of an attribute may match
+func matchesAttributes(user User, attributes map[string]string) bool {
+	for key, expected := range attributes {
+		values, _ := user.Attributes[key].([]interface{})
+		if !slices.Contains(values, interface{}(expected)) {
+			return false
+		}
+	}
+	return true
+}
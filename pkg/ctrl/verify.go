@@ -0,0 +1,67 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctrl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"log"
+)
+
+var ErrRemainingResources = errors.New("resources remain after cleanup")
+
+// verifyCleaner re-queries the service with the token of the user after its cleanup. Remaining resources are cleaned
+// again by retry, up to conf.DeletionVerifyRetries times, before the step fails with ErrRemainingResources.
+// The keycloak user is not verified, because its removal is the end of the deletion.
+func verifyCleaner(ctx context.Context, cleaner ServiceCleaner, token Token, conf configuration.Config, retry func() error) (orphans []string, err error) {
+	if _, isKeycloak := cleaner.(KeycloakCleaner); !conf.DeletionVerify || isKeycloak {
+		return orphans, nil
+	}
+	for attempt := 0; ; attempt++ {
+		orphans, err = cleaner.Verify(ctx, token.WithContext(ctx), conf)
+		if err != nil {
+			return orphans, fmt.Errorf("unable to verify cleanup: %w", err)
+		}
+		if len(orphans) == 0 {
+			return orphans, nil
+		}
+		if attempt >= conf.DeletionVerifyRetries {
+			return orphans, fmt.Errorf("%w: %v", ErrRemainingResources, orphans)
+		}
+		log.Println("WARNING:", len(orphans), "resources remain in", cleaner.Name(), "for", token.GetUserId(), "; retry cleanup")
+		err = retry()
+		if err != nil {
+			return orphans, err
+		}
+	}
+}
+
+// getJobOrphans returns the remaining resources of the job by service
+func getJobOrphans(job DeletionJob) (result map[string][]string) {
+	for _, step := range job.Steps {
+		if len(step.Orphans) == 0 {
+			continue
+		}
+		if result == nil {
+			result = map[string][]string{}
+		}
+		result[step.Service] = step.Orphans
+	}
+	return result
+}
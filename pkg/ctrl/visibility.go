@@ -0,0 +1,126 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package ctrl
+
+import (
+	"context"
+	"fmt"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"slices"
+	"strings"
+)
+
+// visibilities of user attributes, from the least to the most restrictive
+const (
+	AttributeVisibilityPublic       = "public"        //every caller
+	AttributeVisibilityGroupMembers = "group-members" //callers sharing a group with the user
+	AttributeVisibilitySelf         = "self"          //the user
+	AttributeVisibilityAdmin        = "admin"         //only admins
+)
+
+var AttributeVisibilities = []string{AttributeVisibilityPublic, AttributeVisibilityGroupMembers, AttributeVisibilitySelf, AttributeVisibilityAdmin}
+
+// UserRelation is the relationship between a caller and a user; it is ordered like AttributeVisibilities,
+// so a caller sees every attribute with a visibility up to its relation (e.g. admins see everything)
+type UserRelation int
+
+const (
+	RelationOther UserRelation = iota
+	RelationGroupMember
+	RelationSelf
+	RelationAdmin
+)
+
+// ValidateAttributeVisibility checks AttributeVisibility and AttributeVisibilityDefault
+func ValidateAttributeVisibility(conf configuration.Config) error {
+	if conf.AttributeVisibilityDefault != "" && !slices.Contains(AttributeVisibilities, conf.AttributeVisibilityDefault) {
+		return fmt.Errorf("unknown AttributeVisibilityDefault %v (known: %v)", conf.AttributeVisibilityDefault, strings.Join(AttributeVisibilities, ", "))
+	}
+	for attribute, visibility := range conf.AttributeVisibility {
+		if !slices.Contains(AttributeVisibilities, visibility) {
+			return fmt.Errorf("unknown visibility %v of attribute %v (known: %v)", visibility, attribute, strings.Join(AttributeVisibilities, ", "))
+		}
+	}
+	return nil
+}
+
+// GetAttributeVisibility returns the configured visibility of the attribute; attributes without configuration are public
+// unless AttributeVisibilityDefault is set
+func GetAttributeVisibility(conf configuration.Config, attribute string) string {
+	if visibility, ok := conf.AttributeVisibility[attribute]; ok {
+		return visibility
+	}
+	if conf.AttributeVisibilityDefault != "" {
+		return conf.AttributeVisibilityDefault
+	}
+	return AttributeVisibilityPublic
+}
+
+// IsAttributeVisible reports whether a caller with the relation sees the attribute
+func IsAttributeVisible(conf configuration.Config, attribute string, relation UserRelation) bool {
+	return slices.Index(AttributeVisibilities, GetAttributeVisibility(conf, attribute)) <= int(relation)
+}
+
+// GetUserRelation returns the relation of the caller to the user. Groups are only requested from keycloak,
+// if the user has attributes visible to group members, which would otherwise be hidden.
+func GetUserRelation(ctx context.Context, callerId string, callerIsAdmin bool, user User, conf configuration.Config) (UserRelation, error) {
+	switch {
+	case callerIsAdmin:
+		return RelationAdmin, nil
+	case callerId == user.Id:
+		return RelationSelf, nil
+	}
+	needsGroups := false
+	for attribute := range user.Attributes {
+		if GetAttributeVisibility(conf, attribute) == AttributeVisibilityGroupMembers {
+			needsGroups = true
+			break
+		}
+	}
+	if !needsGroups {
+		return RelationOther, nil
+	}
+	callerGroups, err := GetUsersGroups(ctx, callerId, conf)
+	if err != nil {
+		return RelationOther, err
+	}
+	userGroups, err := GetUsersGroups(ctx, user.Id, conf)
+	if err != nil {
+		return RelationOther, err
+	}
+	for _, group := range userGroups {
+		if slices.ContainsFunc(callerGroups, func(callerGroup Group) bool { return callerGroup.ID == group.ID }) {
+			return RelationGroupMember, nil
+		}
+	}
+	return RelationOther, nil
+}
+
+// FilterUserAttributes returns the user with the attributes visible to a caller with the relation
+func FilterUserAttributes(user User, relation UserRelation, conf configuration.Config) User {
+	if user.Attributes == nil {
+		return user
+	}
+	attributes := map[string]interface{}{}
+	for attribute, value := range user.Attributes {
+		if IsAttributeVisible(conf, attribute, relation) {
+			attributes[attribute] = value
+		}
+	}
+	user.Attributes = attributes
+	return user
+}
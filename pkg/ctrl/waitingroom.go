@@ -17,6 +17,7 @@
 package ctrl
 
 import (
+	"context"
 	"errors"
 	"github.com/SENERGY-Platform/user-management/pkg/configuration"
 	"io"
@@ -26,35 +27,53 @@ import (
 
 var BatchSize = 100
 
-func DeleteWaitingRoomUser(token Token, conf configuration.Config) error {
-	if conf.WaitingRoomUrl == "" || conf.WaitingRoomUrl == "-" {
-		return nil
-	}
+type WaitingRoomCleaner struct{}
+
+func (this WaitingRoomCleaner) Name() string {
+	return "waiting-room"
+}
 
+func (this WaitingRoomCleaner) Enabled(conf configuration.Config) bool {
+	return isSet(conf.WaitingRoomUrl)
+}
+
+func (this WaitingRoomCleaner) List(ctx context.Context, token Token, conf configuration.Config) ([]ServiceResources, error) {
+	ids, err := listAllPages(func(limit int, offset int) ([]string, error) {
+		return getBatchOfWaitingRoomDeviceIds(token, conf, limit, offset)
+	})
+	return []ServiceResources{{Resource: "devices", Ids: ids}}, err
+}
+
+func (this WaitingRoomCleaner) Delete(ctx context.Context, token Token, conf configuration.Config) (deleted []string, err error) {
 	loopLimit := 10000
 	loopCount := 0
 	for {
 		ids, err := getBatchOfWaitingRoomDeviceIds(token, conf, BatchSize, 0)
 		if err != nil {
-			return err
+			return deleted, err
 		}
 		if len(ids) == 0 {
-			return nil
+			return deleted, nil
 		}
 
 		err = deleteBatchOfWaitingRoomDevices(token, conf, ids)
 		if err != nil {
-			return err
+			return deleted, err
 		}
+		deleted = append(deleted, ids...)
 
 		loopCount = loopCount + 1
 		if loopCount == loopLimit {
-			return errors.New("DeleteWaitingRoomUser() reach loop limit")
+			return deleted, errors.New("DeleteWaitingRoomUser() reach loop limit")
 		}
 	}
 
 }
 
+func (this WaitingRoomCleaner) Verify(ctx context.Context, token Token, conf configuration.Config) (remaining []string, err error) {
+	return verifyByList(ctx, this, token, conf)
+}
+
 func deleteBatchOfWaitingRoomDevices(token Token, conf configuration.Config, ids []string) error {
 	if len(ids) > 0 {
 		resp, err := token.Impersonate().DeleteWithBody(conf.WaitingRoomUrl+"/devices", ids)
@@ -27,23 +27,47 @@ import (
 	"time"
 )
 
-func NewConsumer(ctx context.Context, wg *sync.WaitGroup, broker string, groupid string, topic string, initTopic bool, listener func(topic string, msg []byte, t time.Time) error, errorhandler func(err error, consumer *Consumer)) (consumer *Consumer, err error) {
-	consumer = &Consumer{ctx: ctx, wg: wg, groupId: groupid, broker: broker, topic: topic, listener: listener, errorhandler: errorhandler, initTopic: initTopic}
+// ErrPoisonMessage marks listener errors, which will not be resolved by retries (e.g. unparsable messages)
+var ErrPoisonMessage = errors.New("poison message")
+
+// DeadLetter describes a message, which could not be handled by the listener
+type DeadLetter struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Time      time.Time
+	Error     error
+	Attempts  int64
+}
+
+func NewConsumer(ctx context.Context, wg *sync.WaitGroup, broker string, groupid string, topic string, initTopic bool, listener func(ctx context.Context, topic string, msg []byte, t time.Time) error, errorhandler func(err error, consumer *Consumer)) (consumer *Consumer, err error) {
+	return NewConsumerWithDeadLetters(ctx, wg, broker, groupid, topic, initTopic, listener, nil, errorhandler)
+}
+
+// NewConsumerWithDeadLetters passes messages, which fail after all retries or with ErrPoisonMessage, to deadLetterHandler.
+// The message is committed if deadLetterHandler succeeds. A nil deadLetterHandler skips such messages without commit.
+// The listener receives ctx; messages interrupted by the cancellation of ctx are neither dead-lettered nor committed,
+// so they are consumed again after a restart.
+func NewConsumerWithDeadLetters(ctx context.Context, wg *sync.WaitGroup, broker string, groupid string, topic string, initTopic bool, listener func(ctx context.Context, topic string, msg []byte, t time.Time) error, deadLetterHandler func(letter DeadLetter) error, errorhandler func(err error, consumer *Consumer)) (consumer *Consumer, err error) {
+	consumer = &Consumer{ctx: ctx, wg: wg, groupId: groupid, broker: broker, topic: topic, listener: listener, deadLetterHandler: deadLetterHandler, errorhandler: errorhandler, initTopic: initTopic}
 	err = consumer.start()
 	return
 }
 
 type Consumer struct {
-	wg           *sync.WaitGroup
-	count        int
-	broker       string
-	groupId      string
-	topic        string
-	ctx          context.Context
-	listener     func(topic string, msg []byte, t time.Time) error
-	errorhandler func(err error, consumer *Consumer)
-	mux          sync.Mutex
-	initTopic    bool
+	wg                *sync.WaitGroup
+	count             int
+	broker            string
+	groupId           string
+	topic             string
+	ctx               context.Context
+	listener          func(ctx context.Context, topic string, msg []byte, t time.Time) error
+	deadLetterHandler func(letter DeadLetter) error
+	errorhandler      func(err error, consumer *Consumer)
+	mux               sync.Mutex
+	initTopic         bool
 }
 
 func (this *Consumer) start() (err error) {
@@ -86,12 +110,29 @@ func (this *Consumer) start() (err error) {
 					return
 				}
 
-				err = retry(func() error {
-					return this.listener(m.Topic, m.Value, m.Time)
+				attempts, err := retry(this.ctx, func() error {
+					return this.listener(this.ctx, m.Topic, m.Value, m.Time)
 				}, func(n int64) time.Duration {
 					return time.Duration(n) * time.Second
 				}, 10*time.Minute)
 
+				if err != nil && this.ctx.Err() != nil {
+					log.Println("WARNING: message handling interrupted by shutdown (no commit)", m.Topic, m.Partition, m.Offset)
+					return
+				}
+				if err != nil && this.deadLetterHandler != nil {
+					log.Println("ERROR: unable to handle message (dead letter)", err)
+					err = this.deadLetterHandler(DeadLetter{
+						Topic:     m.Topic,
+						Partition: m.Partition,
+						Offset:    m.Offset,
+						Key:       m.Key,
+						Value:     m.Value,
+						Time:      m.Time,
+						Error:     err,
+						Attempts:  attempts,
+					})
+				}
 				if err != nil {
 					log.Println("ERROR: unable to handle message (no commit)", err)
 					this.errorhandler(err, this)
@@ -104,21 +145,30 @@ func (this *Consumer) start() (err error) {
 	return err
 }
 
-func retry(f func() error, waitProvider func(n int64) time.Duration, timeout time.Duration) (err error) {
+// retry calls f until it succeeds, returns an ErrPoisonMessage, the timeout is reached or ctx is canceled
+func retry(ctx context.Context, f func() error, waitProvider func(n int64) time.Duration, timeout time.Duration) (attempts int64, err error) {
 	err = errors.New("")
 	start := time.Now()
-	for i := int64(1); err != nil && time.Since(start) < timeout; i++ {
+	for attempts = 1; err != nil && time.Since(start) < timeout; attempts++ {
 		err = f()
+		if errors.Is(err, ErrPoisonMessage) {
+			log.Println("ERROR: kafka listener error (no retry):", err)
+			return attempts, err
+		}
 		if err != nil {
 			log.Println("ERROR: kafka listener error:", err)
-			wait := waitProvider(i)
+			wait := waitProvider(attempts)
 			if time.Since(start)+wait < timeout {
 				log.Println("ERROR: retry after:", wait.String())
-				time.Sleep(wait)
+				select {
+				case <-ctx.Done():
+					return attempts, err
+				case <-time.After(wait):
+				}
 			} else {
-				return err
+				return attempts, err
 			}
 		}
 	}
-	return err
+	return attempts - 1, err
 }
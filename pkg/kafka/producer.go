@@ -22,6 +22,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"strconv"
 	"time"
 )
 
@@ -56,8 +57,34 @@ func GetKafkaWriter(broker string, topic string, debug bool) (writer *kafka.Writ
 	return writer, err
 }
 
+// ProduceDeadLetter writes the original key and value of the letter; error, attempts and origin are passed as headers
+func (this *Producer) ProduceDeadLetter(letter DeadLetter) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	errMsg := ""
+	if letter.Error != nil {
+		errMsg = letter.Error.Error()
+	}
+	return this.writer.WriteMessages(
+		ctx,
+		kafka.Message{
+			Key:   letter.Key,
+			Value: letter.Value,
+			Time:  time.Now(),
+			Headers: []kafka.Header{
+				{Key: "error", Value: []byte(errMsg)},
+				{Key: "attempts", Value: []byte(strconv.FormatInt(letter.Attempts, 10))},
+				{Key: "original-topic", Value: []byte(letter.Topic)},
+				{Key: "original-partition", Value: []byte(strconv.Itoa(letter.Partition))},
+				{Key: "original-offset", Value: []byte(strconv.FormatInt(letter.Offset, 10))},
+			},
+		},
+	)
+}
+
 func (this *Producer) Produce(key []byte, msg []byte) error {
-	ctx, _ := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 	return this.writer.WriteMessages(
 		ctx,
 		kafka.Message{
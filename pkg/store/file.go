@@ -0,0 +1,101 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// File is a Memory store which writes its complete state to a json file on every change
+type File[T any] struct {
+	*Memory[T]
+	location string
+}
+
+func NewFile[T any](location string) (result *File[T], err error) {
+	result = &File[T]{Memory: NewMemory[T](), location: location}
+	err = os.MkdirAll(filepath.Dir(location), 0o700)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(location)
+	if errors.Is(err, os.ErrNotExist) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > 0 {
+		err = json.Unmarshal(content, &result.values)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (this *File[T]) Set(key string, value T) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	old, existed := this.values[key]
+	this.values[key] = raw
+	err = this.write()
+	if err != nil {
+		if existed {
+			this.values[key] = old
+		} else {
+			delete(this.values, key)
+		}
+	}
+	return err
+}
+
+func (this *File[T]) Remove(key string) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	old, existed := this.values[key]
+	if !existed {
+		return nil
+	}
+	delete(this.values, key)
+	err := this.write()
+	if err != nil {
+		this.values[key] = old
+	}
+	return err
+}
+
+// write expects the caller to hold the lock; the file is replaced atomically to survive crashes while writing
+func (this *File[T]) write() error {
+	content, err := json.Marshal(this.values)
+	if err != nil {
+		return err
+	}
+	temp := this.location + ".tmp"
+	err = os.WriteFile(temp, content, 0o600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(temp, this.location)
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// logCompactionMinLines is the number of lines a log may always reach before it is compacted
+const logCompactionMinLines = 1000
+
+// Log is a Memory store which appends every change as a json line to a file, instead of rewriting the complete state.
+// The file is compacted on load and whenever outdated lines outnumber the current values.
+type Log[T any] struct {
+	*Memory[T]
+	location string
+	file     *os.File
+	lines    int
+}
+
+type logLine struct {
+	Key   string          `json:"key"`
+	Value json.RawMessage `json:"value,omitempty"` //empty for removed keys
+}
+
+func NewLog[T any](location string) (result *Log[T], err error) {
+	result = &Log[T]{Memory: NewMemory[T](), location: location}
+	err = os.MkdirAll(filepath.Dir(location), 0o700)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(location)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	lines := bytes.Split(content, []byte("\n"))
+	for i, raw := range lines {
+		if len(bytes.TrimSpace(raw)) == 0 {
+			continue
+		}
+		line := logLine{}
+		err = json.Unmarshal(raw, &line)
+		if err != nil {
+			if i == len(lines)-1 {
+				break //incomplete last line of an interrupted write
+			}
+			return nil, err
+		}
+		if len(line.Value) == 0 {
+			delete(result.values, line.Key)
+		} else {
+			result.values[line.Key] = line.Value
+		}
+	}
+	err = result.compact()
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (this *Log[T]) Set(key string, value T) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	err = this.append(logLine{Key: key, Value: raw})
+	if err != nil {
+		return err
+	}
+	this.values[key] = raw
+	return this.compactIfOutdated()
+}
+
+func (this *Log[T]) Remove(key string) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if _, existed := this.values[key]; !existed {
+		return nil
+	}
+	err := this.append(logLine{Key: key})
+	if err != nil {
+		return err
+	}
+	delete(this.values, key)
+	return this.compactIfOutdated()
+}
+
+// append expects the caller to hold the lock
+func (this *Log[T]) append(line logLine) error {
+	content, err := json.Marshal(line)
+	if err != nil {
+		return err
+	}
+	_, err = this.file.Write(append(content, '\n'))
+	if err != nil {
+		this.compact() //drops a partially written line
+		return err
+	}
+	this.lines++
+	return nil
+}
+
+// compactIfOutdated expects the caller to hold the lock
+func (this *Log[T]) compactIfOutdated() error {
+	if this.lines < logCompactionMinLines || this.lines < 2*len(this.values) {
+		return nil
+	}
+	return this.compact()
+}
+
+// compact replaces the file atomically with one line per current value; it expects the caller to hold the lock
+func (this *Log[T]) compact() error {
+	temp := this.location + ".tmp"
+	file, err := os.OpenFile(temp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	for key, value := range this.values {
+		content, err := json.Marshal(logLine{Key: key, Value: value})
+		if err == nil {
+			_, err = writer.Write(append(content, '\n'))
+		}
+		if err != nil {
+			file.Close()
+			return err
+		}
+	}
+	err = writer.Flush()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Rename(temp, this.location)
+	if err != nil {
+		return err
+	}
+	if this.file != nil {
+		this.file.Close()
+	}
+	this.file, err = os.OpenFile(this.location, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	this.lines = len(this.values)
+	return nil
+}
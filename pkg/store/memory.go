@@ -0,0 +1,82 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+)
+
+// Memory keeps values json encoded, so that callers never share slices or maps with the stored state
+type Memory[T any] struct {
+	mux    sync.RWMutex
+	values map[string]json.RawMessage
+}
+
+func NewMemory[T any]() *Memory[T] {
+	return &Memory[T]{values: map[string]json.RawMessage{}}
+}
+
+func (this *Memory[T]) Get(key string) (value T, exists bool, err error) {
+	this.mux.RLock()
+	defer this.mux.RUnlock()
+	raw, exists := this.values[key]
+	if !exists {
+		return value, false, nil
+	}
+	err = json.Unmarshal(raw, &value)
+	return value, true, err
+}
+
+func (this *Memory[T]) Set(key string, value T) error {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.values[key] = raw
+	return nil
+}
+
+func (this *Memory[T]) Remove(key string) error {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	delete(this.values, key)
+	return nil
+}
+
+func (this *Memory[T]) List() (values []T, err error) {
+	this.mux.RLock()
+	defer this.mux.RUnlock()
+	keys := make([]string, 0, len(this.values))
+	for key := range this.values {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	values = make([]T, 0, len(keys))
+	for _, key := range keys {
+		var value T
+		err = json.Unmarshal(this.values[key], &value)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
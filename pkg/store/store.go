@@ -0,0 +1,50 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package store
+
+import (
+	"errors"
+	"path/filepath"
+)
+
+const (
+	TypeMemory = "memory"
+	TypeFile   = "file"
+	TypeLog    = "log"
+)
+
+// Store is a simple key-value store for state that has to survive between kafka retries (and with the file implementation, restarts)
+type Store[T any] interface {
+	Get(key string) (value T, exists bool, err error)
+	Set(key string, value T) error
+	Remove(key string) error
+	List() (values []T, err error) //sorted by key
+}
+
+// New creates a store of the given type; file stores are persisted as <dir>/<name>.json, log stores as <dir>/<name>.jsonl
+func New[T any](storeType string, dir string, name string) (Store[T], error) {
+	switch storeType {
+	case "", TypeMemory:
+		return NewMemory[T](), nil
+	case TypeFile:
+		return NewFile[T](filepath.Join(dir, name+".json"))
+	case TypeLog:
+		return NewLog[T](filepath.Join(dir, name+".jsonl"))
+	default:
+		return nil, errors.New("unknown store type: " + storeType)
+	}
+}
@@ -0,0 +1,223 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"bytes"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/ctrl"
+	"github.com/SENERGY-Platform/user-management/pkg/store"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestDeletionAudit(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	config, _, setDashboardFailure := startDeletionMocks(t, t.Context(), config)
+
+	location := filepath.Join(t.TempDir(), "deletion-audit.jsonl")
+	audits, err := store.NewLog[ctrl.AuditEntry](location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jobs := store.NewMemory[ctrl.DeletionJob]()
+
+	requested := ctrl.NewAuditEntry(ctrl.AuditSourceApi, ctrl.Requester{Id: "admin", Admin: true}, "user1", "")
+	err = audits.Set(requested.Id, requested)
+	if err != nil {
+		t.Fatal(err)
+	}
+	command := ctrl.UserCommandMsg{Command: ctrl.CommandDelete, Id: "user1", AuditId: requested.Id}
+	deleteUser := func() error {
+		return ctrl.DeleteUser(t.Context(), "user1", config, jobs, nil)
+	}
+
+	setDashboardFailure(true)
+	err = ctrl.RunAudited(audits, jobs, command, time.Now(), deleteUser)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	entry, _, err := audits.Get(requested.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry.State != ctrl.DeletionFailed || entry.Error == "" || entry.RequesterId != "admin" || !entry.RequesterAdmin || entry.Source != ctrl.AuditSourceApi {
+		t.Errorf("%#v", entry)
+	}
+
+	setDashboardFailure(false)
+	err = ctrl.RunAudited(audits, jobs, command, time.Now(), deleteUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//command published by another service without audit id; retries update the same entry
+	msgTime := time.Now()
+	kafkaCommand := ctrl.UserCommandMsg{Command: ctrl.CommandDelete, Id: "user2", RequesterId: "user2"}
+	for range 2 {
+		err = ctrl.RunAudited(audits, jobs, kafkaCommand, msgTime, func() error { return nil })
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//simulate restart
+	audits, err = store.NewLog[ctrl.AuditEntry](location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := ctrl.ListAuditEntries(audits, ctrl.AuditFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("%#v", entries)
+	}
+	entry = entries[0]
+	if entry.Id != requested.Id || entry.State != ctrl.DeletionDone || entry.Error != "" || entry.Started == nil || entry.Finished == nil {
+		t.Errorf("%#v", entry)
+	}
+	deleted := map[string]int{}
+	for _, service := range entry.Services {
+		if service.State != ctrl.DeletionDone {
+			t.Errorf("%#v", service)
+		}
+		deleted[service.Service] = service.Deleted
+	}
+	if deleted["keycloak"] != 1 {
+		t.Errorf("%#v", entry.Services)
+	}
+	if entries[1].Source != ctrl.AuditSourceKafka || entries[1].UserId != "user2" || entries[1].RequesterId != "user2" || entries[1].RequesterAdmin {
+		t.Errorf("%#v", entries[1])
+	}
+
+	entries, err = ctrl.ListAuditEntries(audits, ctrl.AuditFilter{RequesterId: "admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].UserId != "user1" {
+		t.Errorf("%#v", entries)
+	}
+	entries, err = ctrl.ListAuditEntries(audits, ctrl.AuditFilter{UserId: "user2", From: msgTime.Add(-time.Second), To: msgTime.Add(time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].UserId != "user2" {
+		t.Errorf("%#v", entries)
+	}
+	entries, err = ctrl.ListAuditEntries(audits, ctrl.AuditFilter{From: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("%#v", entries)
+	}
+
+	t.Run("retention", func(t *testing.T) {
+		conf := config
+		conf.AuditRetention = "24h"
+		unfinished := ctrl.NewAuditEntry(ctrl.AuditSourceApi, ctrl.Requester{Id: "user3"}, "user3", "")
+		unfinished.State = ctrl.DeletionScheduled
+		err = audits.Set(unfinished.Id, unfinished)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ctrl.RemoveExpiredAuditEntries(conf, audits, time.Now().Add(23*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if list, _ := audits.List(); len(list) != 3 {
+			t.Errorf("%#v", list)
+		}
+		err = ctrl.RemoveExpiredAuditEntries(conf, audits, unfinished.Requested.Add(25*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if list, _ := audits.List(); len(list) != 0 {
+			t.Errorf("%#v", list)
+		}
+		conf.AuditRetention = "-1h"
+		if _, err := ctrl.GetAuditRetention(conf); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
+
+func TestLogStore(t *testing.T) {
+	location := filepath.Join(t.TempDir(), "values.jsonl")
+	values, err := store.NewLog[int](location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range 3000 {
+		err = values.Set(strconv.Itoa(i%10), i)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = values.Remove("9")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := bytes.Count(content, []byte("\n")); lines > 1000 {
+		t.Error("expected compacted log", lines)
+	}
+
+	//simulate a crash while appending
+	file, err := os.OpenFile(location, os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.WriteString(`{"key":"0","val`)
+	file.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err = store.NewLog[int](location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list, err := values.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(list, []int{2990, 2991, 2992, 2993, 2994, 2995, 2996, 2997, 2998}) {
+		t.Error(list)
+	}
+	err = values.Set("0", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	values, err = store.NewLog[int](location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, _, _ := values.Get("0"); value != 1 {
+		t.Error(value)
+	}
+}
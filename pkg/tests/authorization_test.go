@@ -0,0 +1,163 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"encoding/json"
+	"github.com/SENERGY-Platform/user-management/pkg/api"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/ctrl"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthorizationMiddleware(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	config.KeycloakUrl = mockKeycloakDirectory(t, []ctrl.User{{Id: "user1"}, {Id: "user2"}}, map[string][]string{"team": {"user2"}})
+	config.AuthorizationRules = []configuration.AuthorizationRule{
+		{Method: "GET", Route: "/doc", Public: true},
+		{Method: "GET", Route: "/user/id/:id"},
+		{Method: "DELETE", Route: "/user/id/:id", Roles: []string{"admin"}, Self: "id"},
+		{Method: "GET", Route: "/team/:id", Groups: []string{"/team"}},
+		{Method: "*", Route: "/admin/*path", Roles: []string{"admin"}},
+	}
+
+	router := httprouter.New()
+	ok := func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {}
+	router.GET("/doc", ok)
+	router.GET("/user/id/:id", ok)
+	router.DELETE("/user/id/:id", ok)
+	router.GET("/team/:id", ok)
+	router.GET("/admin/deletions", ok)
+	router.POST("/admin/orphans/scan", ok)
+	router.GET("/unruled", ok)
+	middleware, err := api.NewAuthorizationMiddleware(config, router)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token := func(userId string, roles ...string) string {
+		token, err := ctrl.CreateTokenWithRoles("test", userId, roles)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token.Token
+	}
+
+	for _, c := range []struct {
+		method string
+		path   string
+		auth   string
+		status int
+	}{
+		{"GET", "/doc", "", http.StatusOK},
+		{"GET", "/user/id/user2", "", http.StatusUnauthorized},
+		{"GET", "/user/id/user2", "Bearer foo", http.StatusUnauthorized},
+		{"GET", "/user/id/user2", token("user1"), http.StatusOK},
+		{"DELETE", "/user/id/user1", token("user1"), http.StatusOK},
+		{"DELETE", "/user/id/user2", token("user1"), http.StatusForbidden},
+		{"DELETE", "/user/id/user2", token("user1", "admin"), http.StatusOK},
+		{"GET", "/team/t1", token("user1"), http.StatusForbidden},
+		{"GET", "/team/t1", token("user2"), http.StatusOK},
+		{"GET", "/admin/deletions", token("user1"), http.StatusForbidden},
+		{"POST", "/admin/orphans/scan", token("user1", "admin"), http.StatusOK},
+		{"GET", "/unruled", token("user1", "admin"), http.StatusForbidden},
+		{"GET", "/unknown", token("user1", "admin"), http.StatusNotFound},
+	} {
+		req := httptest.NewRequest(c.method, c.path, nil)
+		if c.auth != "" {
+			req.Header.Set("Authorization", c.auth)
+		}
+		rec := httptest.NewRecorder()
+		middleware.ServeHTTP(rec, req)
+		if rec.Code != c.status {
+			t.Error(c.method, c.path, rec.Code, rec.Body.String())
+			continue
+		}
+		if c.status == http.StatusUnauthorized || c.status == http.StatusForbidden {
+			body := api.AuthErrorResponse{}
+			err = json.NewDecoder(rec.Body).Decode(&body)
+			if err != nil || body.Status != c.status || body.Error == "" || rec.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+				t.Error(c.method, c.path, body, err)
+			}
+		}
+	}
+
+	t.Run("invalid rules", func(t *testing.T) {
+		for _, rule := range []configuration.AuthorizationRule{
+			{Method: "get", Route: "/user"},
+			{Method: "GET", Route: "user"},
+			{Method: "GET", Route: "/admin/*path/foo"},
+			{Method: "GET", Route: "/user/id/:id", Self: "user"},
+		} {
+			conf := config
+			conf.AuthorizationRules = []configuration.AuthorizationRule{rule}
+			if _, err := api.NewAuthorizationMiddleware(conf, router); err == nil {
+				t.Error("expected error", rule)
+			}
+		}
+	})
+}
+
+func TestDestructiveRoutesNeedSelfOrAdmin(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	token, err := ctrl.CreateTokenWithRoles("test", "user1", []string{"user"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	misconfigured := config
+	misconfigured.AuthorizationRules = []configuration.AuthorizationRule{{Method: "*", Route: "/*path"}}
+
+	for name, conf := range map[string]configuration.Config{"shipped": config, "catch-all rule": misconfigured} {
+		router, err := api.NewRouter(conf, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, c := range []struct {
+			method string
+			path   string
+		}{
+			{"DELETE", "/user/id/user2"},
+			{"DELETE", "/user/id/user2?force=true"},
+			{"POST", "/user/id/user2/transfer"},
+			{"GET", "/user/id/user2/deletion"},
+			{"DELETE", "/user/id/user2/deletion"},
+			{"GET", "/user/id/user2/resources"},
+			{"POST", "/user/id/user2/export"},
+			{"POST", "/admin/users/bulk-delete"},
+			{"POST", "/admin/orphans/scan"},
+			{"GET", "/admin/audit/deletions"},
+			{"POST", "/admin/dead-letters/replay"},
+		} {
+			req := httptest.NewRequest(c.method, c.path, nil)
+			req.Header.Set("Authorization", token.Token)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != http.StatusForbidden {
+				t.Error(name, c.method, c.path, rec.Code, rec.Body.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,245 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/ctrl"
+	"github.com/SENERGY-Platform/user-management/pkg/store"
+	"github.com/SENERGY-Platform/user-management/pkg/tests/mocks"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// mockKeycloakDirectory serves the given users, group members (group id -> user ids), the groups of users and the user search (search, username, q and count, ordered by username);
+// token requests are forwarded to mocks.MockKeycloak
+func mockKeycloakDirectory(t *testing.T, users []ctrl.User, groups map[string][]string) (keycloakUrl string) {
+	mockUrl, err := mocks.MockKeycloak(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := url.Parse(mockUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	prefix := "/auth/admin/realms/master/"
+	page := func(w http.ResponseWriter, r *http.Request, list []ctrl.User) {
+		first, _ := strconv.Atoi(r.URL.Query().Get("first"))
+		max, _ := strconv.Atoi(r.URL.Query().Get("max"))
+		result := []ctrl.User{}
+		if first < len(list) {
+			result = list[first:min(first+max, len(list))]
+		}
+		json.NewEncoder(w).Encode(result)
+	}
+	matches := func(user ctrl.User, query url.Values) bool {
+		if search := query.Get("search"); search != "" {
+			return strings.Contains(strings.ToLower(user.Name), strings.ToLower(search))
+		}
+		if username := query.Get("username"); username != "" {
+			if query.Get("exact") == "true" && user.Name != username || !strings.Contains(user.Name, username) {
+				return false
+			}
+		}
+		for _, pair := range strings.Fields(query.Get("q")) {
+			key, value, _ := strings.Cut(pair, ":")
+			values, _ := user.Attributes[key].([]interface{})
+			if len(values) == 0 || values[0] != value {
+				return false
+			}
+		}
+		return true
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, prefix)
+		if path == r.URL.Path || r.Method != http.MethodGet {
+			proxy.ServeHTTP(w, r)
+			return
+		}
+		switch {
+		case path == "users" || path == "users/count":
+			result := []ctrl.User{}
+			for _, user := range users {
+				if matches(user, r.URL.Query()) {
+					result = append(result, user)
+				}
+			}
+			sortLikeKeycloak(result)
+			if path == "users/count" {
+				json.NewEncoder(w).Encode(len(result))
+				return
+			}
+			page(w, r, result)
+		case strings.HasPrefix(path, "users/") && strings.HasSuffix(path, "/groups"):
+			userId := strings.TrimSuffix(strings.TrimPrefix(path, "users/"), "/groups")
+			result := []ctrl.Group{}
+			for id, members := range groups {
+				if slices.Contains(members, userId) {
+					result = append(result, ctrl.Group{ID: id, Name: id, Path: "/" + id})
+				}
+			}
+			sort.Slice(result, func(i, j int) bool {
+				return result[i].ID < result[j].ID
+			})
+			first, _ := strconv.Atoi(r.URL.Query().Get("first"))
+			max, _ := strconv.Atoi(r.URL.Query().Get("max"))
+			json.NewEncoder(w).Encode(result[min(first, len(result)):min(first+max, len(result))])
+		case strings.HasPrefix(path, "users/"):
+			for _, user := range users {
+				if user.Id == strings.TrimPrefix(path, "users/") {
+					json.NewEncoder(w).Encode(user)
+					return
+				}
+			}
+			http.Error(w, "not found", http.StatusNotFound)
+		case strings.HasPrefix(path, "groups/") && strings.HasSuffix(path, "/members"):
+			result := []ctrl.User{}
+			for _, id := range groups[strings.TrimSuffix(strings.TrimPrefix(path, "groups/"), "/members")] {
+				for _, user := range users {
+					if user.Id == id {
+						if r.URL.Query().Get("briefRepresentation") == "true" {
+							user.Attributes = nil
+						}
+						result = append(result, user)
+					}
+				}
+			}
+			sortLikeKeycloak(result)
+			page(w, r, result)
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+// sortLikeKeycloak orders users by username case-insensitively, like the database collation of keycloak and unlike the byte order of go
+func sortLikeKeycloak(users []ctrl.User) {
+	sort.SliceStable(users, func(i, j int) bool {
+		return strings.ToLower(users[i].Name) < strings.ToLower(users[j].Name)
+	})
+}
+
+func TestBulkDeletion(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	config.KeycloakPageMax = 2
+	tenant := map[string]interface{}{"tenant": []interface{}{"test"}}
+	config.KeycloakUrl = mockKeycloakDirectory(t, []ctrl.User{
+		{Id: "admin", Name: "admin", Attributes: tenant},
+		{Id: "user1", Name: "user1", Attributes: tenant},
+		{Id: "user2", Name: "user2", Attributes: tenant},
+		{Id: "user3", Name: "user3", Attributes: tenant},
+		{Id: "user4", Name: "user4", Attributes: map[string]interface{}{"tenant": []interface{}{"customer"}}},
+	}, map[string][]string{"g1": {"user1", "admin", "user4"}})
+
+	userIds := func(preview ctrl.BulkDeletionPreview) (result []string) {
+		for _, user := range preview.Users {
+			result = append(result, user.Id)
+		}
+		return result
+	}
+
+	_, err = ctrl.ResolveBulkDeletion(t.Context(), ctrl.BulkDeletionRequest{GroupId: "g1", Ids: []string{"user1"}}, "admin", config)
+	if !errors.Is(err, ctrl.ErrInvalidBulkDeletion) {
+		t.Error(err)
+	}
+	preview, err := ctrl.ResolveBulkDeletion(t.Context(), ctrl.BulkDeletionRequest{Ids: []string{"user1", "unknown", "admin", "user1"}}, "admin", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := userIds(preview); !reflect.DeepEqual(ids, []string{"user1"}) || !reflect.DeepEqual(preview.Unknown, []string{"unknown"}) {
+		t.Errorf("%#v", preview)
+	}
+	preview, err = ctrl.ResolveBulkDeletion(t.Context(), ctrl.BulkDeletionRequest{GroupId: "g1"}, "admin", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := userIds(preview); !reflect.DeepEqual(ids, []string{"user1", "user4"}) {
+		t.Error(ids)
+	}
+	preview, err = ctrl.ResolveBulkDeletion(t.Context(), ctrl.BulkDeletionRequest{Attributes: map[string]string{"tenant": "test"}}, "admin", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := userIds(preview); !reflect.DeepEqual(ids, []string{"user1", "user2", "user3"}) {
+		t.Error(ids)
+	}
+
+	batches := store.NewMemory[ctrl.DeletionBatch]()
+	jobs := store.NewMemory[ctrl.DeletionJob]()
+	requested := []string{}
+	batch, err := ctrl.CreateDeletionBatch(preview.Users, "admin", batches, func(userId string) error {
+		if userId == "user3" {
+			return errors.New("test failure")
+		}
+		requested = append(requested, userId)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(requested, []string{"user1", "user2"}) || len(batch.Errors) != 1 || batch.Errors["user3"] == "" {
+		t.Errorf("%#v %#v", requested, batch)
+	}
+
+	job := ctrl.NewDeletionJob("user1", []string{"keycloak"})
+	job.State = ctrl.DeletionDone
+	err = jobs.Set(job.UserId, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	progress, err := ctrl.GetDeletionBatchProgress(batches, jobs, batch.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[ctrl.DeletionState]int{ctrl.DeletionDone: 1, ctrl.DeletionPending: 1, ctrl.DeletionFailed: 1}
+	if progress.Total != 3 || progress.Finished || !reflect.DeepEqual(progress.States, expected) {
+		t.Errorf("%#v", progress)
+	}
+
+	job.UserId = "user2"
+	err = jobs.Set(job.UserId, job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	progress, err = ctrl.GetDeletionBatchProgress(batches, jobs, batch.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !progress.Finished {
+		t.Errorf("%#v", progress)
+	}
+	_, err = ctrl.GetDeletionBatchProgress(batches, jobs, "unknown")
+	if !errors.Is(err, ctrl.ErrDeletionBatchNotFound) {
+		t.Error(err)
+	}
+}
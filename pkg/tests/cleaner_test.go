@@ -0,0 +1,117 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"context"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/ctrl"
+	"github.com/SENERGY-Platform/user-management/pkg/store"
+	"sync"
+	"testing"
+)
+
+type testCleaner struct {
+	mux     *sync.Mutex
+	enabled *bool
+	calls   *[]string
+}
+
+func (this testCleaner) Name() string {
+	return "test-cleaner"
+}
+
+func (this testCleaner) Enabled(conf configuration.Config) bool {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return *this.enabled
+}
+
+func (this testCleaner) List(ctx context.Context, token ctrl.Token, conf configuration.Config) ([]ctrl.ServiceResources, error) {
+	return []ctrl.ServiceResources{{Resource: "things", Ids: []string{"t1"}}}, nil
+}
+
+func (this testCleaner) Delete(ctx context.Context, token ctrl.Token, conf configuration.Config) (deleted []string, err error) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	*this.calls = append(*this.calls, token.GetUserId())
+	return []string{"t1"}, nil
+}
+
+func (this testCleaner) Verify(ctx context.Context, token ctrl.Token, conf configuration.Config) (remaining []string, err error) {
+	return nil, nil
+}
+
+func TestRegisterCleaner(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	config, _, _ = startDeletionMocks(t, t.Context(), config)
+
+	mux := &sync.Mutex{}
+	enabled := true
+	calls := []string{}
+	defer func() {
+		mux.Lock()
+		defer mux.Unlock()
+		enabled = false
+	}()
+	err = ctrl.RegisterCleaner(testCleaner{mux: mux, enabled: &enabled, calls: &calls})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ctrl.RegisterCleaner(ctrl.KeycloakCleaner{})
+	if err == nil {
+		t.Error("expected error on duplicate name")
+	}
+
+	cleaners := ctrl.GetCleaners(config)
+	if len(cleaners) < 2 || cleaners[len(cleaners)-2].Name() != "test-cleaner" || cleaners[len(cleaners)-1].Name() != "keycloak" {
+		t.Errorf("unexpected cleaner order %#v", cleaners)
+	}
+
+	jobs := store.NewMemory[ctrl.DeletionJob]()
+	err = ctrl.DeleteUser(t.Context(), "user1", config, jobs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 || calls[0] != "user1" {
+		t.Error(calls)
+	}
+	job, _, err := jobs.Get("user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if step := findStep(job, "test-cleaner"); step.State != ctrl.DeletionDone || step.Deleted != 1 {
+		t.Errorf("%#v", step)
+	}
+
+	resources, err := ctrl.GetUserResources(t.Context(), "user1", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, entry := range resources.Services {
+		if entry.Service == "test-cleaner" && entry.Count == 1 {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("%#v", resources)
+	}
+}
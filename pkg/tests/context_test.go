@@ -0,0 +1,253 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/ctrl"
+	"github.com/SENERGY-Platform/user-management/pkg/store"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockHungService lists one item and blocks delete requests until the request is canceled, as long as hung is set
+func mockHungService(t *testing.T) (url string, setHung func(bool)) {
+	mux := sync.Mutex{}
+	hung := true
+	deleted := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		isHung := hung
+		mux.Unlock()
+		if r.Method == http.MethodGet {
+			mux.Lock()
+			defer mux.Unlock()
+			result := []map[string]string{}
+			if !deleted {
+				result = append(result, map[string]string{"id": "a"})
+			}
+			json.NewEncoder(w).Encode(result)
+			return
+		}
+		if isHung {
+			<-r.Context().Done()
+			return
+		}
+		mux.Lock()
+		defer mux.Unlock()
+		deleted = true
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, func(value bool) {
+		mux.Lock()
+		defer mux.Unlock()
+		hung = value
+	}
+}
+
+func TestGetServiceTimeout(t *testing.T) {
+	conf := configuration.Config{ServiceTimeout: "1m", ServiceTimeoutOverride: map[string]string{"slow": "1h"}}
+	timeout, err := ctrl.GetServiceTimeout(conf, "slow")
+	if err != nil || timeout != time.Hour {
+		t.Error(timeout, err)
+	}
+	timeout, err = ctrl.GetServiceTimeout(conf, "other")
+	if err != nil || timeout != time.Minute {
+		t.Error(timeout, err)
+	}
+	timeout, err = ctrl.GetServiceTimeout(configuration.Config{}, "other")
+	if err != nil || timeout != 0 {
+		t.Error(timeout, err)
+	}
+	conf.ServiceTimeoutOverride["broken"] = "foo"
+	if err = ctrl.ValidateServiceTimeouts(conf); err == nil {
+		t.Error("expected error for invalid override")
+	}
+}
+
+func TestDeletionTimeouts(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	config, _, _ = startDeletionMocks(t, t.Context(), config)
+	config.ServiceTimeout = "1m"
+	config.ServiceTimeoutOverride = map[string]string{"hung": "200ms"}
+
+	prepare := func(t *testing.T) (conf configuration.Config, setHung func(bool)) {
+		conf = config
+		var url string
+		url, setHung = mockHungService(t)
+		conf.HttpCleaners = []configuration.HttpCleanerConfig{{
+			Name:      "hung",
+			ListUrl:   url + "/items",
+			DeleteUrl: url + "/items/{id}",
+		}}
+		return conf, setHung
+	}
+
+	t.Run("service timeout fails the step and is resumed", func(t *testing.T) {
+		conf, setHung := prepare(t)
+		jobs := store.NewMemory[ctrl.DeletionJob]()
+		start := time.Now()
+		err := ctrl.DeleteUser(t.Context(), "user1", conf, jobs, nil)
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatal(err)
+		}
+		if time.Since(start) > 10*time.Second {
+			t.Error("deletion was not aborted by the service timeout", time.Since(start))
+		}
+		job, _, err := jobs.Get("user1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if step := findStep(job, "hung"); job.State != ctrl.DeletionFailed || step.State != ctrl.DeletionFailed {
+			t.Errorf("%#v", job)
+		}
+		if step := findStep(job, "keycloak"); step.State != ctrl.DeletionPending {
+			t.Errorf("%#v", step)
+		}
+
+		setHung(false)
+		err = ctrl.DeleteUser(t.Context(), "user1", conf, jobs, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		job, _, err = jobs.Get("user1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if step := findStep(job, "hung"); job.State != ctrl.DeletionDone || step.State != ctrl.DeletionDone || step.Attempts != 2 {
+			t.Errorf("%#v", job)
+		}
+	})
+
+	t.Run("cancellation aborts the deletion", func(t *testing.T) {
+		conf, _ := prepare(t)
+		conf.ServiceTimeoutOverride = nil
+		jobs := store.NewMemory[ctrl.DeletionJob]()
+		ctx, cancel := context.WithCancel(t.Context())
+		time.AfterFunc(200*time.Millisecond, cancel)
+		err := ctrl.DeleteUser(ctx, "user1", conf, jobs, nil)
+		if !errors.Is(err, context.Canceled) {
+			t.Fatal(err)
+		}
+		job, _, err := jobs.Get("user1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if step := findStep(job, "hung"); job.State != ctrl.DeletionFailed || step.State != ctrl.DeletionFailed {
+			t.Errorf("%#v", job)
+		}
+	})
+}
+
+func TestDeletionStepStopsWithContext(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	config, _, _ = startDeletionMocks(t, t.Context(), config)
+	canceled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(canceled)
+	}))
+	t.Cleanup(server.Close)
+	config.DeviceRepositoryUrl = server.URL
+	config.ServiceTimeoutOverride = map[string]string{"device-repository": "200ms"}
+
+	jobs := store.NewMemory[ctrl.DeletionJob]()
+	err = ctrl.DeleteUser(t.Context(), "user1", config, jobs, nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal(err)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("device-repository request is still running after the step failed")
+	}
+	job, _, err := jobs.Get("user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if step := findStep(job, "device-repository"); step.State != ctrl.DeletionFailed {
+		t.Errorf("%#v", step)
+	}
+}
+
+func TestEnsureAccessIsShared(t *testing.T) {
+	mux := sync.Mutex{}
+	requests := 0
+	inFlight := 0
+	maxInFlight := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		requests++
+		count := requests
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mux.Unlock()
+		time.Sleep(100 * time.Millisecond)
+		mux.Lock()
+		inFlight--
+		mux.Unlock()
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "token-" + strconv.Itoa(count), "expires_in": 0})
+	}))
+	t.Cleanup(server.Close)
+	//the buffer also invalidates tokens cached by other tests
+	conf := configuration.Config{KeycloakUrl: server.URL, KeycloakRealm: "master", AuthExpirationTimeBuffer: math.Inf(1)}
+
+	t.Run("canceled caller does not fail the request", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(t.Context())
+		time.AfterFunc(20*time.Millisecond, cancel)
+		token, err := ctrl.EnsureAccess(ctx, conf)
+		if err != nil || token.Token != "Bearer token-1" {
+			t.Error(token.Token, err)
+		}
+		_, err = ctrl.EnsureAccess(ctx, conf)
+		if !errors.Is(err, context.Canceled) {
+			t.Error(err)
+		}
+	})
+
+	t.Run("concurrent callers", func(t *testing.T) {
+		wg := sync.WaitGroup{}
+		for range 5 {
+			wg.Go(func() {
+				token, err := ctrl.EnsureAccess(t.Context(), conf)
+				if err != nil || token.Token == "Bearer " {
+					t.Error(token.Token, err)
+				}
+			})
+		}
+		wg.Wait()
+		mux.Lock()
+		defer mux.Unlock()
+		if maxInFlight != 1 {
+			t.Error("concurrent token requests:", maxInFlight)
+		}
+	})
+}
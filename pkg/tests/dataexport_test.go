@@ -0,0 +1,144 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/ctrl"
+	"github.com/SENERGY-Platform/user-management/pkg/store"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDataExport(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	config, _, _ = startDeletionMocks(t, t.Context(), config)
+	dashboardUrl, _ := mockDashboards(t, map[string]map[string]interface{}{
+		"d1": {"id": "d1", "user_id": "user1", "name": "foo"},
+		"d2": {"id": "d2", "user_id": "user2", "name": "other"},
+	})
+	config.DashboardServiceUrl = dashboardUrl
+	config.PersistenceDir = t.TempDir()
+	config.DataExportExpiration = "1h"
+
+	exports := store.NewMemory[ctrl.DataExport]()
+	export := ctrl.NewDataExport("user1", "admin")
+	err = exports.Set(export.Id, export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ctrl.RunDataExport(t.Context(), export.Id, config, exports)
+	if err != nil {
+		t.Fatal(err)
+	}
+	export, _, err = exports.Get(export.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export.State != ctrl.DataExportDone || export.Expires == nil || export.Size == 0 {
+		t.Fatalf("%#v", export)
+	}
+	//the keycloak mock knows no user profiles
+	if len(export.Errors) != 1 {
+		t.Errorf("%#v", export.Errors)
+	}
+
+	archive, err := zip.OpenReader(ctrl.GetDataExportLocation(config, export.Id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	readJson := func(name string, value interface{}) {
+		t.Helper()
+		file, err := archive.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+		err = json.NewDecoder(file).Decode(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifest := ctrl.DataExportManifest{}
+	readJson("manifest.json", &manifest)
+	dashboards := []map[string]interface{}{}
+	readJson("dashboard/dashboards.json", &dashboards)
+	archive.Close()
+
+	if manifest.UserId != "user1" {
+		t.Errorf("%#v", manifest)
+	}
+	for _, service := range manifest.Services {
+		switch service.Service {
+		case "dashboard":
+			if service.Error != "" || len(service.Files) != 1 || service.Files[0] != "dashboard/dashboards.json" {
+				t.Errorf("%#v", service)
+			}
+		case "keycloak":
+			if service.Error == "" {
+				t.Errorf("%#v", service)
+			}
+		}
+	}
+	if len(dashboards) != 1 || dashboards[0]["id"] != "d1" {
+		t.Errorf("%#v", dashboards)
+	}
+
+	err = ctrl.RemoveExpiredDataExports(config, exports, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists, _ := exports.Get(export.Id); !exists {
+		t.Error("expected export to be kept until expiry")
+	}
+	err = ctrl.RemoveExpiredDataExports(config, exports, time.Now().Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, exists, _ := exports.Get(export.Id); exists {
+		t.Error("expected removed export")
+	}
+	if _, err = os.Stat(ctrl.GetDataExportLocation(config, export.Id)); !os.IsNotExist(err) {
+		t.Error("expected removed archive", err)
+	}
+
+	t.Run("invalid expiration fails the export", func(t *testing.T) {
+		conf := config
+		conf.DataExportExpiration = "foo"
+		export := ctrl.NewDataExport("user1", "admin")
+		err := exports.Set(export.Id, export)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = ctrl.RunDataExport(t.Context(), export.Id, conf, exports); err == nil {
+			t.Error("expected error")
+		}
+		export, _, err = exports.Get(export.Id)
+		if err != nil || export.State != ctrl.DataExportFailed || export.Finished == nil {
+			t.Errorf("%#v %v", export, err)
+		}
+		if _, err = os.Stat(ctrl.GetDataExportLocation(conf, export.Id)); !os.IsNotExist(err) {
+			t.Error("unexpected archive", err)
+		}
+	})
+}
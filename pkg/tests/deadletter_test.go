@@ -0,0 +1,91 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"errors"
+	"github.com/SENERGY-Platform/user-management/pkg/ctrl"
+	"github.com/SENERGY-Platform/user-management/pkg/kafka"
+	"github.com/SENERGY-Platform/user-management/pkg/store"
+	"testing"
+)
+
+func TestParseUserCommand(t *testing.T) {
+	for msg, poison := range map[string]bool{
+		`{"command": "DELETE", "id": "user1"}`:                                false,
+		`{"command": "TRANSFER", "id": "user1", "target_id": "user2"}`:        false,
+		`{"command": "TRANSFER", "id": "user1"}`:                              true,
+		`{"command": "FOO", "id": "user1"}`:                                   true,
+		`{"command": "DELETE"}`:                                               true,
+		`{"command": "DELETE", "id": "user1", "policies": {"flows": "keep"}}`: false,
+		`{"command": "DELETE", "id": "user1", "policies": {"flows": "foo"}}`:  true,
+		`{"command": "DELETE", "id": "user1", "policies": {"foo": "keep"}}`:   true,
+		`not json`: true,
+	} {
+		_, err := ctrl.ParseUserCommand([]byte(msg))
+		if errors.Is(err, kafka.ErrPoisonMessage) != poison {
+			t.Error(msg, err)
+		}
+	}
+}
+
+func TestReplayDeadLetters(t *testing.T) {
+	letters := store.NewMemory[ctrl.DeadLetter]()
+	for i, value := range []string{"not json", `{"command": "DELETE", "id": "user1"}`} {
+		letter := ctrl.NewDeadLetter(kafka.DeadLetter{
+			Topic:     "user",
+			Partition: 0,
+			Offset:    int64(i),
+			Key:       []byte("key"),
+			Value:     []byte(value),
+			Error:     errors.New("test"),
+			Attempts:  1,
+		})
+		err := letters.Set(letter.Id, letter)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	produced := []string{}
+	produce := func(key []byte, value []byte) error {
+		produced = append(produced, string(value))
+		return nil
+	}
+	replayed, err := ctrl.ReplayDeadLetters(letters, []string{"user:0:1"}, produce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replayed) != 1 || len(produced) != 1 || produced[0] != `{"command": "DELETE", "id": "user1"}` {
+		t.Error(replayed, produced)
+	}
+	list, err := letters.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Id != "user:0:0" || list[0].Error != "test" {
+		t.Errorf("%#v", list)
+	}
+
+	replayed, err = ctrl.ReplayDeadLetters(letters, nil, produce)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replayed) != 1 || len(produced) != 2 {
+		t.Error(replayed, produced)
+	}
+}
@@ -0,0 +1,163 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/ctrl"
+	"github.com/SENERGY-Platform/user-management/pkg/store"
+	"github.com/SENERGY-Platform/user-management/pkg/tests/mocks"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestDeletionJobResume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	config, calls, setDashboardFailure := startDeletionMocks(t, ctx, config)
+	location := filepath.Join(t.TempDir(), "deletion-jobs.json")
+
+	jobs, err := store.NewFile[ctrl.DeletionJob](location)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	setDashboardFailure(true)
+	err = ctrl.DeleteUser(t.Context(), "user1", config, jobs, nil)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	//simulate restart
+	jobs, err = store.NewFile[ctrl.DeletionJob](location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, exists, err := jobs.Get("user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exists || job.State != ctrl.DeletionFailed {
+		t.Fatalf("%#v", job)
+	}
+	if step := findStep(job, "device-repository"); step.State != ctrl.DeletionDone || step.Finished == nil {
+		t.Errorf("%#v", step)
+	}
+	if step := findStep(job, "dashboard"); step.State != ctrl.DeletionFailed || step.Attempts != 1 || step.LastError == "" {
+		t.Errorf("%#v", step)
+	}
+	if step := findStep(job, "keycloak"); step.State != ctrl.DeletionPending {
+		t.Errorf("%#v", step)
+	}
+
+	setDashboardFailure(false)
+	err = ctrl.DeleteUser(t.Context(), "user1", config, jobs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, _, err = jobs.Get("user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != ctrl.DeletionDone || job.Finished == nil {
+		t.Errorf("%#v", job)
+	}
+	if step := findStep(job, "dashboard"); step.State != ctrl.DeletionDone || step.Attempts != 2 || step.LastError != "" {
+		t.Errorf("%#v", step)
+	}
+	if step := findStep(job, "device-repository"); step.Attempts != 1 {
+		t.Errorf("%#v", step)
+	}
+	if c := calls(); c["DELETE /users/user1"] != 1 {
+		t.Errorf("%#v", c)
+	}
+}
+
+func findStep(job ctrl.DeletionJob, service string) ctrl.DeletionStep {
+	for _, step := range job.Steps {
+		if step.Service == service {
+			return step
+		}
+	}
+	return ctrl.DeletionStep{}
+}
+
+// startDeletionMocks replaces device-repository, dashboard and keycloak with mocks and disables all other services
+func startDeletionMocks(t *testing.T, ctx context.Context, origConfig configuration.Config) (config configuration.Config, calls func() map[string]int, setDashboardFailure func(bool)) {
+	config = origConfig
+	mux := sync.Mutex{}
+	counter := map[string]int{}
+	dashboardFailure := false
+	calls = func() map[string]int {
+		mux.Lock()
+		defer mux.Unlock()
+		result := map[string]int{}
+		for k, v := range counter {
+			result[k] = v
+		}
+		return result
+	}
+	setDashboardFailure = func(fail bool) {
+		mux.Lock()
+		defer mux.Unlock()
+		dashboardFailure = fail
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		counter[r.Method+" "+r.URL.Path]++
+		if r.URL.Path == "/dashboards" {
+			if dashboardFailure {
+				http.Error(w, "test failure", http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode([]ctrl.IdWrapper{})
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+
+	keycloakUrl, err := mocks.MockKeycloak(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.KeycloakUrl = keycloakUrl
+	config.DeviceRepositoryUrl = server.URL
+	config.DashboardServiceUrl = server.URL
+	config.WaitingRoomUrl = ""
+	config.ProcessSchedulerUrl = ""
+	config.ImportsDeploymentUrl = ""
+	config.BrokerExportsUrl = ""
+	config.DatabaseExportsUrl = ""
+	config.AnalyticsOperatorRepoUrl = ""
+	config.AnalyticsFlowRepoUrl = ""
+	config.AnalyticsFlowEngineUrl = ""
+	config.AnalyticsPipelineUrl = ""
+	config.NotifierUrl = ""
+	return config, calls, setDashboardFailure
+}
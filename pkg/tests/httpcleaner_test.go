@@ -0,0 +1,171 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"encoding/json"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/ctrl"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// mockPaginatedService serves {"result": {"items": [...]}} with limit/offset and deletes via DELETE /items/{id} or DELETE /items with a json body
+func mockPaginatedService(t *testing.T, items []map[string]interface{}) (url string, getItems func() []map[string]interface{}) {
+	mux := sync.Mutex{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		remove := func(id string) {
+			items = slices.DeleteFunc(items, func(item map[string]interface{}) bool {
+				return ctrlId(item) == id
+			})
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/items":
+			limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			page := items[min(offset, len(items)):min(offset+limit, len(items))]
+			json.NewEncoder(w).Encode(map[string]interface{}{"result": map[string]interface{}{"items": page}})
+		case r.Method == http.MethodDelete && r.URL.Path == "/items":
+			ids := []string{}
+			json.NewDecoder(r.Body).Decode(&ids)
+			for _, id := range ids {
+				remove(id)
+			}
+		case r.Method == http.MethodDelete:
+			remove(r.URL.Path[len("/items/"):])
+		default:
+			http.Error(w, "unknown", http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, func() []map[string]interface{} {
+		mux.Lock()
+		defer mux.Unlock()
+		return slices.Clone(items)
+	}
+}
+
+func ctrlId(item map[string]interface{}) string {
+	if id, ok := item["_id"].(string); ok {
+		return id
+	}
+	return strconv.Itoa(int(item["_id"].(float64)))
+}
+
+func testItems() []map[string]interface{} {
+	return []map[string]interface{}{
+		{"_id": "a", "owner": "user1"},
+		{"_id": "b", "owner": "user2"},
+		{"_id": "c", "owner": "user1"},
+		{"_id": float64(4), "owner": "user1"},
+		{"_id": "e", "owner": "user1"},
+	}
+}
+
+func TestHttpCleaner(t *testing.T) {
+	token, err := ctrl.CreateToken("test", "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mode := range []string{ctrl.HttpCleanerDeleteSingle, ctrl.HttpCleanerDeleteBatch} {
+		t.Run(mode, func(t *testing.T) {
+			url, getItems := mockPaginatedService(t, testItems())
+			cleaner := ctrl.HttpCleaner{Config: configuration.HttpCleanerConfig{
+				Name:       "test-http",
+				ListUrl:    url + "/items",
+				Pagination: ctrl.HttpCleanerPaginationLimitOffset,
+				PageSize:   2,
+				ListPath:   "result.items",
+				IdField:    "_id",
+				OwnerField: "owner",
+				DeleteUrl:  url + "/items",
+				DeleteMode: mode,
+			}}
+			if mode == ctrl.HttpCleanerDeleteSingle {
+				cleaner.Config.DeleteUrl = url + "/items/{id}"
+			}
+			err = ctrl.ValidateHttpCleaners(configuration.Config{HttpCleaners: []configuration.HttpCleanerConfig{cleaner.Config}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resources, err := cleaner.List(t.Context(), token, configuration.Config{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(resources) != 1 || !reflect.DeepEqual(resources[0].Ids, []string{"a", "c", "4", "e"}) || len(resources[0].Kept) != 1 {
+				t.Fatalf("%#v", resources)
+			}
+
+			deleted, err := cleaner.Delete(t.Context(), token, configuration.Config{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(deleted) != 4 {
+				t.Error(deleted)
+			}
+			remaining, err := cleaner.Verify(t.Context(), token, configuration.Config{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(remaining) != 0 {
+				t.Error(remaining)
+			}
+			if items := getItems(); len(items) != 1 || items[0]["_id"] != "b" {
+				t.Error(items)
+			}
+		})
+	}
+}
+
+func TestHttpCleanerConfig(t *testing.T) {
+	t.Setenv("HTTP_CLEANERS", `[{"Name": "foo", "ListUrl": "http://foo/items", "DeleteUrl": "http://foo/items/{id}"}]`)
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	if len(config.HttpCleaners) != 1 || config.HttpCleaners[0].Name != "foo" {
+		t.Fatalf("%#v", config.HttpCleaners)
+	}
+	err = ctrl.ValidateHttpCleaners(config)
+	if err != nil {
+		t.Error(err)
+	}
+	if names := cleanerNames(ctrl.GetCleaners(config)); names[len(names)-2] != "foo" || names[len(names)-1] != "keycloak" {
+		t.Error(names)
+	}
+
+	config.HttpCleaners = append(config.HttpCleaners, configuration.HttpCleanerConfig{Name: "dashboard", ListUrl: "http://foo", DeleteUrl: "http://foo/{id}"})
+	err = ctrl.ValidateHttpCleaners(config)
+	if err == nil {
+		t.Error("expected error for duplicate name")
+	}
+}
+
+func cleanerNames(cleaners []ctrl.ServiceCleaner) (names []string) {
+	for _, cleaner := range cleaners {
+		names = append(names, cleaner.Name())
+	}
+	return names
+}
@@ -0,0 +1,259 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"encoding/json"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/ctrl"
+	"github.com/SENERGY-Platform/user-management/pkg/store"
+	"github.com/SENERGY-Platform/user-management/pkg/tests/mocks"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type mockActivity struct {
+	Created    time.Time
+	LastLogin  time.Time
+	LastAccess time.Time //of an active session
+	Groups     []string
+	Roles      []string
+}
+
+// mockKeycloakActivity serves users with creation time, login events, sessions, groups and realm roles and the event settings of the realm;
+// token requests are forwarded to mocks.MockKeycloak
+func mockKeycloakActivity(t *testing.T, users map[string]*mockActivity, eventsConfig map[string]interface{}) (keycloakUrl string, mux *sync.Mutex) {
+	mockUrl, err := mocks.MockKeycloak(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := url.Parse(mockUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	mux = &sync.Mutex{}
+	prefix := "/auth/admin/realms/master/"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, prefix)
+		if path == r.URL.Path {
+			proxy.ServeHTTP(w, r)
+			return
+		}
+		mux.Lock()
+		defer mux.Unlock()
+		parts := strings.SplitN(path, "/", 3)
+		result := []map[string]interface{}{}
+		switch {
+		case path == "events/config":
+			json.NewEncoder(w).Encode(eventsConfig)
+			return
+		case path == "users":
+			if r.URL.Query().Get("first") == "0" {
+				for id := range users {
+					result = append(result, map[string]interface{}{"id": id, "username": id})
+				}
+			}
+		case path == "events":
+			if user := users[r.URL.Query().Get("user")]; user != nil && !user.LastLogin.IsZero() && r.URL.Query().Get("type") == "LOGIN" {
+				result = append(result, map[string]interface{}{"time": user.LastLogin.UnixMilli(), "type": "LOGIN"})
+			}
+		case len(parts) >= 2 && parts[0] == "users" && users[parts[1]] != nil:
+			user := users[parts[1]]
+			subPath := ""
+			if len(parts) == 3 {
+				subPath = parts[2]
+			}
+			switch subPath {
+			case "":
+				json.NewEncoder(w).Encode(map[string]interface{}{"id": parts[1], "username": parts[1], "createdTimestamp": user.Created.UnixMilli()})
+				return
+			case "sessions":
+				if !user.LastAccess.IsZero() {
+					result = append(result, map[string]interface{}{"lastAccess": user.LastAccess.UnixMilli()})
+				}
+			case "groups":
+				if r.URL.Query().Get("first") == "0" {
+					for _, group := range user.Groups {
+						result = append(result, map[string]interface{}{"id": group, "name": group})
+					}
+				}
+			case "role-mappings/realm/composite":
+				for _, role := range user.Roles {
+					result = append(result, map[string]interface{}{"name": role})
+				}
+			default:
+				http.Error(w, "not found", http.StatusNotFound)
+				return
+			}
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, mux
+}
+
+func TestInactiveUsers(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	now := time.Now()
+	longAgo := now.Add(-2 * 365 * 24 * time.Hour)
+	users := map[string]*mockActivity{
+		"inactive":     {Created: longAgo},
+		"login":        {Created: longAgo, LastLogin: now.Add(-24 * time.Hour)},
+		"session":      {Created: longAgo, LastAccess: now.Add(-time.Hour)},
+		"new":          {Created: now.Add(-24 * time.Hour)},
+		"tester":       {Created: longAgo, Groups: []string{"testers"}},
+		"service-user": {Created: longAgo, Roles: []string{"offline_access", "service"}},
+	}
+	eventsConfig := map[string]interface{}{"eventsEnabled": true, "eventsExpiration": 0, "enabledEventTypes": []string{"LOGIN", "LOGOUT"}}
+	keycloakUrl, keycloakMux := mockKeycloakActivity(t, users, eventsConfig)
+	config.KeycloakUrl = keycloakUrl
+
+	notifierMux := sync.Mutex{}
+	notifications := []map[string]interface{}{}
+	notifier := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		notifierMux.Lock()
+		defer notifierMux.Unlock()
+		if r.Method != http.MethodPost || r.URL.Path != "/notifications" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		notification := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&notification)
+		notifications = append(notifications, notification)
+	}))
+	t.Cleanup(notifier.Close)
+	config.NotifierUrl = notifier.URL
+	config.InactiveUserPeriod = "8760h"
+	config.InactiveUserDeletionDelay = "720h"
+	config.InactiveUserNotify = true
+	config.InactiveUserExcludedGroups = []string{"testers"}
+	config.InactiveUserExcludedRoles = []string{"service"}
+
+	inactive := store.NewMemory[ctrl.InactiveUser]()
+	requested := []string{}
+	requestDeletion := func(userId string) error {
+		requested = append(requested, userId)
+		return nil
+	}
+	flaggedIds := func(list []ctrl.InactiveUser) (result []string) {
+		for _, user := range list {
+			result = append(result, user.UserId)
+		}
+		return result
+	}
+
+	config.InactiveUserDryRun = true
+	report, err := ctrl.ReapInactiveUsers(t.Context(), config, inactive, now, requestDeletion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := flaggedIds(report.Flagged); !report.DryRun || !reflect.DeepEqual(ids, []string{"inactive"}) || len(report.Errors) != 0 {
+		t.Errorf("%#v", report)
+	}
+	if list, _ := inactive.List(); len(list) != 0 || len(notifications) != 0 {
+		t.Error("dry run changed state", list, notifications)
+	}
+
+	config.InactiveUserDryRun = false
+	report, err = ctrl.ReapInactiveUsers(t.Context(), config, inactive, now, requestDeletion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := flaggedIds(report.Flagged); !reflect.DeepEqual(ids, []string{"inactive"}) || len(report.Deleted) != 0 {
+		t.Errorf("%#v", report)
+	}
+	flag, flagged, _ := inactive.Get("inactive")
+	if !flagged || !flag.Notified || !flag.DeleteAfter.Equal(now.Add(720*time.Hour)) {
+		t.Errorf("%#v", flag)
+	}
+	notifierMux.Lock()
+	if len(notifications) != 1 || notifications[0]["userId"] != "inactive" {
+		t.Errorf("%#v", notifications)
+	}
+	notifierMux.Unlock()
+
+	later := now.Add(31 * 24 * time.Hour)
+	for range 2 {
+		report, err = ctrl.ReapInactiveUsers(t.Context(), config, inactive, later, requestDeletion)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(requested, []string{"inactive"}) || len(report.Flagged) != 0 {
+		t.Errorf("%#v %#v", requested, report)
+	}
+
+	keycloakMux.Lock()
+	users["inactive"].LastLogin = later
+	keycloakMux.Unlock()
+	report, err = ctrl.ReapInactiveUsers(t.Context(), config, inactive, later, requestDeletion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(report.Unflagged, []string{"inactive"}) {
+		t.Errorf("%#v", report)
+	}
+	if _, flagged, _ = inactive.Get("inactive"); flagged {
+		t.Error("expected unflagged user")
+	}
+
+	t.Run("realm event settings", func(t *testing.T) {
+		for _, c := range []map[string]interface{}{
+			{"eventsEnabled": false},
+			{"eventsEnabled": true, "enabledEventTypes": []string{"LOGOUT"}},
+			{"eventsEnabled": true, "eventsExpiration": 30 * 24 * 60 * 60},
+		} {
+			keycloakMux.Lock()
+			clear(eventsConfig)
+			maps.Copy(eventsConfig, c)
+			keycloakMux.Unlock()
+
+			inactive := store.NewMemory[ctrl.InactiveUser]()
+			requested = []string{}
+			conf := config
+			conf.InactiveUserDryRun = false
+			_, err = ctrl.ReapInactiveUsers(t.Context(), conf, inactive, later, requestDeletion)
+			if err == nil {
+				t.Error("expected error", c)
+			}
+			if list, _ := inactive.List(); len(list) != 0 || len(requested) != 0 {
+				t.Error("unexpected changes", c, list, requested)
+			}
+
+			conf.InactiveUserDryRun = true
+			report, err = ctrl.ReapInactiveUsers(t.Context(), conf, inactive, later, requestDeletion)
+			if err != nil || len(report.Errors) != 1 {
+				t.Errorf("%v %v %#v", c, err, report)
+			}
+		}
+	})
+}
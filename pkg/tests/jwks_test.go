@@ -0,0 +1,297 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/SENERGY-Platform/user-management/pkg/api"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/ctrl"
+	"github.com/golang-jwt/jwt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testSigningKey struct {
+	kid string
+	key *rsa.PrivateKey
+}
+
+// mockJwks serves the public keys of the currently published signing keys and counts the requests
+func mockJwks(t *testing.T) (url string, publish func(keys ...testSigningKey), requests func() int) {
+	mux := sync.Mutex{}
+	published := []testSigningKey{}
+	count := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		count++
+		keys := []map[string]string{}
+		for _, key := range published {
+			keys = append(keys, map[string]string{
+				"kid": key.kid,
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.key.E)).Bytes()),
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, func(keys ...testSigningKey) {
+			mux.Lock()
+			defer mux.Unlock()
+			published = keys
+		}, func() int {
+			mux.Lock()
+			defer mux.Unlock()
+			return count
+		}
+}
+
+func newTestSigningKey(t *testing.T, kid string) testSigningKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testSigningKey{kid: kid, key: key}
+}
+
+func signTestToken(t *testing.T, key testSigningKey, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = key.kid
+	signed, err := token.SignedString(key.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return "Bearer " + signed
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	jwksUrl, publish, requests := mockJwks(t)
+	config.AuthMode = api.AuthModeJwks
+	config.AuthJwksUrl = jwksUrl
+	config.AuthJwksRefreshInterval = "1h"
+	config.AuthIssuer = "http://keycloak/auth/realms/test"
+	config.AuthAudiences = []string{"frontend"}
+
+	key1 := newTestSigningKey(t, "key1")
+	key2 := newTestSigningKey(t, "key2")
+	publish(key1)
+
+	claims := func(modify func(claims jwt.MapClaims)) jwt.MapClaims {
+		result := jwt.MapClaims{
+			"sub":          "user1",
+			"iss":          config.AuthIssuer,
+			"aud":          "account",
+			"azp":          "frontend",
+			"exp":          time.Now().Add(time.Minute).Unix(),
+			"realm_access": map[string][]string{"roles": {"admin"}},
+		}
+		if modify != nil {
+			modify(result)
+		}
+		return result
+	}
+
+	call := func(t *testing.T, conf configuration.Config, auth string) (status int, token api.Token) {
+		middleware, err := api.NewAuthMiddleware(conf, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, err = api.GetParsedToken(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			}
+		}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/user", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		rec := httptest.NewRecorder()
+		middleware.ServeHTTP(rec, req)
+		return rec.Code, token
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		status, token := call(t, config, signTestToken(t, key1, claims(nil)))
+		if status != http.StatusOK || token.GetUserId() != "user1" || !token.IsAdmin() {
+			t.Error(status, token)
+		}
+		status, _ = call(t, config, signTestToken(t, key1, claims(func(claims jwt.MapClaims) {
+			delete(claims, "azp")
+			claims["aud"] = []string{"account", "frontend"}
+		})))
+		if status != http.StatusOK {
+			t.Error(status)
+		}
+		//within the leeway of the shipped config
+		status, _ = call(t, config, signTestToken(t, key1, claims(func(claims jwt.MapClaims) {
+			claims["exp"] = time.Now().Add(-10 * time.Second).Unix()
+			claims["nbf"] = time.Now().Add(10 * time.Second).Unix()
+		})))
+		if status != http.StatusOK {
+			t.Error(status)
+		}
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		forged, err := ctrl.CreateTokenWithRoles(config.AuthIssuer, "user1", []string{"admin"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, auth := range map[string]string{
+			"unsigned":    forged.Token,
+			"none":        "Bearer " + mustSignNone(t, claims(nil)),
+			"foreign key": signTestToken(t, testSigningKey{kid: "key1", key: newTestSigningKey(t, "key1").key}, claims(nil)),
+			"expired": signTestToken(t, key1, claims(func(claims jwt.MapClaims) {
+				claims["exp"] = time.Now().Add(-time.Minute).Unix()
+			})),
+			"not valid yet": signTestToken(t, key1, claims(func(claims jwt.MapClaims) {
+				claims["nbf"] = time.Now().Add(time.Minute).Unix()
+			})),
+			"issuer": signTestToken(t, key1, claims(func(claims jwt.MapClaims) {
+				claims["iss"] = "http://attacker/auth/realms/test"
+			})),
+			"audience": signTestToken(t, key1, claims(func(claims jwt.MapClaims) {
+				claims["azp"] = "other"
+			})),
+		} {
+			if status, _ := call(t, config, auth); status != http.StatusUnauthorized {
+				t.Error(name, status)
+			}
+		}
+	})
+
+	t.Run("key rotation", func(t *testing.T) {
+		before := requests()
+		publish(key2)
+		conf := config
+		conf.AuthJwksRefreshInterval = "1ns"
+		middleware, err := api.NewAuthMiddleware(conf, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range []testSigningKey{key2, key2, key1} {
+			req := httptest.NewRequest(http.MethodGet, "/user", nil)
+			req.Header.Set("Authorization", signTestToken(t, key, claims(nil)))
+			rec := httptest.NewRecorder()
+			middleware.ServeHTTP(rec, req)
+			if expected := map[string]int{"key1": http.StatusUnauthorized, "key2": http.StatusOK}[key.kid]; rec.Code != expected {
+				t.Error(key.kid, rec.Code)
+			}
+		}
+		//the first key2 token loads the keys; key2 is cached afterward and key1 triggers a refresh
+		if requests()-before != 2 {
+			t.Error(requests() - before)
+		}
+	})
+
+	t.Run("refresh interval limits unknown key ids", func(t *testing.T) {
+		publish(key1)
+		for _, refreshInterval := range []string{"1h", "", "0s"} {
+			conf := config
+			conf.AuthJwksRefreshInterval = refreshInterval
+			middleware, err := api.NewAuthMiddleware(conf, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			before := requests()
+			for _, kid := range []string{"key1", "unknown1", "unknown2"} {
+				req := httptest.NewRequest(http.MethodGet, "/user", nil)
+				req.Header.Set("Authorization", signTestToken(t, testSigningKey{kid: kid, key: key1.key}, claims(nil)))
+				middleware.ServeHTTP(httptest.NewRecorder(), req)
+			}
+			if requests()-before != 1 {
+				t.Error(refreshInterval, requests()-before)
+			}
+		}
+	})
+
+	t.Run("failing refresh is limited", func(t *testing.T) {
+		failures := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			failures++
+			http.Error(w, "test error", http.StatusInternalServerError)
+		}))
+		t.Cleanup(server.Close)
+		conf := config
+		conf.AuthJwksUrl = server.URL
+		middleware, err := api.NewAuthMiddleware(conf, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for range 3 {
+			req := httptest.NewRequest(http.MethodGet, "/user", nil)
+			req.Header.Set("Authorization", signTestToken(t, key1, claims(nil)))
+			rec := httptest.NewRecorder()
+			middleware.ServeHTTP(rec, req)
+			if rec.Code != http.StatusUnauthorized {
+				t.Error(rec.Code)
+			}
+		}
+		if failures != 1 {
+			t.Error(failures)
+		}
+	})
+
+	t.Run("without token", func(t *testing.T) {
+		if status, _ := call(t, config, ""); status != http.StatusBadRequest {
+			t.Error(status)
+		}
+	})
+
+	t.Run("gateway mode", func(t *testing.T) {
+		conf := config
+		conf.AuthMode = api.AuthModeGateway
+		forged, err := ctrl.CreateToken(config.AuthIssuer, "user1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status, token := call(t, conf, forged.Token); status != http.StatusOK || token.GetUserId() != "user1" {
+			t.Error(status, token)
+		}
+	})
+
+	t.Run("invalid mode", func(t *testing.T) {
+		conf := config
+		conf.AuthMode = "foo"
+		if _, err := api.NewAuthMiddleware(conf, nil); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
+
+func mustSignNone(t *testing.T, claims jwt.MapClaims) string {
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"errors"
+	"github.com/SENERGY-Platform/user-management/pkg/ctrl"
+	"github.com/SENERGY-Platform/user-management/pkg/store"
+	"path/filepath"
+	"testing"
+)
+
+func TestUserCommandLedger(t *testing.T) {
+	location := filepath.Join(t.TempDir(), "user-command-ledger.json")
+	ledger, err := store.NewFile[ctrl.LedgerEntry](location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs := 0
+	fail := false
+	run := func() error {
+		runs++
+		if fail {
+			return errors.New("test failure")
+		}
+		return nil
+	}
+	command := ctrl.UserCommandMsg{Command: ctrl.CommandDelete, Id: "user1"}
+
+	fail = true
+	skipped, err := ctrl.RunWithLedger(ledger, command, run)
+	if err == nil || skipped {
+		t.Error(skipped, err)
+	}
+	if _, exists, _ := ledger.Get("DELETE_user1"); exists {
+		t.Error("failed command recorded as completed")
+	}
+
+	fail = false
+	skipped, err = ctrl.RunWithLedger(ledger, command, run)
+	if err != nil || skipped {
+		t.Error(skipped, err)
+	}
+
+	//simulate restart and replay of the topic
+	ledger, err = store.NewFile[ctrl.LedgerEntry](location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	skipped, err = ctrl.RunWithLedger(ledger, command, run)
+	if err != nil || !skipped {
+		t.Error(skipped, err)
+	}
+	if runs != 2 {
+		t.Error(runs)
+	}
+
+	//other commands of the same user have their own key
+	skipped, err = ctrl.RunWithLedger(ledger, ctrl.UserCommandMsg{Command: ctrl.CommandTransfer, Id: "user1", TargetId: "user2"}, run)
+	if err != nil || skipped {
+		t.Error(skipped, err)
+	}
+
+	command.Force = true
+	skipped, err = ctrl.RunWithLedger(ledger, command, run)
+	if err != nil || skipped || runs != 4 {
+		t.Error(skipped, err, runs)
+	}
+	entry, exists, err := ledger.Get("DELETE_user1")
+	if err != nil || !exists || entry.UserId != "user1" || entry.Completed.IsZero() {
+		t.Errorf("%#v %v", entry, err)
+	}
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/ctrl"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// mockOwners answers /owners with the owners, if the request uses the admin token of the orphan scanner
+func mockOwners(t *testing.T, owners []string) (url string) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/owners" || r.Header.Get("X-UserId") != ctrl.OrphanScannerId {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(owners)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestScanOrphans(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	config.KeycloakUrl = mockKeycloakDirectory(t, []ctrl.User{{Id: "user1", Name: "user1"}, {Id: "user2", Name: "user2"}}, nil)
+	config.HttpCleaners = []configuration.HttpCleanerConfig{
+		{Name: "service-a", ListUrl: "http://service-a/items", DeleteUrl: "http://service-a/items/{id}", OwnersUrl: mockOwners(t, []string{"user1", "ghost1", "ghost2"}) + "/owners"},
+		{Name: "service-b", ListUrl: "http://service-b/items", DeleteUrl: "http://service-b/items/{id}", OwnersUrl: mockOwners(t, []string{"user2", "ghost1"}) + "/owners"},
+		{Name: "service-c", ListUrl: "http://service-c/items", DeleteUrl: "http://service-c/items/{id}", OwnersUrl: mockOwners(t, nil) + "/unknown"},
+		{Name: "service-d", ListUrl: "http://service-d/items", DeleteUrl: "http://service-d/items/{id}"},
+	}
+
+	t.Run("report", func(t *testing.T) {
+		report, err := ctrl.ScanOrphans(t.Context(), config, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.Enqueue || len(report.Enqueued) != 0 || len(report.Services) != 3 {
+			t.Errorf("%#v", report)
+		}
+		slices.Sort(report.Orphans)
+		if !slices.Equal(report.Orphans, []string{"ghost1", "ghost2"}) {
+			t.Errorf("%#v", report.Orphans)
+		}
+		for _, service := range report.Services {
+			switch service.Service {
+			case "service-a":
+				if service.Owners != 3 || len(service.Orphans) != 2 || service.Error != "" {
+					t.Errorf("%#v", service)
+				}
+			case "service-b":
+				if service.Owners != 2 || !slices.Equal(service.Orphans, []string{"ghost1"}) || service.Error != "" {
+					t.Errorf("%#v", service)
+				}
+			case "service-c":
+				if service.Error == "" {
+					t.Errorf("%#v", service)
+				}
+			default:
+				t.Errorf("unexpected service %#v", service)
+			}
+		}
+	})
+
+	t.Run("enqueue", func(t *testing.T) {
+		enqueued := []string{}
+		report, err := ctrl.ScanOrphans(t.Context(), config, func(userId string) error {
+			if userId == "ghost2" {
+				return errors.New("test error")
+			}
+			enqueued = append(enqueued, userId)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !report.Enqueue || !slices.Equal(report.Enqueued, []string{"ghost1"}) || !slices.Equal(enqueued, []string{"ghost1"}) || len(report.Errors) != 1 {
+			t.Errorf("%#v", report)
+		}
+	})
+
+	t.Run("failing existence check", func(t *testing.T) {
+		target, err := url.Parse(config.KeycloakUrl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		proxy := httputil.NewSingleHostReverseProxy(target)
+		keycloak := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/users/user2") || strings.HasSuffix(r.URL.Path, "/users/ghost2") {
+				http.Error(w, "test error", http.StatusInternalServerError)
+				return
+			}
+			proxy.ServeHTTP(w, r)
+		}))
+		t.Cleanup(keycloak.Close)
+		conf := config
+		conf.KeycloakUrl = keycloak.URL
+
+		enqueued := []string{}
+		report, err := ctrl.ScanOrphans(t.Context(), conf, func(userId string) error {
+			enqueued = append(enqueued, userId)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(report.Orphans, []string{"ghost1"}) || !slices.Equal(enqueued, []string{"ghost1"}) || len(report.Errors) != 2 {
+			t.Errorf("%#v", report)
+		}
+		for _, e := range report.Errors {
+			if !strings.Contains(e, "user2") && !strings.Contains(e, "ghost2") {
+				t.Error(e)
+			}
+		}
+	})
+}
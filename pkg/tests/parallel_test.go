@@ -0,0 +1,114 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"encoding/json"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/ctrl"
+	"github.com/SENERGY-Platform/user-management/pkg/store"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockSlowService lists 10 items and answers every delete after a delay, while tracking the max number of concurrent requests
+func mockSlowService(t *testing.T, fail bool) (url string, maxConcurrent func() int) {
+	mux := sync.Mutex{}
+	current := 0
+	maxCurrent := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			items := []map[string]string{}
+			for i := 0; i < 10; i++ {
+				items = append(items, map[string]string{"id": strconv.Itoa(i)})
+			}
+			json.NewEncoder(w).Encode(items)
+			return
+		}
+		mux.Lock()
+		current++
+		maxCurrent = max(maxCurrent, current)
+		mux.Unlock()
+		time.Sleep(50 * time.Millisecond)
+		mux.Lock()
+		current--
+		mux.Unlock()
+		if fail && strings.HasSuffix(r.URL.Path, "/3") {
+			http.Error(w, "test failure", http.StatusInternalServerError)
+			return
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, func() int {
+		mux.Lock()
+		defer mux.Unlock()
+		return maxCurrent
+	}
+}
+
+func TestParallelDeletion(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	config, _, _ = startDeletionMocks(t, t.Context(), config)
+	config.DeletionParallelServices = 3
+	config.DeletionServiceWorkers = 2
+	config.DeletionServiceWorkerOverride = map[string]int{"slow-b": 5}
+	config.DeletionVerify = false //the slow services keep listing deleted items
+
+	urlA, maxA := mockSlowService(t, false)
+	urlB, maxB := mockSlowService(t, false)
+	urlC, _ := mockSlowService(t, true)
+	for name, url := range map[string]string{"slow-a": urlA, "slow-b": urlB, "slow-c": urlC} {
+		config.HttpCleaners = append(config.HttpCleaners, configuration.HttpCleanerConfig{
+			Name:      name,
+			ListUrl:   url + "/items",
+			DeleteUrl: url + "/items/{id}",
+		})
+	}
+
+	jobs := store.NewMemory[ctrl.DeletionJob]()
+	err = ctrl.DeleteUser(t.Context(), "user1", config, jobs, nil)
+	if err == nil || !strings.Contains(err.Error(), "slow-c") {
+		t.Fatal("expected error of slow-c", err)
+	}
+	if maxA() != 2 || maxB() != 5 {
+		t.Error("unexpected concurrency", maxA(), maxB())
+	}
+	job, _, err := jobs.Get("user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != ctrl.DeletionFailed {
+		t.Errorf("%#v", job)
+	}
+	if step := findStep(job, "slow-a"); step.State != ctrl.DeletionDone || step.Deleted != 10 {
+		t.Errorf("%#v", step)
+	}
+	if step := findStep(job, "slow-c"); step.State != ctrl.DeletionFailed || step.Deleted != 9 {
+		t.Errorf("%#v", step)
+	}
+	if step := findStep(job, "keycloak"); step.State != ctrl.DeletionPending {
+		t.Errorf("%#v", step)
+	}
+}
@@ -0,0 +1,285 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"encoding/json"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/ctrl"
+	"github.com/SENERGY-Platform/user-management/pkg/store"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// mockExportDatabases lists own and public databases, without export instances, and stores the X-UserId of the creating request as UserId
+func mockExportDatabases(t *testing.T, databases map[string]ctrl.ExportDatabase) (url string, get func() map[string]ctrl.ExportDatabase) {
+	mux := sync.Mutex{}
+	counter := len(databases)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		userId := r.Header.Get("X-UserId")
+		id := strings.TrimPrefix(r.URL.Path, "/databases/")
+		database, exists := databases[id]
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/instance":
+			json.NewEncoder(w).Encode(ctrl.ExportListIdWrapper{Instances: []ctrl.ExportIdWrapper{}})
+		case r.Method == http.MethodGet && r.URL.Path == "/databases":
+			result := []ctrl.ExportDatabase{}
+			for _, element := range databases {
+				if element.UserId == userId || element.Public {
+					result = append(result, element)
+				}
+			}
+			sort.Slice(result, func(i, j int) bool {
+				return result[i].ID < result[j].ID
+			})
+			offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+			json.NewEncoder(w).Encode(result[min(offset, len(result)):])
+		case r.Method == http.MethodGet && exists && (database.UserId == userId || database.Public):
+			json.NewEncoder(w).Encode(database)
+		case r.Method == http.MethodPost && r.URL.Path == "/databases":
+			database = ctrl.ExportDatabase{}
+			json.NewDecoder(r.Body).Decode(&database)
+			if database.ID != "" || database.UserId != "" {
+				http.Error(w, "unexpected id or owner", http.StatusBadRequest)
+				return
+			}
+			counter++
+			database.ID = "db" + strconv.Itoa(counter)
+			database.UserId = userId
+			databases[database.ID] = database
+			json.NewEncoder(w).Encode(database)
+		case r.Method == http.MethodDelete && exists && database.UserId == userId:
+			delete(databases, id)
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, func() map[string]ctrl.ExportDatabase {
+		mux.Lock()
+		defer mux.Unlock()
+		result := map[string]ctrl.ExportDatabase{}
+		for k, v := range databases {
+			result[k] = v
+		}
+		return result
+	}
+}
+
+func TestParseResourcePolicy(t *testing.T) {
+	for value, expected := range map[string]ctrl.ResourcePolicy{
+		"delete":            {Mode: ctrl.PolicyDelete},
+		"keep":              {Mode: ctrl.PolicyKeep},
+		"anonymize":         {Mode: ctrl.PolicyAnonymize},
+		"transfer-to:user2": {Mode: ctrl.PolicyTransferTo, Target: "user2"},
+	} {
+		policy, err := ctrl.ParseResourcePolicy(value)
+		if err != nil || policy != expected || policy.String() != value {
+			t.Error(value, policy, err)
+		}
+	}
+	for _, value := range []string{"", "foo", "transfer-to", "transfer-to:", "keep:user2"} {
+		_, err := ctrl.ParseResourcePolicy(value)
+		if err == nil {
+			t.Error("expected error for", value)
+		}
+	}
+	if err := ctrl.ValidateResourcePolicies(map[string]string{"dashboards": "keep"}); err == nil {
+		t.Error("expected error for unknown resource type")
+	}
+	if err := ctrl.ValidateResourcePolicies(map[string]string{ctrl.PolicyResourceFlows: "transfer-to:user2"}); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestResourcePolicies(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	config.ResourcePolicies = map[string]string{}
+	config.ResourcePolicyAnonymousUserId = "anonymous"
+
+	initDatabases := func() map[string]ctrl.ExportDatabase {
+		return map[string]ctrl.ExportDatabase{
+			"private1": {ID: "private1", Name: "private1", UserId: "user1"},
+			"public1":  {ID: "public1", Name: "public1", UserId: "user1", Public: true},
+			"public3":  {ID: "public3", Name: "public3", UserId: "user3", Public: true},
+		}
+	}
+	user1, err := ctrl.CreateToken("users-service", "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("defaults", func(t *testing.T) {
+		conf := config
+		conf.RemoveExportDatabaseMetadataOnUserDelete = true
+		conf.DatabaseExportsUrl, _ = mockExportDatabases(t, initDatabases())
+		resources, err := ctrl.ExportDatabasesCleaner{}.List(t.Context(), user1, conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(resources) != 1 || len(resources[0].Ids) != 1 || resources[0].Ids[0] != "private1" || len(resources[0].Kept) != 2 {
+			t.Errorf("%#v", resources)
+		}
+		deleted, transferred, decisions, err := ctrl.ExportDatabasesCleaner{}.DeleteWithPolicies(t.Context(), user1, nil, conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deleted) != 1 || deleted[0] != "private1" || len(transferred) != 0 {
+			t.Error(deleted, transferred)
+		}
+		expected := []ctrl.PolicyDecision{
+			{Resource: ctrl.PolicyResourceExportDatabases, Id: "private1", Policy: ctrl.PolicyDelete},
+			{Resource: ctrl.PolicyResourcePublicExportDatabases, Id: "public1", Policy: ctrl.PolicyKeep},
+		}
+		if !equalDecisions(decisions, expected) {
+			t.Errorf("%#v", decisions)
+		}
+	})
+
+	t.Run("private databases kept without metadata removal", func(t *testing.T) {
+		conf := config
+		conf.RemoveExportDatabaseMetadataOnUserDelete = false
+		var get func() map[string]ctrl.ExportDatabase
+		conf.DatabaseExportsUrl, get = mockExportDatabases(t, initDatabases())
+		deleted, _, _, err := ctrl.ExportDatabasesCleaner{}.DeleteWithPolicies(t.Context(), user1, nil, conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deleted) != 0 || len(get()) != 3 {
+			t.Error(deleted, get())
+		}
+	})
+
+	t.Run("request overrides", func(t *testing.T) {
+		conf := ctrl.MergeResourcePolicies(config, map[string]string{
+			ctrl.PolicyResourceExportDatabases:       "anonymize",
+			ctrl.PolicyResourcePublicExportDatabases: "transfer-to:user2",
+		})
+		if len(config.ResourcePolicies) != 0 {
+			t.Error("merge must not change the configured policies", config.ResourcePolicies)
+		}
+		var get func() map[string]ctrl.ExportDatabase
+		conf.DatabaseExportsUrl, get = mockExportDatabases(t, initDatabases())
+		deleted, transferred, decisions, err := ctrl.ExportDatabasesCleaner{}.DeleteWithPolicies(t.Context(), user1, nil, conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(deleted) != 0 || len(transferred) != 2 {
+			t.Error(deleted, transferred)
+		}
+		expected := []ctrl.PolicyDecision{
+			{Resource: ctrl.PolicyResourceExportDatabases, Id: "private1", Policy: ctrl.PolicyAnonymize, Target: "anonymous"},
+			{Resource: ctrl.PolicyResourcePublicExportDatabases, Id: "public1", Policy: "transfer-to:user2", Target: "user2"},
+		}
+		if !equalDecisions(decisions, expected) {
+			t.Errorf("%#v", decisions)
+		}
+		owners := map[string]string{}
+		for _, database := range get() {
+			owners[database.Name] = database.UserId
+		}
+		if len(owners) != 3 || owners["private1"] != "anonymous" || owners["public1"] != "user2" || owners["public3"] != "user3" {
+			t.Errorf("%#v", get())
+		}
+	})
+
+	t.Run("preview matches deletion", func(t *testing.T) {
+		for _, policy := range []string{"delete", "keep", "anonymize"} {
+			conf := ctrl.MergeResourcePolicies(config, map[string]string{
+				ctrl.PolicyResourceExportDatabases:       policy,
+				ctrl.PolicyResourcePublicExportDatabases: policy,
+			})
+			conf.DatabaseExportsUrl, _ = mockExportDatabases(t, initDatabases())
+			resources, err := ctrl.ExportDatabasesCleaner{}.List(t.Context(), user1, conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			deleted, _, decisions, err := ctrl.ExportDatabasesCleaner{}.DeleteWithPolicies(t.Context(), user1, nil, conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			listed := append([]string{}, resources[0].Ids...)
+			for _, kept := range resources[0].Kept {
+				listed = append(listed, kept.Id)
+			}
+			decided := []string{"public3"} //public databases of other users are listed as kept, without decision
+			for _, decision := range decisions {
+				decided = append(decided, decision.Id)
+			}
+			slices.Sort(listed)
+			slices.Sort(decided)
+			slices.Sort(deleted)
+			slices.Sort(resources[0].Ids)
+			if !slices.Equal(listed, decided) || !slices.Equal(deleted, resources[0].Ids) {
+				t.Error(policy, resources, deleted, decisions)
+			}
+		}
+	})
+
+	t.Run("decisions in deletion job", func(t *testing.T) {
+		conf, _, _ := startDeletionMocks(t, t.Context(), config)
+		conf.RemoveExportDatabaseMetadataOnUserDelete = false
+		conf.ResourcePolicies = map[string]string{ctrl.PolicyResourcePublicExportDatabases: "delete"}
+		var get func() map[string]ctrl.ExportDatabase
+		conf.DatabaseExportsUrl, get = mockExportDatabases(t, initDatabases())
+		jobs := store.NewMemory[ctrl.DeletionJob]()
+		err := ctrl.DeleteUser(t.Context(), "user1", conf, jobs, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		job, _, err := jobs.Get("user1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		step := findStep(job, "export-databases")
+		if step.State != ctrl.DeletionDone || len(step.Decisions) != 2 {
+			t.Errorf("%#v", step)
+		}
+		if databases := get(); len(databases) != 2 || databases["private1"].UserId != "user1" || databases["public3"].UserId != "user3" {
+			t.Errorf("%#v", databases)
+		}
+	})
+}
+
+func equalDecisions(actual []ctrl.PolicyDecision, expected []ctrl.PolicyDecision) bool {
+	sort.Slice(actual, func(i, j int) bool {
+		return actual[i].Id < actual[j].Id
+	})
+	sort.Slice(expected, func(i, j int) bool {
+		return expected[i].Id < expected[j].Id
+	})
+	if len(actual) != len(expected) {
+		return false
+	}
+	for i := range actual {
+		if actual[i] != expected[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"encoding/json"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/ctrl"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestUserResourcesPreview(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	deleteCalls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			deleteCalls++
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		switch r.URL.Path {
+		case "/dashboards":
+			json.NewEncoder(w).Encode([]ctrl.IdWrapper{{Id: "d1"}, {Id: "d2"}})
+		case "/operator":
+			json.NewEncoder(w).Encode(map[string]interface{}{"operators": []map[string]interface{}{
+				{"_id": "o1", "userId": "user1", "pub": true},
+				{"_id": "o2", "userId": "user2", "pub": true},
+			}})
+		default:
+			http.Error(w, "unknown path", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	config, _, _ = startDeletionMocks(t, t.Context(), config)
+	config.DashboardServiceUrl = server.URL
+	config.AnalyticsOperatorRepoUrl = server.URL
+
+	result, err := ctrl.GetUserResources(t.Context(), "user1", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleteCalls != 0 {
+		t.Error("preview must not delete anything", deleteCalls)
+	}
+	services := map[string]ctrl.ServiceResources{}
+	for _, entry := range result.Services {
+		services[entry.Service+"/"+entry.Resource] = entry
+	}
+	if entry := services["dashboard/dashboards"]; entry.Count != 2 || !reflect.DeepEqual(entry.Ids, []string{"d1", "d2"}) || entry.Error != "" {
+		t.Errorf("%#v", entry)
+	}
+	entry := services["analytics-operator-repo/operators"]
+	if entry.Count != 1 || !reflect.DeepEqual(entry.Ids, []string{"o1"}) {
+		t.Errorf("%#v", entry)
+	}
+	if len(entry.Kept) != 1 || entry.Kept[0].Id != "o2" {
+		t.Errorf("%#v", entry)
+	}
+	if _, ok := services["imports/instances"]; ok {
+		t.Error("disabled services should not be listed")
+	}
+	if entry := services["keycloak/user"]; entry.Count != 1 {
+		t.Errorf("%#v", entry)
+	}
+}
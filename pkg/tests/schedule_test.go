@@ -0,0 +1,341 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/user-management/pkg/api"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/ctrl"
+	"github.com/SENERGY-Platform/user-management/pkg/store"
+	"github.com/SENERGY-Platform/user-management/pkg/tests/mocks"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// mockKeycloakUsers tracks the enabled flag and logouts of users and forwards all other requests to mocks.MockKeycloak.
+// Every user exists, except users with the id prefix "missing".
+func mockKeycloakUsers(t *testing.T) (keycloakUrl string, getEnabled func(id string) (enabled bool, known bool), getLogouts func() []string) {
+	mockUrl, err := mocks.MockKeycloak(t.Context())
+	if err != nil {
+		t.Fatal(err)
+	}
+	target, err := url.Parse(mockUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	mux := sync.Mutex{}
+	enabled := map[string]bool{}
+	logouts := []string{}
+	prefix := "/auth/admin/realms/master/users/"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			proxy.ServeHTTP(w, r)
+			return
+		}
+		mux.Lock()
+		defer mux.Unlock()
+		id := strings.TrimPrefix(r.URL.Path, prefix)
+		switch {
+		case r.Method == http.MethodGet && !strings.Contains(id, "/") && !strings.HasPrefix(id, "missing"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "username": id, "enabled": enabled[id]})
+		case r.Method == http.MethodPut:
+			user := map[string]interface{}{}
+			json.NewDecoder(r.Body).Decode(&user)
+			enabled[id] = user["enabled"] == true
+		case r.Method == http.MethodPost && strings.HasSuffix(id, "/logout"):
+			logouts = append(logouts, strings.TrimSuffix(id, "/logout"))
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, func(id string) (bool, bool) {
+			mux.Lock()
+			defer mux.Unlock()
+			value, ok := enabled[id]
+			return value, ok
+		}, func() []string {
+			mux.Lock()
+			defer mux.Unlock()
+			return append([]string{}, logouts...)
+		}
+}
+
+func TestScheduledDeletion(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	config, _, _ = startDeletionMocks(t, t.Context(), config)
+	keycloakUrl, getEnabled, getLogouts := mockKeycloakUsers(t)
+	config.KeycloakUrl = keycloakUrl
+
+	location := filepath.Join(t.TempDir(), "deletion-jobs.json")
+	jobs, err := store.NewFile[ctrl.DeletionJob](location)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job, cancelToken1, err := ctrl.ScheduleDeletion(t.Context(), "user1", "", time.Hour, config, jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelToken1 == "" || job.CancelTokenHash == "" || strings.Contains(job.CancelTokenHash, cancelToken1) {
+		t.Errorf("%#v", job)
+	}
+	if enabled, known := getEnabled("user1"); enabled || !known {
+		t.Error("expected disabled user")
+	}
+	if logouts := getLogouts(); len(logouts) != 1 || logouts[0] != "user1" {
+		t.Error(logouts)
+	}
+	_, cancelToken2, err := ctrl.ScheduleDeletion(t.Context(), "user2", "", time.Hour, config, jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, cancelToken3, err := ctrl.ScheduleDeletion(t.Context(), "user3", "", time.Hour, config, jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//simulate restart
+	jobs, err = store.NewFile[ctrl.DeletionJob](location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scheduled, err := ctrl.ListScheduledDeletions(jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scheduled) != 3 || scheduled[0].UserId != "user1" || !scheduled[0].ScheduledFor.Equal(*job.ScheduledFor) {
+		t.Fatalf("%#v", scheduled)
+	}
+
+	err = ctrl.CancelScheduledDeletion(t.Context(), "user2", config, jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enabled, _ := getEnabled("user2"); !enabled {
+		t.Error("expected enabled user")
+	}
+	err = ctrl.CancelScheduledDeletion(t.Context(), "user2", config, jobs)
+	if !errors.Is(err, ctrl.ErrNotScheduled) {
+		t.Error(err)
+	}
+
+	//the disabled user cancels with the token of the deletion request
+	for _, c := range []struct{ userId, cancelToken string }{
+		{"user3", ""},
+		{"user3", cancelToken1},
+		{"user2", cancelToken2}, //already canceled
+		{"unknown", cancelToken3},
+	} {
+		err = ctrl.CancelScheduledDeletionWithToken(t.Context(), c.userId, c.cancelToken, config, jobs)
+		if !errors.Is(err, ctrl.ErrInvalidCancelToken) {
+			t.Error(c, err)
+		}
+	}
+	if enabled, _ := getEnabled("user3"); enabled {
+		t.Error("expected disabled user")
+	}
+	err = ctrl.CancelScheduledDeletionWithToken(t.Context(), "user3", cancelToken3, config, jobs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if enabled, _ := getEnabled("user3"); !enabled {
+		t.Error("expected enabled user")
+	}
+	err = ctrl.CancelScheduledDeletionWithToken(t.Context(), "user3", cancelToken3, config, jobs)
+	if !errors.Is(err, ctrl.ErrInvalidCancelToken) {
+		t.Error("expected used token to be invalid", err)
+	}
+
+	started := []string{}
+	start := func(job ctrl.DeletionJob) error {
+		started = append(started, job.UserId)
+		return nil
+	}
+	err = ctrl.StartDueDeletions(jobs, time.Now(), start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(started) != 0 {
+		t.Error(started)
+	}
+	err = ctrl.StartDueDeletions(jobs, time.Now().Add(2*time.Hour), start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(started) != 1 || started[0] != "user1" {
+		t.Error(started)
+	}
+	job, _, err = jobs.Get("user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != ctrl.DeletionPending {
+		t.Errorf("%#v", job)
+	}
+	err = ctrl.CancelScheduledDeletion(t.Context(), "user1", config, jobs)
+	if !errors.Is(err, ctrl.ErrNotScheduled) {
+		t.Error("expected started deletion to be not cancelable", err)
+	}
+
+	err = ctrl.DeleteUser(t.Context(), "user1", config, jobs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, _, err = jobs.Get("user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != ctrl.DeletionDone {
+		t.Errorf("%#v", job)
+	}
+}
+
+func TestCancelDeletionRouteIsPublic(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	router := httprouter.New()
+	router.POST("/user/id/:id/deletion/cancel", func(res http.ResponseWriter, r *http.Request, ps httprouter.Params) {})
+	middleware, err := api.NewAuthorizationMiddleware(config, router)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	middleware.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/user/id/user1/deletion/cancel", nil))
+	if rec.Code != http.StatusOK {
+		t.Error("disabled users have no token to cancel their deletion", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRawDeleteCommandWaitsForGracePeriod(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	config, _, _ = startDeletionMocks(t, t.Context(), config)
+	keycloakUrl, getEnabled, _ := mockKeycloakUsers(t)
+	config.KeycloakUrl = keycloakUrl
+	jobs := store.NewMemory[ctrl.DeletionJob]()
+	audits := store.NewMemory[ctrl.AuditEntry]()
+	msgTime := time.Now()
+
+	//published by another service, without audit id or job
+	command, err := ctrl.ParseUserCommand([]byte(`{"command":"DELETE","id":"user1","policies":{"flows":"keep"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	held, err := ctrl.HoldForGracePeriod(t.Context(), command, msgTime, time.Hour, config, jobs, audits)
+	if err != nil || !held {
+		t.Fatal(held, err)
+	}
+	job, exists, err := jobs.Get("user1")
+	if err != nil || !exists || job.State != ctrl.DeletionScheduled || job.Policies["flows"] != "keep" || job.AuditId == "" {
+		t.Fatalf("%#v %v %v", job, exists, err)
+	}
+	if enabled, known := getEnabled("user1"); enabled || !known {
+		t.Error("expected disabled user")
+	}
+	entry, _, err := audits.Get(job.AuditId)
+	if err != nil || entry.State != ctrl.DeletionScheduled || entry.Source != ctrl.AuditSourceKafka {
+		t.Errorf("%#v %v", entry, err)
+	}
+
+	t.Run("redelivery stays scheduled", func(t *testing.T) {
+		held, err := ctrl.HoldForGracePeriod(t.Context(), command, msgTime, time.Hour, config, jobs, audits)
+		if err != nil || !held {
+			t.Fatal(held, err)
+		}
+		redelivered, _, err := jobs.Get("user1")
+		if err != nil || !redelivered.ScheduledFor.Equal(*job.ScheduledFor) {
+			t.Errorf("%#v %v", redelivered, err)
+		}
+	})
+
+	t.Run("released command is executed", func(t *testing.T) {
+		released := []ctrl.DeletionJob{}
+		err := ctrl.StartDueDeletions(jobs, time.Now().Add(2*time.Hour), func(job ctrl.DeletionJob) error {
+			released = append(released, job)
+			return nil
+		})
+		if err != nil || len(released) != 1 {
+			t.Fatal(released, err)
+		}
+		releasedCommand := ctrl.UserCommandMsg{Command: ctrl.CommandDelete, Id: "user1", AuditId: released[0].AuditId, Policies: released[0].Policies}
+		held, err := ctrl.HoldForGracePeriod(t.Context(), releasedCommand, time.Now(), time.Hour, config, jobs, audits)
+		if err != nil || held {
+			t.Error(held, err)
+		}
+	})
+
+	t.Run("raw transfer command is scheduled", func(t *testing.T) {
+		command, err := ctrl.ParseUserCommand([]byte(`{"command":"TRANSFER","id":"user2","target_id":"user3"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		held, err := ctrl.HoldForGracePeriod(t.Context(), command, msgTime, time.Hour, config, jobs, audits)
+		if err != nil || !held {
+			t.Fatal(held, err)
+		}
+		job, _, err := jobs.Get("user2")
+		if err != nil || job.State != ctrl.DeletionScheduled || job.TargetUserId != "user3" {
+			t.Errorf("%#v %v", job, err)
+		}
+	})
+
+	t.Run("forced command is executed", func(t *testing.T) {
+		command := ctrl.UserCommandMsg{Command: ctrl.CommandDelete, Id: "user4", Force: true}
+		held, err := ctrl.HoldForGracePeriod(t.Context(), command, msgTime, time.Hour, config, jobs, audits)
+		if err != nil || held {
+			t.Error(held, err)
+		}
+		if _, exists, _ := jobs.Get("user4"); exists {
+			t.Error("unexpected job")
+		}
+	})
+
+	t.Run("command of missing user is executed", func(t *testing.T) {
+		command := ctrl.UserCommandMsg{Command: ctrl.CommandDelete, Id: "missing-user"}
+		held, err := ctrl.HoldForGracePeriod(t.Context(), command, msgTime, time.Hour, config, jobs, audits)
+		if err != nil || held {
+			t.Error(held, err)
+		}
+	})
+
+	t.Run("without grace period", func(t *testing.T) {
+		command := ctrl.UserCommandMsg{Command: ctrl.CommandDelete, Id: "user5"}
+		held, err := ctrl.HoldForGracePeriod(t.Context(), command, msgTime, 0, config, jobs, audits)
+		if err != nil || held {
+			t.Error(held, err)
+		}
+	})
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"encoding/json"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/ctrl"
+	"github.com/SENERGY-Platform/user-management/pkg/store"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// mockDashboards stores dashboards with the X-UserId of the creating request as user_id
+func mockDashboards(t *testing.T, dashboards map[string]map[string]interface{}) (url string, get func() map[string]map[string]interface{}) {
+	mux := sync.Mutex{}
+	counter := len(dashboards)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		userId := r.Header.Get("X-UserId")
+		id := strings.TrimPrefix(r.URL.Path, "/dashboards/")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/dashboards":
+			result := []map[string]interface{}{}
+			for _, dashboard := range dashboards {
+				if dashboard["user_id"] == userId {
+					result = append(result, dashboard)
+				}
+			}
+			json.NewEncoder(w).Encode(result)
+		case r.Method == http.MethodGet && dashboards[id]["user_id"] == userId:
+			json.NewEncoder(w).Encode(dashboards[id])
+		case r.Method == http.MethodPost && r.URL.Path == "/dashboards":
+			dashboard := map[string]interface{}{}
+			json.NewDecoder(r.Body).Decode(&dashboard)
+			if _, ok := dashboard["id"]; ok {
+				http.Error(w, "unexpected id", http.StatusBadRequest)
+				return
+			}
+			counter++
+			dashboard["id"] = "d" + strconv.Itoa(counter)
+			dashboard["user_id"] = userId
+			dashboards[dashboard["id"].(string)] = dashboard
+			json.NewEncoder(w).Encode(dashboard)
+		case r.Method == http.MethodDelete && dashboards[id]["user_id"] == userId:
+			delete(dashboards, id)
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, func() map[string]map[string]interface{} {
+		mux.Lock()
+		defer mux.Unlock()
+		result := map[string]map[string]interface{}{}
+		for k, v := range dashboards {
+			result[k] = v
+		}
+		return result
+	}
+}
+
+func TestTransferUser(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	config, _, _ = startDeletionMocks(t, t.Context(), config)
+	dashboardUrl, getDashboards := mockDashboards(t, map[string]map[string]interface{}{
+		"d1": {"id": "d1", "user_id": "user1", "name": "foo"},
+		"d2": {"id": "d2", "user_id": "user1", "name": "bar"},
+		"d3": {"id": "d3", "user_id": "user3", "name": "other"},
+	})
+	config.DashboardServiceUrl = dashboardUrl
+
+	jobs := store.NewMemory[ctrl.DeletionJob]()
+	err = ctrl.TransferUser(t.Context(), "user1", "user1", config, jobs, nil)
+	if err == nil {
+		t.Error("expected error for transfer to the same user")
+	}
+
+	err = ctrl.TransferUser(t.Context(), "user1", "user2", config, jobs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	job, _, err := jobs.Get("user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if job.State != ctrl.DeletionDone || job.Command != ctrl.CommandTransfer || job.TargetUserId != "user2" {
+		t.Errorf("%#v", job)
+	}
+	if step := findStep(job, "dashboard"); step.State != ctrl.DeletionDone || step.Transferred != 2 || step.Deleted != 0 {
+		t.Errorf("%#v", step)
+	}
+	if step := findStep(job, "device-repository"); step.State != ctrl.DeletionSkipped || step.Note == "" {
+		t.Errorf("%#v", step)
+	}
+	if step := findStep(job, "keycloak"); step.State != ctrl.DeletionDone {
+		t.Errorf("%#v", step)
+	}
+
+	dashboards := getDashboards()
+	if len(dashboards) != 3 || dashboards["d3"]["user_id"] != "user3" {
+		t.Errorf("%#v", dashboards)
+	}
+	names := []string{}
+	for _, dashboard := range dashboards {
+		if dashboard["user_id"] == "user2" {
+			names = append(names, dashboard["name"].(string))
+		}
+	}
+	if len(names) != 2 {
+		t.Errorf("%#v", dashboards)
+	}
+}
+
+func TestTransferRetryDoesNotDuplicate(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	config, _, _ = startDeletionMocks(t, t.Context(), config)
+	dashboardUrl, getDashboards := mockDashboards(t, map[string]map[string]interface{}{
+		"d1": {"id": "d1", "user_id": "user1", "name": "foo"},
+	})
+	target, err := url.Parse(dashboardUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the first removal of the original fails after the copy was created
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	failDelete := atomic.Bool{}
+	failDelete.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete && failDelete.CompareAndSwap(true, false) {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	config.DashboardServiceUrl = server.URL
+
+	jobs := store.NewMemory[ctrl.DeletionJob]()
+	err = ctrl.TransferUser(t.Context(), "user1", "user2", config, jobs, nil)
+	if err == nil {
+		t.Fatal("expected error of the failed removal")
+	}
+	job, _, err := jobs.Get("user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if step := findStep(job, "dashboard"); step.State != ctrl.DeletionFailed || !slices.Equal(step.Copied, []string{"d1"}) {
+		t.Errorf("%#v", step)
+	}
+
+	err = ctrl.TransferUser(t.Context(), "user1", "user2", config, jobs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dashboards := getDashboards()
+	if len(dashboards) != 1 {
+		t.Errorf("%#v", dashboards)
+	}
+	for _, dashboard := range dashboards {
+		if dashboard["user_id"] != "user2" || dashboard["name"] != "foo" {
+			t.Errorf("%#v", dashboards)
+		}
+	}
+}
@@ -40,6 +40,7 @@ func TestSwagger(t *testing.T) {
 		t.Fatal("ERROR: unable to load config", err)
 	}
 	config.RemoveExportDatabaseMetadataOnUserDelete = true
+	config.PersistenceDir = t.TempDir() //jobs and ledger must not outlive the test
 
 	config.ServerPort, err = docker.GetFreePort()
 	if err != nil {
@@ -83,6 +84,7 @@ func TestUserDelete(t *testing.T) {
 		t.Fatal("ERROR: unable to load config", err)
 	}
 	config.RemoveExportDatabaseMetadataOnUserDelete = true
+	config.PersistenceDir = t.TempDir() //jobs and ledger must not outlive the test
 
 	config.ServerPort, err = docker.GetFreePort()
 	if err != nil {
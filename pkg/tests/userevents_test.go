@@ -0,0 +1,92 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/ctrl"
+	"github.com/SENERGY-Platform/user-management/pkg/store"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestUserEvents(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	config, _, setDashboardFailure := startDeletionMocks(t, t.Context(), config)
+
+	mux := sync.Mutex{}
+	events := []ctrl.UserEventMsg{}
+	publisher := func(event ctrl.UserEventMsg) error {
+		mux.Lock()
+		defer mux.Unlock()
+		events = append(events, event)
+		return nil
+	}
+	eventNames := func() (result []string) {
+		mux.Lock()
+		defer mux.Unlock()
+		for _, event := range events {
+			name := event.Event
+			if event.Service != "" {
+				name = name + ":" + event.Service
+			}
+			result = append(result, name)
+		}
+		events = []ctrl.UserEventMsg{}
+		return result
+	}
+
+	jobs := store.NewMemory[ctrl.DeletionJob]()
+	setDashboardFailure(true)
+	err = ctrl.DeleteUser(t.Context(), "user1", config, jobs, publisher)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if names := eventNames(); !reflect.DeepEqual(names, []string{
+		ctrl.EventUserDeletionStarted,
+		ctrl.EventUserServiceCleaned + ":device-repository",
+		ctrl.EventUserDeletionFailed,
+	}) {
+		t.Error(names)
+	}
+
+	setDashboardFailure(false)
+	err = ctrl.DeleteUser(t.Context(), "user1", config, jobs, publisher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.Lock()
+	if !slices.ContainsFunc(events, func(event ctrl.UserEventMsg) bool {
+		return event.Id == "user1" && event.Command == ctrl.CommandDelete && event.Service == "keycloak" && event.Count == 1
+	}) {
+		t.Errorf("%#v", events)
+	}
+	mux.Unlock()
+	if names := eventNames(); !reflect.DeepEqual(names, []string{
+		ctrl.EventUserDeletionStarted,
+		ctrl.EventUserServiceCleaned + ":dashboard",
+		ctrl.EventUserServiceCleaned + ":keycloak",
+		ctrl.EventUserDeleted,
+	}) {
+		t.Error(names)
+	}
+}
@@ -0,0 +1,388 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/ctrl"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestListUsers(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	config.KeycloakPageMax = 3
+
+	users := []ctrl.User{}
+	for i, name := range []string{"fiona", "alice", "erin", "bob", "dave", "carol", "grace", "heidi"} {
+		locale := "de"
+		if i%2 == 0 {
+			locale = "en"
+		}
+		users = append(users, ctrl.User{Id: "id-" + name, Name: name, Attributes: map[string]interface{}{"locale": []interface{}{locale}}})
+	}
+	config.KeycloakUrl = mockKeycloakDirectory(t, users, map[string][]string{
+		"g1": {"id-alice", "id-bob", "id-carol", "id-heidi"},
+		"g2": {"id-carol", "id-dave", "id-erin"},
+	})
+	//group members are listed without requests for single users or searches in the whole realm
+	target, err := url.Parse(config.KeycloakUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	fullRequestsMux := sync.Mutex{}
+	fullRequests := []int{} //max of the member requests in full representation
+	groupsOnly := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/auth/admin/") && !strings.HasPrefix(r.URL.Path, "/auth/admin/realms/master/groups/") {
+			t.Error("unexpected request", r.URL.String())
+			http.Error(w, "unexpected request", http.StatusInternalServerError)
+			return
+		}
+		if r.URL.Query().Get("briefRepresentation") == "false" {
+			max, _ := strconv.Atoi(r.URL.Query().Get("max"))
+			fullRequestsMux.Lock()
+			fullRequests = append(fullRequests, max)
+			fullRequestsMux.Unlock()
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	t.Cleanup(groupsOnly.Close)
+	groupsConfig := config
+	groupsConfig.KeycloakUrl = groupsOnly.URL
+
+	//expected applies the options to the candidates in memory
+	expected := func(candidates []ctrl.User, options ctrl.UserListOptions) (names []string, total int) {
+		for _, user := range candidates {
+			if user.Id == options.ExcludeId || !strings.Contains(user.Name, options.Search) {
+				continue
+			}
+			if locale, ok := options.Attributes["locale"]; ok && user.Attributes["locale"].([]interface{})[0] != locale {
+				continue
+			}
+			names = append(names, user.Name)
+		}
+		slices.Sort(names)
+		if options.Sort == ctrl.UserSortUsernameDesc {
+			slices.Reverse(names)
+		}
+		total = len(names)
+		names = names[min(options.Offset, total):]
+		if options.Limit > 0 {
+			names = names[:min(options.Limit, len(names))]
+		}
+		return names, total
+	}
+	names := func(users []ctrl.User) (result []string) {
+		for _, user := range users {
+			result = append(result, user.Name)
+		}
+		return result
+	}
+
+	members := []ctrl.User{}
+	g1Members := []ctrl.User{}
+	for _, user := range users {
+		if !slices.Contains([]string{"fiona", "grace"}, user.Name) {
+			members = append(members, user)
+		}
+		if slices.Contains([]string{"alice", "bob", "carol", "heidi"}, user.Name) {
+			g1Members = append(g1Members, user)
+		}
+	}
+	groups := []ctrl.Group{{ID: "g1"}, {ID: "g2"}}
+
+	for _, search := range []string{"", "a"} {
+		for _, attributes := range []map[string]string{nil, {"locale": "de"}} {
+			for _, exclude := range []string{"", "id-alice", "id-dave", "id-heidi", "id-fiona"} {
+				for _, sort := range []string{"", ctrl.UserSortUsernameDesc} {
+					for limit := 0; limit <= 3; limit++ {
+						for offset := 0; offset <= 9; offset++ {
+							options := ctrl.UserListOptions{Limit: limit, Offset: offset, Search: search, Attributes: attributes, Sort: sort, ExcludeId: exclude}
+							name := fmt.Sprintf("%#v", options)
+
+							result, total, err := ctrl.ListUsers(t.Context(), options, config)
+							if err != nil {
+								t.Fatal(name, err)
+							}
+							expectedNames, expectedTotal := expected(users, options)
+							if total != expectedTotal || !slices.Equal(names(result), expectedNames) {
+								t.Error("ListUsers", name, total, names(result), expectedTotal, expectedNames)
+							}
+
+							result, total, err = ctrl.ListGroupMembers(t.Context(), groups, options, groupsConfig)
+							if err != nil {
+								t.Fatal(name, err)
+							}
+							expectedNames, expectedTotal = expected(members, options)
+							if total != expectedTotal || !slices.Equal(names(result), expectedNames) {
+								t.Error("ListGroupMembers", name, total, names(result), expectedTotal, expectedNames)
+							}
+							for _, user := range result {
+								if user.Attributes == nil {
+									t.Error("ListGroupMembers", name, "missing attributes of", user.Name)
+								}
+							}
+
+							//a single group is paged by keycloak
+							fullRequestsMux.Lock()
+							fullRequests = fullRequests[:0]
+							fullRequestsMux.Unlock()
+							result, total, err = ctrl.ListGroupMembers(t.Context(), groups[:1], options, groupsConfig)
+							if err != nil {
+								t.Fatal(name, err)
+							}
+							expectedNames, expectedTotal = expected(g1Members, options)
+							if total != expectedTotal || !slices.Equal(names(result), expectedNames) {
+								t.Error("ListGroupMembers single group", name, total, names(result), expectedTotal, expectedNames)
+							}
+							fullRequestsMux.Lock()
+							if search == "" && attributes == nil && limit > 0 && slices.ContainsFunc(fullRequests, func(max int) bool { return max > limit+1 }) {
+								t.Error("ListGroupMembers single group", name, "requested more than the page in full representation", fullRequests)
+							}
+							fullRequestsMux.Unlock()
+						}
+					}
+				}
+			}
+		}
+	}
+
+	t.Run("invalid options", func(t *testing.T) {
+		for _, options := range []ctrl.UserListOptions{{Limit: -1}, {Offset: -1}, {Sort: "email"}} {
+			if ctrl.ValidateUserListOptions(options) == nil {
+				t.Error("expected error", options)
+			}
+		}
+	})
+}
+
+func TestListUsersCollation(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	config.KeycloakPageMax = 2
+	//keycloak orders "Bob" after "alice", the byte order of go before
+	ordered := []string{"alice", "Bob", "carol", "Dave", "erin"}
+	users := []ctrl.User{}
+	for _, name := range ordered {
+		users = append(users, ctrl.User{Id: "id-" + name, Name: name, Attributes: map[string]interface{}{}})
+	}
+	groupMembers := []string{}
+	for _, user := range users {
+		groupMembers = append(groupMembers, user.Id)
+	}
+	config.KeycloakUrl = mockKeycloakDirectory(t, users, map[string][]string{"g1": groupMembers})
+
+	for _, exclude := range []string{"", "id-alice", "id-Bob", "id-Dave", "id-erin"} {
+		for _, sort := range []string{"", ctrl.UserSortUsernameDesc} {
+			for limit := 1; limit <= 3; limit++ {
+				for offset := 0; offset <= 5; offset++ {
+					options := ctrl.UserListOptions{Limit: limit, Offset: offset, Sort: sort, ExcludeId: exclude}
+					expected := slices.DeleteFunc(slices.Clone(ordered), func(name string) bool { return "id-"+name == exclude })
+					if sort == ctrl.UserSortUsernameDesc {
+						slices.Reverse(expected)
+					}
+					expected = expected[min(offset, len(expected)):]
+					expected = expected[:min(limit, len(expected))]
+
+					result, _, err := ctrl.ListUsers(t.Context(), options, config)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !slices.Equal(userNames(result), expected) {
+						t.Errorf("ListUsers %#v %v %v", options, userNames(result), expected)
+					}
+					result, _, err = ctrl.ListGroupMembers(t.Context(), []ctrl.Group{{ID: "g1"}}, options, config)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if !slices.Equal(userNames(result), expected) {
+						t.Errorf("ListGroupMembers %#v %v %v", options, userNames(result), expected)
+					}
+				}
+			}
+		}
+	}
+}
+
+func userNames(users []ctrl.User) (result []string) {
+	for _, user := range users {
+		result = append(result, user.Name)
+	}
+	return result
+}
+
+func TestSearchUsers(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	config.KeycloakPageMax = 2
+	users := []ctrl.User{
+		{Id: "id-ann", Name: "ann", Attributes: map[string]interface{}{"team": []interface{}{"a"}}},
+		{Id: "id-anna", Name: "anna", Attributes: map[string]interface{}{"team": []interface{}{"a"}}},
+		{Id: "id-hanna", Name: "hanna", Attributes: map[string]interface{}{"team": []interface{}{"b"}}},
+		{Id: "id-bob", Name: "bob", Attributes: map[string]interface{}{"team": []interface{}{"a"}}},
+	}
+	config.KeycloakUrl = mockKeycloakDirectory(t, users, map[string][]string{"g1": {"id-ann", "id-hanna"}})
+	members, err := ctrl.GetGroupMemberIds(t.Context(), []ctrl.Group{{ID: "g1"}}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := func(users []ctrl.User) (result []string) {
+		for _, user := range users {
+			result = append(result, user.Name)
+		}
+		return result
+	}
+
+	for _, c := range []struct {
+		search    ctrl.UserSearch
+		members   map[string]bool
+		expected  []string
+		ambiguous bool
+	}{
+		{search: ctrl.UserSearch{Username: "ann"}, expected: []string{"ann", "anna", "hanna"}},
+		{search: ctrl.UserSearch{Username: "ann", Exact: true}, expected: []string{"ann"}},
+		{search: ctrl.UserSearch{Username: "ann"}, members: members, expected: []string{"ann", "hanna"}},
+		{search: ctrl.UserSearch{Username: "ann", Attributes: map[string]string{"team": "a"}}, expected: []string{"ann", "anna"}},
+		{search: ctrl.UserSearch{Attributes: map[string]string{"team": "a"}, Exact: true}, expected: []string{"ann", "anna", "bob"}, ambiguous: true},
+		{search: ctrl.UserSearch{Attributes: map[string]string{"team": "a"}, Exact: true}, members: members, expected: []string{"ann"}},
+		{search: ctrl.UserSearch{Username: "carl", Exact: true}, expected: nil},
+	} {
+		result, err := ctrl.SearchUsers(t.Context(), c.search, c.members, config)
+		if c.ambiguous != errors.Is(err, ctrl.ErrAmbiguousUser) || (err != nil && !c.ambiguous) {
+			t.Error(c.search, err)
+		}
+		if result == nil || !slices.Equal(names(result), c.expected) {
+			t.Error(c.search, names(result), c.expected)
+		}
+	}
+}
+
+func TestGetUsernames(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	users := []ctrl.User{}
+	ids := []string{}
+	for i := 0; i < 20; i++ {
+		users = append(users, ctrl.User{Id: fmt.Sprintf("id-%v", i), Name: fmt.Sprintf("user%v", i)})
+		ids = append(ids, fmt.Sprintf("id-%v", i))
+	}
+	directoryUrl := mockKeycloakDirectory(t, users, nil)
+	target, err := url.Parse(directoryUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	mux := sync.Mutex{}
+	running, maxRunning := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/users/broken") {
+			http.Error(w, "test error", http.StatusInternalServerError)
+			return
+		}
+		mux.Lock()
+		running++
+		maxRunning = max(maxRunning, running)
+		mux.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		proxy.ServeHTTP(w, r)
+		mux.Lock()
+		running--
+		mux.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	config.KeycloakUrl = server.URL
+	config.KeycloakLookupWorkers = 3
+
+	result, err := ctrl.GetUsernames(t.Context(), append(ids, "unknown", "id-1", "", "broken"), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := result.Names
+	if len(names) != 22 {
+		t.Error(len(names), names)
+	}
+	for i, id := range ids {
+		if name := names[id]; name == nil || *name != fmt.Sprintf("user%v", i) {
+			t.Error(id, name)
+		}
+	}
+	for _, id := range []string{"unknown", ""} {
+		if name, ok := names[id]; !ok || name != nil {
+			t.Error(id, ok, name)
+		}
+	}
+	if _, ok := names["broken"]; ok || len(result.Failed) != 1 || result.Failed["broken"] == "" {
+		t.Errorf("%#v", result)
+	}
+	if maxRunning < 2 || maxRunning > 3 {
+		t.Error("unexpected number of concurrent requests", maxRunning)
+	}
+
+	t.Run("too many ids", func(t *testing.T) {
+		conf := config
+		conf.KeycloakLookupMaxIds = 5
+		_, err := ctrl.GetUsernames(t.Context(), ids[:6], conf)
+		if !errors.Is(err, ctrl.ErrLookupTooLarge) {
+			t.Error(err)
+		}
+		conf.KeycloakLookupMaxIds = 0
+		_, err = ctrl.GetUsernames(t.Context(), append(ids, ids...), conf)
+		if err != nil {
+			t.Error(err)
+		}
+		for len(ids) <= ctrl.DefaultKeycloakLookupMaxIds {
+			ids = append(ids, ids...)
+		}
+		_, err = ctrl.GetUsernames(t.Context(), ids, conf)
+		if !errors.Is(err, ctrl.ErrLookupTooLarge) {
+			t.Error(err)
+		}
+	})
+
+	t.Run("unreachable keycloak", func(t *testing.T) {
+		conf := config
+		_, err := ctrl.GetUsernames(t.Context(), ids[:3], conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		server.Close()
+		_, err = ctrl.GetUsernames(t.Context(), ids[:3], conf)
+		if err == nil {
+			t.Error("expected error")
+		}
+	})
+}
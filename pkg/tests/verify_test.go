@@ -0,0 +1,126 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/ctrl"
+	"github.com/SENERGY-Platform/user-management/pkg/store"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// mockForgetfulService answers every delete with 200, but removes an item only after ignoring the given number of delete requests for it;
+// a negative number never removes items
+func mockForgetfulService(t *testing.T, ignoredDeletes int) (url string) {
+	mux := sync.Mutex{}
+	items := map[string]int{"a": 0, "b": 0}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mux.Lock()
+		defer mux.Unlock()
+		if r.Method == http.MethodGet {
+			result := []map[string]string{}
+			for id := range items {
+				result = append(result, map[string]string{"id": id})
+			}
+			json.NewEncoder(w).Encode(result)
+			return
+		}
+		id := strings.TrimPrefix(r.URL.Path, "/items/")
+		if _, ok := items[id]; !ok {
+			return
+		}
+		if ignoredDeletes < 0 || items[id] < ignoredDeletes {
+			items[id]++
+			return
+		}
+		delete(items, id)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestDeletionVerification(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	config, _, _ = startDeletionMocks(t, t.Context(), config)
+	config.DeletionVerify = true
+	config.DeletionVerifyRetries = 2
+
+	run := func(t *testing.T, ignoredDeletes int) (job ctrl.DeletionJob, events []ctrl.UserEventMsg, err error) {
+		conf := config
+		url := mockForgetfulService(t, ignoredDeletes)
+		conf.HttpCleaners = []configuration.HttpCleanerConfig{{
+			Name:      "forgetful",
+			ListUrl:   url + "/items",
+			DeleteUrl: url + "/items/{id}",
+		}}
+		jobs := store.NewMemory[ctrl.DeletionJob]()
+		eventsMux := sync.Mutex{}
+		err = ctrl.DeleteUser(t.Context(), "user1", conf, jobs, func(event ctrl.UserEventMsg) error {
+			eventsMux.Lock()
+			defer eventsMux.Unlock()
+			events = append(events, event)
+			return nil
+		})
+		job, _, getErr := jobs.Get("user1")
+		if getErr != nil {
+			t.Fatal(getErr)
+		}
+		return job, events, err
+	}
+
+	t.Run("retry removes leftovers", func(t *testing.T) {
+		job, _, err := run(t, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if step := findStep(job, "forgetful"); step.State != ctrl.DeletionDone || step.Deleted != 4 || len(step.Orphans) != 0 {
+			t.Errorf("%#v", step)
+		}
+		if step := findStep(job, "keycloak"); step.State != ctrl.DeletionDone {
+			t.Errorf("%#v", step)
+		}
+	})
+
+	t.Run("orphans fail the deletion before keycloak", func(t *testing.T) {
+		job, events, err := run(t, -1)
+		if !errors.Is(err, ctrl.ErrRemainingResources) {
+			t.Fatal(err)
+		}
+		if job.State != ctrl.DeletionFailed {
+			t.Errorf("%#v", job)
+		}
+		if step := findStep(job, "forgetful"); step.State != ctrl.DeletionFailed || step.Deleted != 6 || len(step.Orphans) != 2 {
+			t.Errorf("%#v", step)
+		}
+		if step := findStep(job, "keycloak"); step.State != ctrl.DeletionPending {
+			t.Errorf("%#v", step)
+		}
+		last := events[len(events)-1]
+		if last.Event != ctrl.EventUserDeletionFailed || len(last.Orphans["forgetful"]) != 2 {
+			t.Errorf("%#v", last)
+		}
+	})
+}
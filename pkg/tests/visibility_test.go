@@ -0,0 +1,109 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"github.com/SENERGY-Platform/user-management/pkg/configuration"
+	"github.com/SENERGY-Platform/user-management/pkg/ctrl"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestAttributeVisibility(t *testing.T) {
+	config, err := configuration.Load("./../../config.json")
+	if err != nil {
+		t.Fatal("ERROR: unable to load config", err)
+	}
+	config.AttributeVisibility = map[string]string{
+		"locale": ctrl.AttributeVisibilityPublic,
+		"team":   ctrl.AttributeVisibilityGroupMembers,
+		"phone":  ctrl.AttributeVisibilitySelf,
+		"ldapId": ctrl.AttributeVisibilityAdmin,
+	}
+	config.AttributeVisibilityDefault = ctrl.AttributeVisibilitySelf
+	err = ctrl.ValidateAttributeVisibility(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attributes := map[string]interface{}{
+		"locale":  []interface{}{"de"},
+		"team":    []interface{}{"a"},
+		"phone":   []interface{}{"123"},
+		"ldapId":  []interface{}{"x"},
+		"unknown": []interface{}{"y"},
+	}
+	users := []ctrl.User{
+		{Id: "user1", Name: "user1", Attributes: attributes},
+		{Id: "user2", Name: "user2"},
+		{Id: "user3", Name: "user3"},
+	}
+	config.KeycloakUrl = mockKeycloakDirectory(t, users, map[string][]string{"team": {"user1", "user2"}, "other": {"user3"}})
+
+	visible := func(user ctrl.User) []string {
+		return slices.Sorted(maps.Keys(user.Attributes))
+	}
+
+	for _, c := range []struct {
+		callerId string
+		admin    bool
+		relation ctrl.UserRelation
+		expected []string
+	}{
+		{"user3", false, ctrl.RelationOther, []string{"locale"}},
+		{"user2", false, ctrl.RelationGroupMember, []string{"locale", "team"}},
+		{"user1", false, ctrl.RelationSelf, []string{"locale", "phone", "team", "unknown"}},
+		{"user3", true, ctrl.RelationAdmin, []string{"ldapId", "locale", "phone", "team", "unknown"}},
+	} {
+		relation, err := ctrl.GetUserRelation(t.Context(), c.callerId, c.admin, users[0], config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if relation != c.relation {
+			t.Error(c.callerId, c.admin, relation)
+		}
+		if result := visible(ctrl.FilterUserAttributes(users[0], relation, config)); !slices.Equal(result, c.expected) {
+			t.Error(c.callerId, c.admin, result)
+		}
+	}
+	if len(users[0].Attributes) != 5 {
+		t.Error("filter modified the original attributes", users[0].Attributes)
+	}
+
+	t.Run("default is public", func(t *testing.T) {
+		conf := config
+		conf.AttributeVisibility = nil
+		conf.AttributeVisibilityDefault = ""
+		if result := visible(ctrl.FilterUserAttributes(users[0], ctrl.RelationOther, conf)); len(result) != 5 {
+			t.Error(result)
+		}
+	})
+
+	t.Run("invalid visibility", func(t *testing.T) {
+		conf := config
+		conf.AttributeVisibility = map[string]string{"phone": "private"}
+		if ctrl.ValidateAttributeVisibility(conf) == nil {
+			t.Error("expected error")
+		}
+		conf = config
+		conf.AttributeVisibilityDefault = "nobody"
+		if ctrl.ValidateAttributeVisibility(conf) == nil {
+			t.Error("expected error")
+		}
+	})
+}